@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+var ErrBatchResponse = erk.New(ErkResponse{}, "batch response has {{.results}} results for {{.calls}} calls")
+
+// BatchCall is a call made with Batch.
+type BatchCall struct {
+	FunctionName string
+	Params       interface{}
+
+	// Result is unmarshaled from the data returned by the function. It should be a pointer, or nil to ignore the data.
+	Result interface{}
+
+	// Err is set by Batch if the call fails. If the function returns an error, it is an *Error.
+	Err error
+}
+
+// Batch calls several functions on the service in one request, which the service runs concurrently.
+//
+// Calls fail independently, so the error of each call is set on the call, and Batch only returns an error
+// if the whole batch fails, for example because the service cannot be reached.
+func (c *Client) Batch(ctx context.Context, service string, calls []*BatchCall) error {
+	names := make([]string, len(calls))
+	params := make([]interface{}, len(calls))
+	reqDetails := c.newRequestDetails(ctx, service, "")
+	reqDetails.Batch = make([]strand.BatchCall, len(calls))
+	for i, call := range calls {
+		names[i] = call.FunctionName
+		params[i] = call.Params
+		reqDetails.Batch[i] = strand.BatchCall{ID: strconv.Itoa(i), FunctionName: call.FunctionName}
+	}
+	errParams := erk.Params{"serviceName": service, "fnName": strings.Join(names, ", ")}
+
+	resp, err := c.post(ctx, reqDetails, params, errParams)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoded, err := wire.NewDecoder(resp.Body, c.DecoderOptions...).Decode()
+	if err != nil {
+		return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+	}
+
+	var respDetails strand.ResponseDetails
+	if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &respDetails); err != nil {
+		return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+	}
+
+	// Errors that fail the whole batch are returned
+	if err := c.handleResponse(reqDetails.RequestID, &respDetails, decoded, nil, errParams); err != nil {
+		return err
+	}
+
+	// The data of each call is converted to JSON, regardless of the encoding
+	var rawResults []json.RawMessage
+	if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &rawResults); err != nil {
+		return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+	}
+	if len(rawResults) != len(calls) || len(respDetails.Batch) != len(calls) {
+		return erk.WithParams(ErrBatchResponse, erk.Params{"results": len(respDetails.Batch), "calls": len(calls)})
+	}
+
+	for i, call := range calls {
+		result := respDetails.Batch[i]
+		if result.ID != reqDetails.Batch[i].ID {
+			return erk.WithParams(ErrResponseRequestID, erk.Params{"requestID": reqDetails.Batch[i].ID, "responseID": result.ID})
+		}
+
+		rawResult := []byte(rawResults[i])
+		if len(rawResult) == 0 {
+			rawResult = []byte("null")
+		}
+
+		if result.IsError {
+			// The service calls each function with a request ID of its own
+			callRequestID := reqDetails.RequestID + "/" + result.ID
+			call.Err = newError(callRequestID, &strand.ResponseDetails{
+				RequestID:       callRequestID,
+				IsError:         true,
+				IsInternalError: result.IsInternalError,
+			}, rawResult)
+			continue
+		}
+
+		if call.Result != nil {
+			if err := json.Unmarshal(rawResult, call.Result); err != nil {
+				call.Err = erk.WrapAs(erk.WithParams(ErrDecodingResponseResult, erk.Params{"serviceName": service, "fnName": call.FunctionName}), err)
+			}
+		}
+	}
+
+	return nil
+}
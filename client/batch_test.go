@@ -0,0 +1,68 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hoistup/hoist-go/client"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+func TestBatch(t *testing.T) {
+	var received strand.RequestDetails
+	server := newTestServer(&received)
+	defer server.Close()
+
+	table := []struct {
+		Name     string
+		Encoding wire.Encoding
+	}{
+		{Name: "with the JSON encoding", Encoding: wire.EncodingJSON},
+		{Name: "with the MessagePack encoding", Encoding: wire.EncodingMsgPack},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			c := client.New(server.URL)
+			c.NewRequestID = func() string { return "my-id" }
+			c.Encoding = entry.Encoding
+
+			var first, second EchoParams
+			calls := []*client.BatchCall{
+				{FunctionName: "echo", Params: &EchoParams{Message: "a"}, Result: &first},
+				{FunctionName: "fail", Params: &EchoParams{}},
+				{FunctionName: "echo", Params: &EchoParams{Message: "b"}, Result: &second},
+			}
+			is.NoErr(c.Batch(context.Background(), "my-service", calls))
+
+			is.Equal(received.Batch, []strand.BatchCall{
+				{ID: "0", FunctionName: "echo"},
+				{ID: "1", FunctionName: "fail"},
+				{ID: "2", FunctionName: "echo"},
+			})
+
+			is.NoErr(calls[0].Err)
+			is.Equal(first, EchoParams{Message: "a"})
+			is.True(errors.Is(calls[1].Err, ErrTest))
+			var callErr *client.Error
+			is.True(errors.As(calls[1].Err, &callErr))
+			is.Equal(callErr.RequestID, "my-id/1")
+			is.NoErr(calls[2].Err)
+			is.Equal(second, EchoParams{Message: "b"})
+		})
+	}
+
+	t.Run("with unreachable service", func(t *testing.T) {
+		is := is.New(t)
+
+		c := client.New("http://127.0.0.1:1")
+		err := c.Batch(context.Background(), "my-service", []*client.BatchCall{{FunctionName: "echo"}})
+		is.True(errors.Is(err, client.ErrSendingRequest))
+	})
+}
@@ -0,0 +1,254 @@
+// Package client calls functions on hoist services.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+type (
+	ErkRequest  struct{ erks.Default }
+	ErkResponse struct{ erks.Default }
+)
+
+var (
+	ErrEncodingRequest        = erk.New(ErkRequest{}, "could not encode request for function '{{.fnName}}' on service '{{.serviceName}}': {{.err}}")
+	ErrSendingRequest         = erk.New(ErkRequest{}, "could not call function '{{.fnName}}' on service '{{.serviceName}}': {{.err}}")
+	ErrDecodingResponse       = erk.New(ErkResponse{}, "could not decode response from function '{{.fnName}}' on service '{{.serviceName}}': {{.err}}")
+	ErrResponseRequestID      = erk.New(ErkResponse{}, "response request ID '{{.responseID}}' does not match request ID '{{.requestID}}'")
+	ErrDecodingResponseResult = erk.New(ErkResponse{}, "could not unmarshal result from function '{{.fnName}}' on service '{{.serviceName}}': {{.err}}")
+)
+
+// fnPath is where hoist services accept function calls.
+const fnPath = "/_/v1/fn"
+
+// Client calls functions on a hoist service.
+type Client struct {
+	// URL of the hoist service, for example: http://localhost:8080
+	URL string
+
+	// HTTPClient used to make requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// NewRequestID generates the ID of each request. Defaults to NewRequestID.
+	NewRequestID func() string
+
+	// DecoderOptions limit the size of responses. Defaults to the wire.NewDecoder defaults.
+	DecoderOptions []wire.DecoderOption
+
+	// Encoding of requests. The service responds in the same encoding. Defaults to wire.DefaultEncoding.
+	Encoding wire.Encoding
+
+	// Compression of large requests and responses, such as wire.CompressionGzip. Defaults to wire.CompressionNone.
+	// Responses are compressed if the service supports it. Requests are only compressed once the service has
+	// declared it accepts the compression, so services that do not support it never receive compressed requests.
+	Compression wire.Compression
+
+	// CompressionMinBytes is the size params must reach before they are compressed.
+	// Defaults to wire.DefaultCompressionMinBytes.
+	CompressionMinBytes int
+
+	// Checksum adds a checksum to requests, which hoist services verify, and add to their responses.
+	// Services that do not support checksums cannot decode these requests.
+	Checksum bool
+
+	// MaxInFlight is how many calls each Conn sends before waiting for their responses.
+	// Defaults to DefaultMaxInFlight.
+	MaxInFlight int
+
+	// serviceAcceptsCompression is set to 1 when the service declares it accepts the compression.
+	serviceAcceptsCompression int32
+}
+
+// New creates a client for the hoist service at the provided URL.
+func New(url string) *Client {
+	return &Client{
+		URL:          url,
+		HTTPClient:   http.DefaultClient,
+		NewRequestID: NewRequestID,
+	}
+}
+
+// NewRequestID returns a random 128 bit request ID, encoded as hex.
+func NewRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return hex.EncodeToString([]byte(time.Now().String()))
+	}
+
+	return hex.EncodeToString(id[:])
+}
+
+// Call the function named fn on the service, and unmarshal the data it returns into result.
+// Result should be a pointer, or nil to ignore the returned data.
+//
+// The deadline of ctx, if any, is sent to the service, and canceling ctx abandons the call.
+// If the function returns an error, it is returned as an *Error.
+func (c *Client) Call(ctx context.Context, service, fn string, params, result interface{}) error {
+	errParams := erk.Params{"serviceName": service, "fnName": fn}
+	reqDetails := c.newRequestDetails(ctx, service, fn)
+
+	resp, err := c.post(ctx, reqDetails, params, errParams)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoded, err := wire.NewDecoder(resp.Body, c.DecoderOptions...).Decode()
+	if err != nil {
+		return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+	}
+
+	return c.decodeResponse(reqDetails.RequestID, decoded, result, errParams)
+}
+
+// post the request to the service. The caller closes the body of the response.
+func (c *Client) post(ctx context.Context, reqDetails *strand.RequestDetails, params interface{}, errParams erk.Params) (*http.Response, error) {
+	var body bytes.Buffer
+	if err := wire.NewEncoder(&body, c.encoderOptions()...).Encode(reqDetails, params); err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrEncodingRequest, errParams), err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+fnPath, &body)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrEncodingRequest, errParams), err)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrSendingRequest, errParams), err)
+	}
+
+	return resp, nil
+}
+
+// newRequestDetails for a call to the function, with the deadline of ctx.
+func (c *Client) newRequestDetails(ctx context.Context, service, fn string) *strand.RequestDetails {
+	reqDetails := &strand.RequestDetails{
+		RequestID:    c.newRequestID(),
+		ServiceName:  service,
+		FunctionName: fn,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		reqDetails.Deadline = deadline.UnixNano() / int64(time.Millisecond)
+	}
+
+	if c.Compression != wire.CompressionNone {
+		reqDetails.AcceptCompression = []wire.Compression{c.Compression}
+	}
+
+	return reqDetails
+}
+
+// encoderOptions for a request, compressing it once the service accepts the compression.
+func (c *Client) encoderOptions() []wire.EncoderOption {
+	encoderOptions := []wire.EncoderOption{wire.WithEncoding(c.encoding())}
+	if c.Checksum {
+		encoderOptions = append(encoderOptions, wire.WithChecksum())
+	}
+	if c.Compression != wire.CompressionNone && atomic.LoadInt32(&c.serviceAcceptsCompression) == 1 {
+		encoderOptions = append(encoderOptions, wire.WithCompression(c.Compression, c.compressionMinBytes()))
+	}
+
+	return encoderOptions
+}
+
+func (c *Client) decodeResponse(requestID string, decoded *wire.DecodeResult, result interface{}, errParams erk.Params) error {
+	var respDetails strand.ResponseDetails
+	if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &respDetails); err != nil {
+		return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+	}
+
+	return c.handleResponse(requestID, &respDetails, decoded, result, errParams)
+}
+
+// handleResponse returns the error in the response, or unmarshals its result.
+func (c *Client) handleResponse(requestID string, respDetails *strand.ResponseDetails, decoded *wire.DecodeResult, result interface{}, errParams erk.Params) error {
+	// Later requests can be compressed, once the service accepts the compression
+	for _, compression := range respDetails.AcceptCompression {
+		if compression == c.Compression && compression != wire.CompressionNone {
+			atomic.StoreInt32(&c.serviceAcceptsCompression, 1)
+		}
+	}
+
+	// Errors that occur before the service reads the request details have no request ID
+	if respDetails.RequestID != requestID && (respDetails.RequestID != "" || !respDetails.IsError) {
+		return erk.WithParams(ErrResponseRequestID, erk.Params{"requestID": requestID, "responseID": respDetails.RequestID})
+	}
+
+	if respDetails.IsError {
+		rawErr, err := toJSON(decoded.Encoding, decoded.RawParams)
+		if err != nil {
+			return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+		}
+
+		return newError(requestID, respDetails, rawErr)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, result); err != nil {
+		return erk.WrapAs(erk.WithParams(ErrDecodingResponseResult, errParams), err)
+	}
+
+	return nil
+}
+
+// toJSON converts raw params in the encoding to JSON, so errors always have a JSON Raw value.
+func toJSON(encoding wire.Encoding, rawParams []byte) ([]byte, error) {
+	if encoding == wire.EncodingJSON {
+		return rawParams, nil
+	}
+
+	var value interface{}
+	if err := encoding.UnmarshalParams(rawParams, &value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
+}
+
+func (c *Client) encoding() wire.Encoding {
+	if c.Encoding == 0 {
+		return wire.DefaultEncoding
+	}
+
+	return c.Encoding
+}
+
+func (c *Client) compressionMinBytes() int {
+	if c.CompressionMinBytes == 0 {
+		return wire.DefaultCompressionMinBytes
+	}
+
+	return c.CompressionMinBytes
+}
+
+func (c *Client) newRequestID() string {
+	if c.NewRequestID == nil {
+		return NewRequestID()
+	}
+
+	return c.NewRequestID()
+}
@@ -0,0 +1,256 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/client"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+type ErkTest struct{ erks.Default }
+
+var ErrTest = erk.New(ErkTest{}, "test error: {{.detail}}")
+
+type EchoParams struct {
+	Message string `json:"message"`
+}
+
+func newTestServer(received *strand.RequestDetails) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decoded, err := wire.NewDecoder(r.Body).Decode()
+		if err != nil {
+			panic(err)
+		}
+
+		var details strand.RequestDetails
+		if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &details); err != nil {
+			panic(err)
+		}
+		*received = details
+
+		// Respond in the encoding of the request
+		enc := wire.NewEncoder(w, wire.WithEncoding(decoded.Encoding))
+		switch details.FunctionName {
+		case "":
+			// Batches echo the calls named echo, and fail the others
+			var params []EchoParams
+			if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &params); err != nil {
+				panic(err)
+			}
+
+			respDetails := &strand.ResponseDetails{RequestID: details.RequestID}
+			results := make([]interface{}, len(details.Batch))
+			for i, call := range details.Batch {
+				respDetails.Batch = append(respDetails.Batch, strand.BatchResult{ID: call.ID, IsError: call.FunctionName != "echo"})
+				results[i] = params[i]
+				if call.FunctionName != "echo" {
+					results[i] = erk.Export(erk.WithParam(ErrTest, "detail", call.FunctionName))
+				}
+			}
+			err = enc.Encode(respDetails, results)
+
+		case "echo":
+			var params EchoParams
+			if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &params); err != nil {
+				panic(err)
+			}
+			err = enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID}, params)
+
+		case "erk-error":
+			err = enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID, IsError: true}, erk.Export(erk.WithParam(ErrTest, "detail", "abc")))
+
+		case "internal-error":
+			err = enc.EncodeWithJSONParams(&strand.ResponseDetails{IsError: true, IsInternalError: true}, []byte(`{"kind":"internal","message":"oops"}`))
+
+		case "string-error":
+			err = enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID, IsError: true}, "an error")
+
+		case "stream":
+			for i, message := range []string{"a", "b"} {
+				enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID, Sequence: uint64(i + 1)}, EchoParams{Message: message})
+			}
+			err = enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID, Sequence: 3, EndOfStream: true}, nil)
+
+		case "stream-error":
+			enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID, Sequence: 1}, EchoParams{Message: "a"})
+			err = enc.Encode(&strand.ResponseDetails{RequestID: details.RequestID, IsError: true, Sequence: 2, EndOfStream: true}, erk.Export(erk.WithParam(ErrTest, "detail", "abc")))
+
+		case "wrong-id":
+			err = enc.Encode(&strand.ResponseDetails{RequestID: "other"}, nil)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}))
+}
+
+func TestCall(t *testing.T) {
+	var received strand.RequestDetails
+	server := newTestServer(&received)
+	defer server.Close()
+
+	c := client.New(server.URL)
+	c.NewRequestID = func() string { return "my-id" }
+
+	t.Run("returns the result", func(t *testing.T) {
+		is := is.New(t)
+
+		var result EchoParams
+		err := c.Call(context.Background(), "my-service", "echo", &EchoParams{Message: "hi"}, &result)
+		is.NoErr(err)
+		is.Equal(result, EchoParams{Message: "hi"})
+		is.Equal(received, strand.RequestDetails{RequestID: "my-id", ServiceName: "my-service", FunctionName: "echo"})
+	})
+
+	t.Run("sends the deadline", func(t *testing.T) {
+		is := is.New(t)
+
+		deadline := time.Now().Add(time.Minute)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		is.NoErr(c.Call(ctx, "my-service", "echo", &EchoParams{}, nil))
+		is.Equal(received.Deadline, deadline.UnixNano()/int64(time.Millisecond))
+	})
+
+	t.Run("returns erk errors with their kind", func(t *testing.T) {
+		is := is.New(t)
+
+		err := c.Call(context.Background(), "my-service", "erk-error", nil, nil)
+		is.True(errors.Is(err, ErrTest))
+
+		var clientErr *client.Error
+		is.True(errors.As(err, &clientErr))
+		is.Equal(clientErr.Kind, erk.GetKindString(ErrTest))
+		is.Equal(clientErr.Message, "test error: abc")
+		is.Equal(clientErr.Params, erk.Params{"detail": "abc"})
+		is.Equal(clientErr.IsInternal, false)
+		is.Equal(clientErr.RequestID, "my-id")
+	})
+
+	t.Run("returns internal errors", func(t *testing.T) {
+		is := is.New(t)
+
+		err := c.Call(context.Background(), "my-service", "internal-error", nil, nil)
+
+		var clientErr *client.Error
+		is.True(errors.As(err, &clientErr))
+		is.Equal(clientErr.Kind, "internal")
+		is.Equal(clientErr.Error(), "oops")
+		is.Equal(clientErr.IsInternal, true)
+	})
+
+	t.Run("returns string errors", func(t *testing.T) {
+		is := is.New(t)
+
+		err := c.Call(context.Background(), "my-service", "string-error", nil, nil)
+		is.True(!errors.Is(err, ErrTest))
+
+		var clientErr *client.Error
+		is.True(errors.As(err, &clientErr))
+		is.Equal(clientErr.Kind, "")
+		is.Equal(clientErr.Error(), "an error")
+	})
+
+	t.Run("rejects mismatched request IDs", func(t *testing.T) {
+		is := is.New(t)
+
+		err := c.Call(context.Background(), "my-service", "wrong-id", nil, nil)
+		is.True(errors.Is(err, client.ErrResponseRequestID))
+	})
+
+	t.Run("with unencodable params", func(t *testing.T) {
+		is := is.New(t)
+
+		err := c.Call(context.Background(), "my-service", "echo", make(chan int), nil)
+		is.True(errors.Is(err, client.ErrEncodingRequest))
+	})
+
+	t.Run("with the MessagePack encoding", func(t *testing.T) {
+		is := is.New(t)
+
+		c := client.New(server.URL)
+		c.Encoding = wire.EncodingMsgPack
+
+		var result EchoParams
+		is.NoErr(c.Call(context.Background(), "my-service", "echo", &EchoParams{Message: "hi"}, &result))
+		is.Equal(result, EchoParams{Message: "hi"})
+
+		err := c.Call(context.Background(), "my-service", "erk-error", nil, nil)
+		is.True(errors.Is(err, ErrTest))
+
+		var clientErr *client.Error
+		is.True(errors.As(err, &clientErr))
+		is.Equal(clientErr.Message, "test error: abc")
+		is.Equal(clientErr.Params, erk.Params{"detail": "abc"})
+		is.Equal(string(clientErr.Raw), `{"kind":"`+erk.GetKindString(ErrTest)+`","message":"test error: abc","params":{"detail":"abc"}}`)
+	})
+
+	t.Run("with compression", func(t *testing.T) {
+		is := is.New(t)
+
+		// The service accepts compression, and compresses responses, like hoist services
+		var compressions []wire.Compression
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decoded, err := wire.NewDecoder(r.Body).Decode()
+			if err != nil {
+				panic(err)
+			}
+			compressions = append(compressions, decoded.Compression)
+
+			var details strand.RequestDetails
+			var params EchoParams
+			if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &details); err != nil {
+				panic(err)
+			}
+			if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &params); err != nil {
+				panic(err)
+			}
+
+			respDetails := &strand.ResponseDetails{RequestID: details.RequestID, AcceptCompression: wire.Compressions()}
+			if err := wire.NewEncoder(w, wire.WithCompression(details.AcceptCompression[0], 0)).Encode(respDetails, params); err != nil {
+				panic(err)
+			}
+		}))
+		defer server.Close()
+
+		c := client.New(server.URL)
+		c.Compression = wire.CompressionFlate
+		c.CompressionMinBytes = 16
+
+		message := strings.Repeat("compress me ", 100)
+		for i := 0; i < 2; i++ {
+			var result EchoParams
+			is.NoErr(c.Call(context.Background(), "my-service", "echo", &EchoParams{Message: message}, &result))
+			is.Equal(result, EchoParams{Message: message})
+		}
+
+		// The first request is not compressed, since the service has not declared it accepts compression yet
+		is.Equal(compressions, []wire.Compression{wire.CompressionNone, wire.CompressionFlate})
+	})
+
+	t.Run("with unreachable service", func(t *testing.T) {
+		is := is.New(t)
+
+		err := client.New("http://localhost:0").Call(context.Background(), "my-service", "echo", nil, nil)
+		is.True(errors.Is(err, client.ErrSendingRequest))
+	})
+}
+
+func TestNewRequestID(t *testing.T) {
+	is := is.New(t)
+
+	id := client.NewRequestID()
+	is.Equal(len(id), 32)
+	is.True(id != client.NewRequestID())
+}
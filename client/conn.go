@@ -0,0 +1,236 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+type ErkConnClosed struct{ erks.Default }
+
+var (
+	ErrDialing            = erk.New(ErkRequest{}, "could not connect to '{{.address}}': {{.err}}")
+	ErrDuplicateRequestID = erk.New(ErkRequest{}, "request ID '{{.requestID}}' is already in flight on the connection")
+	ErrConnClosed         = erk.New(ErkConnClosed{}, "connection to the service is closed")
+	ErrConnFailed         = erk.New(ErkConnClosed{}, "connection to the service failed: {{.err}}")
+)
+
+// DefaultMaxInFlight is how many calls a Conn sends before waiting for their responses,
+// unless Client.MaxInFlight is set.
+const DefaultMaxInFlight = 100
+
+// Conn calls functions over one persistent connection to a hoist service (see hoist.Service.ServeConns).
+// Calls run at once over the connection, and responses are matched to them by request ID, in any order.
+//
+// A Conn is safe for concurrent use. It uses the settings of the Client that created it.
+type Conn struct {
+	client *Client
+	conn   net.Conn
+	slots  chan struct{}
+
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	mu      sync.Mutex
+	pending map[string]chan *connResponse
+	err     error
+	closed  chan struct{}
+}
+
+// connResponse is a response read from the connection, passed to the call waiting for it.
+type connResponse struct {
+	details *strand.ResponseDetails
+	decoded *wire.DecodeResult
+}
+
+// Dial connects to the service, and returns a Conn to call its functions.
+// The network and address are passed to net.Dialer, for example "tcp" and "localhost:8081",
+// or "unix" and the path of a socket. Canceling ctx only stops connecting.
+func (c *Client) Dial(ctx context.Context, network, address string) (*Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParam(ErrDialing, "address", address), err)
+	}
+
+	return c.NewConn(conn), nil
+}
+
+// NewConn returns a Conn that calls functions over the open connection.
+// The connection is closed when the Conn is closed.
+func (c *Client) NewConn(conn net.Conn) *Conn {
+	maxInFlight := c.MaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = DefaultMaxInFlight
+	}
+
+	cn := &Conn{
+		client:  c,
+		conn:    conn,
+		slots:   make(chan struct{}, maxInFlight),
+		writer:  bufio.NewWriter(conn),
+		pending: make(map[string]chan *connResponse),
+		closed:  make(chan struct{}),
+	}
+
+	go cn.read()
+	return cn
+}
+
+// Call the function named fn on the service, and unmarshal the data it returns into result, like Client.Call.
+//
+// If the limit of calls in flight is reached, Call waits for one of them to finish before sending.
+// If the connection closes before the response arrives, an ErkConnClosed error is returned.
+func (c *Conn) Call(ctx context.Context, service, fn string, params, result interface{}) error {
+	errParams := erk.Params{"serviceName": service, "fnName": fn}
+
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return erk.WrapAs(erk.WithParams(ErrSendingRequest, errParams), ctx.Err())
+	case <-c.closed:
+		return c.closedErr()
+	}
+
+	reqDetails := c.client.newRequestDetails(ctx, service, fn)
+	responses := make(chan *connResponse, 1)
+	if err := c.addPending(reqDetails.RequestID, responses); err != nil {
+		return err
+	}
+	defer c.removePending(reqDetails.RequestID)
+
+	if err := c.send(reqDetails, params); err != nil {
+		if erk.IsKind(err, wire.ErkUnableToWrite{}) {
+			return erk.WrapAs(erk.WithParams(ErrSendingRequest, errParams), err)
+		}
+
+		return erk.WrapAs(erk.WithParams(ErrEncodingRequest, errParams), err)
+	}
+
+	select {
+	case resp := <-responses:
+		return c.client.handleResponse(reqDetails.RequestID, resp.details, resp.decoded, result, errParams)
+	case <-ctx.Done():
+		return erk.WrapAs(erk.WithParams(ErrSendingRequest, errParams), ctx.Err())
+	case <-c.closed:
+		// The response may have arrived just before the connection closed
+		select {
+		case resp := <-responses:
+			return c.client.handleResponse(reqDetails.RequestID, resp.details, resp.decoded, result, errParams)
+		default:
+			return c.closedErr()
+		}
+	}
+}
+
+// Close the connection. Calls waiting for responses return ErrConnClosed.
+func (c *Conn) Close() error {
+	return c.closeWith(ErrConnClosed)
+}
+
+// send the request, closing the connection if it cannot be written.
+func (c *Conn) send(reqDetails *strand.RequestDetails, params interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := wire.NewEncoder(c.writer, c.client.encoderOptions()...).Encode(reqDetails, params); err != nil {
+		return err
+	}
+
+	if err := c.writer.Flush(); err != nil {
+		c.closeWith(erk.WrapAs(ErrConnFailed, err))
+		return erk.WrapAs(wire.ErrUnableToWrite, err)
+	}
+
+	return nil
+}
+
+// read responses, and pass them to the calls waiting for them, until the connection closes.
+func (c *Conn) read() {
+	decoder := wire.NewDecoder(c.conn, c.client.DecoderOptions...)
+	for {
+		decoded, err := decoder.Decode()
+		if err != nil {
+			c.closeWith(erk.WrapAs(ErrConnFailed, err))
+			return
+		}
+
+		var respDetails strand.ResponseDetails
+		if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &respDetails); err != nil {
+			c.closeWith(erk.WrapAs(ErrConnFailed, err))
+			return
+		}
+
+		// Errors without a request ID are about the connection, such as a frame the service could not decode
+		if respDetails.RequestID == "" && respDetails.IsError {
+			rawErr, err := toJSON(decoded.Encoding, decoded.RawParams)
+			if err == nil {
+				err = newError("", &respDetails, rawErr)
+			}
+
+			c.closeWith(erk.WrapAs(ErrConnFailed, err))
+			return
+		}
+
+		// Responses to abandoned calls are dropped
+		c.mu.Lock()
+		responses := c.pending[respDetails.RequestID]
+		delete(c.pending, respDetails.RequestID)
+		c.mu.Unlock()
+
+		if responses != nil {
+			responses <- &connResponse{details: &respDetails, decoded: decoded}
+		}
+	}
+}
+
+func (c *Conn) addPending(requestID string, responses chan *connResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	if c.pending[requestID] != nil {
+		return erk.WithParam(ErrDuplicateRequestID, "requestID", requestID)
+	}
+
+	c.pending[requestID] = responses
+	return nil
+}
+
+func (c *Conn) removePending(requestID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, requestID)
+}
+
+// closeWith closes the connection the first time it is called, so calls waiting for responses return err.
+func (c *Conn) closeWith(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil
+	}
+
+	c.err = err
+	close(c.closed)
+	return c.conn.Close()
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
@@ -0,0 +1,156 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/client"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+type connRequest struct {
+	details strand.RequestDetails
+	params  EchoParams
+}
+
+// newTestConn returns a Conn to a test service, which sends the requests it reads on the channel.
+func newTestConn() (*client.Conn, net.Conn, chan connRequest) {
+	var id int32
+	c := client.New("")
+	c.NewRequestID = func() string { return strconv.Itoa(int(atomic.AddInt32(&id, 1))) }
+
+	conn, serviceConn := net.Pipe()
+	requests := make(chan connRequest)
+	go func() {
+		decoder := wire.NewDecoder(serviceConn)
+		for {
+			decoded, err := decoder.Decode()
+			if err != nil {
+				close(requests)
+				return
+			}
+
+			var req connRequest
+			decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &req.details)
+			decoded.Encoding.UnmarshalParams(decoded.RawParams, &req.params)
+			requests <- req
+		}
+	}()
+
+	return c.NewConn(conn), serviceConn, requests
+}
+
+func TestConnCall(t *testing.T) {
+	t.Run("matches responses to calls by request ID", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, serviceConn, requests := newTestConn()
+		defer conn.Close()
+
+		results := make(chan EchoParams, 2)
+		for _, message := range []string{"a", "b"} {
+			message := message
+			go func() {
+				var result EchoParams
+				is.NoErr(conn.Call(context.Background(), "my-service", "echo", &EchoParams{Message: message}, &result))
+				results <- result
+			}()
+		}
+
+		// Respond in the opposite order
+		first, second := <-requests, <-requests
+		enc := wire.NewEncoder(serviceConn)
+		is.NoErr(enc.Encode(&strand.ResponseDetails{RequestID: second.details.RequestID}, second.params))
+		is.Equal(<-results, second.params)
+		is.NoErr(enc.Encode(&strand.ResponseDetails{RequestID: first.details.RequestID}, first.params))
+		is.Equal(<-results, first.params)
+	})
+
+	t.Run("returns errors of the call", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, serviceConn, requests := newTestConn()
+		defer conn.Close()
+
+		go func() {
+			req := <-requests
+			exported := erk.Export(erk.WithParam(ErrTest, "detail", "abc"))
+			wire.NewEncoder(serviceConn).Encode(&strand.ResponseDetails{RequestID: req.details.RequestID, IsError: true}, exported)
+		}()
+
+		err := conn.Call(context.Background(), "my-service", "erk-error", nil, nil)
+		is.True(errors.Is(err, ErrTest))
+	})
+
+	t.Run("abandoned calls ignore late responses", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, serviceConn, requests := newTestConn()
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-requests
+			cancel()
+		}()
+
+		err := conn.Call(ctx, "my-service", "echo", &EchoParams{}, nil)
+		is.True(errors.Is(err, client.ErrSendingRequest))
+		is.True(errors.Is(err, context.Canceled))
+
+		go func() {
+			req := <-requests
+			enc := wire.NewEncoder(serviceConn)
+			enc.Encode(&strand.ResponseDetails{RequestID: "1"}, EchoParams{Message: "late"})
+			enc.Encode(&strand.ResponseDetails{RequestID: req.details.RequestID}, req.params)
+		}()
+
+		var result EchoParams
+		is.NoErr(conn.Call(context.Background(), "my-service", "echo", &EchoParams{Message: "hi"}, &result))
+		is.Equal(result, EchoParams{Message: "hi"})
+	})
+
+	t.Run("errors without a request ID fail the connection", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, serviceConn, requests := newTestConn()
+		defer conn.Close()
+
+		go func() {
+			<-requests
+			wire.NewEncoder(serviceConn).EncodeWithJSONParams(&strand.ResponseDetails{IsError: true, IsInternalError: true}, []byte(`{"kind":"internal","message":"oops"}`))
+		}()
+
+		err := conn.Call(context.Background(), "my-service", "echo", &EchoParams{}, nil)
+		is.True(errors.Is(err, client.ErrConnFailed))
+
+		var clientErr *client.Error
+		is.True(errors.As(err, &clientErr))
+		is.Equal(clientErr.Message, "oops")
+
+		err = conn.Call(context.Background(), "my-service", "echo", &EchoParams{}, nil)
+		is.True(errors.Is(err, client.ErrConnFailed))
+	})
+
+	t.Run("closing fails calls in flight", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, _, requests := newTestConn()
+
+		go func() {
+			<-requests
+			conn.Close()
+		}()
+
+		err := conn.Call(context.Background(), "my-service", "echo", &EchoParams{}, nil)
+		is.True(errors.Is(err, client.ErrConnClosed))
+		is.Equal(erk.GetKindString(err), erk.GetKindString(client.ErrConnClosed))
+	})
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/strand"
+)
+
+// Error returned by a function on a hoist service.
+type Error struct {
+	RequestID string
+
+	// Kind, Message, and Params of the exported error.
+	// Errors that were not erk errors only have a Message, or only Raw if they were not strings.
+	Kind    string
+	Message string
+	Params  erk.Params
+	Raw     json.RawMessage
+
+	// IsInternal is true when the error was caused by the service, instead of the function.
+	IsInternal bool
+}
+
+// Error satisfies the error interface.
+var _ error = &Error{}
+
+func newError(requestID string, details *strand.ResponseDetails, rawParams []byte) *Error {
+	e := &Error{
+		RequestID:  requestID,
+		Raw:        json.RawMessage(rawParams),
+		IsInternal: details.IsInternalError,
+	}
+
+	var exported erk.BaseExport
+	if err := json.Unmarshal(rawParams, &exported); err == nil && exported.Kind != "" {
+		e.Kind = exported.Kind
+		e.Message = exported.Message
+		e.Params = exported.Params
+		return e
+	}
+
+	var message string
+	if err := json.Unmarshal(rawParams, &message); err == nil {
+		e.Message = message
+	}
+
+	return e
+}
+
+// Error returns the message of the error, or the raw error if there is no message.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return string(e.Raw)
+	}
+
+	return e.Message
+}
+
+// Is reports whether target is an erk error with the same kind as the remote error.
+// This allows checking for error kinds exported by the service, using errors.Is.
+func (e *Error) Is(target error) bool {
+	return e.Kind != "" && erk.GetKindString(target) == e.Kind
+}
+
+// Export the error, so when a hoist function returns it, the original kind is passed to the caller.
+func (e *Error) Export() erk.ExportedErkable {
+	return &erk.BaseExport{
+		Kind:    e.Kind,
+		Message: e.Message,
+		Params:  e.Params,
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+// Stream calls the function named fn on the service, and calls handle with each item the function streams,
+// as it arrives. Handle receives an unmarshal function, which unmarshals the item into a pointer.
+// If handle returns an error, the call is abandoned, and the error is returned.
+//
+// Functions that do not stream call handle once, with the data they return.
+// If the function returns an error, including after streaming some items, it is returned as an *Error.
+func (c *Client) Stream(ctx context.Context, service, fn string, params interface{}, handle func(unmarshal func(item interface{}) error) error) error {
+	errParams := erk.Params{"serviceName": service, "fnName": fn}
+	reqDetails := c.newRequestDetails(ctx, service, fn)
+	reqDetails.AcceptStream = true
+
+	// Canceling the request closes the response body, if handle returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resp, err := c.post(ctx, reqDetails, params, errParams)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := wire.NewDecoder(resp.Body, c.DecoderOptions...)
+	for {
+		decoded, err := decoder.Decode()
+		if err != nil {
+			return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+		}
+
+		var respDetails strand.ResponseDetails
+		if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &respDetails); err != nil {
+			return erk.WrapAs(erk.WithParams(ErrDecodingResponse, errParams), err)
+		}
+
+		if err := c.handleResponse(reqDetails.RequestID, &respDetails, decoded, nil, errParams); err != nil {
+			return err
+		}
+
+		// The final frame of a stream has no data
+		if respDetails.EndOfStream {
+			return nil
+		}
+
+		err = handle(func(item interface{}) error {
+			return c.handleResponse(reqDetails.RequestID, &respDetails, decoded, item, errParams)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Functions that do not stream respond with a single frame
+		if respDetails.Sequence == 0 {
+			return nil
+		}
+	}
+}
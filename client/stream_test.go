@@ -0,0 +1,67 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hoistup/hoist-go/client"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/matryer/is"
+)
+
+func TestStream(t *testing.T) {
+	var received strand.RequestDetails
+	server := newTestServer(&received)
+	defer server.Close()
+
+	c := client.New(server.URL)
+	c.NewRequestID = func() string { return "my-id" }
+
+	collect := func(items *[]string) func(unmarshal func(item interface{}) error) error {
+		return func(unmarshal func(item interface{}) error) error {
+			var item EchoParams
+			if err := unmarshal(&item); err != nil {
+				return err
+			}
+			*items = append(*items, item.Message)
+			return nil
+		}
+	}
+
+	t.Run("handles each item", func(t *testing.T) {
+		is := is.New(t)
+
+		var items []string
+		is.NoErr(c.Stream(context.Background(), "my-service", "stream", nil, collect(&items)))
+		is.Equal(items, []string{"a", "b"})
+		is.True(received.AcceptStream)
+	})
+
+	t.Run("returns the error that ends the stream", func(t *testing.T) {
+		is := is.New(t)
+
+		var items []string
+		err := c.Stream(context.Background(), "my-service", "stream-error", nil, collect(&items))
+		is.True(errors.Is(err, ErrTest))
+		is.Equal(items, []string{"a"})
+	})
+
+	t.Run("handles the result of functions that do not stream", func(t *testing.T) {
+		is := is.New(t)
+
+		var items []string
+		is.NoErr(c.Stream(context.Background(), "my-service", "echo", &EchoParams{Message: "hi"}, collect(&items)))
+		is.Equal(items, []string{"hi"})
+	})
+
+	t.Run("returns the error of handle", func(t *testing.T) {
+		is := is.New(t)
+
+		handleErr := errors.New("stop")
+		err := c.Stream(context.Background(), "my-service", "stream", nil, func(unmarshal func(item interface{}) error) error {
+			return handleErr
+		})
+		is.Equal(err, handleErr)
+	})
+}
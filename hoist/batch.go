@@ -0,0 +1,138 @@
+package hoist
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/msgpack"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+var (
+	ErrBatchParamsInvalid = erk.New(ErkBadRequest{}, "batch params must be a list containing the params of each call: {{.err}}")
+	ErrBatchParamsCount   = erk.New(ErkBadRequest{}, "batch has {{.calls}} calls, but params for {{.params}}")
+	ErrBatchDuplicateID   = erk.New(ErkBadRequest{}, "batch has more than one call with ID '{{.id}}'")
+)
+
+// DefaultMaxBatchCalls is how many calls of each batch request run at once, unless WithMaxBatchCalls is provided.
+const DefaultMaxBatchCalls = 10
+
+// serveBatch runs the calls of a batch request, and writes their results in one frame.
+// Calls fail independently, so the whole batch only fails if it is invalid.
+func (s *Service) serveBatch(ctx context.Context, details *strand.RequestDetails, decoded *wire.DecodeResult, write frameWriter) {
+	results, data, err := s.callBatch(ctx, details, decoded)
+	if err != nil {
+		s.countDecodeError()
+	}
+
+	write(func(w io.Writer) {
+		if err == nil {
+			respDetails := &strand.ResponseDetails{RequestID: details.RequestID, Batch: results}
+
+			encoder := wire.NewEncoder(w, s.responseEncoderOptions(details, decoded, respDetails)...)
+			if err = encoder.Encode(respDetails, data); err == nil {
+				return
+			}
+		}
+
+		s.writeEventError(w, details, decoded, 0, err)
+	}, false)
+}
+
+// callBatch runs the calls of a batch request concurrently, up to the limit set by WithMaxBatchCalls,
+// and returns the result and data of each call, in order. The data of failed calls is their exported error.
+//
+// Each call is made with CallWithDetails, so its params are converted to JSON. Streaming functions return
+// their items in a list.
+func (s *Service) callBatch(ctx context.Context, details *strand.RequestDetails, decoded *wire.DecodeResult) ([]strand.BatchResult, []interface{}, error) {
+	var rawParams []json.RawMessage
+	if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &rawParams); err != nil {
+		return nil, nil, erk.WrapAs(ErrBatchParamsInvalid, err)
+	}
+	if len(rawParams) != len(details.Batch) {
+		return nil, nil, erk.WithParams(ErrBatchParamsCount, erk.Params{"calls": len(details.Batch), "params": len(rawParams)})
+	}
+
+	ids := make(map[string]struct{}, len(details.Batch))
+	for _, call := range details.Batch {
+		if _, ok := ids[call.ID]; ok {
+			return nil, nil, erk.WithParam(ErrBatchDuplicateID, "id", call.ID)
+		}
+		ids[call.ID] = struct{}{}
+	}
+
+	results := make([]strand.BatchResult, len(details.Batch))
+	data := make([]interface{}, len(details.Batch))
+	slots := make(chan struct{}, s.options.maxBatchCalls)
+	var wg sync.WaitGroup
+
+	for i := range details.Batch {
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			results[i], data[i] = s.callInBatch(ctx, details, i, rawParams[i], decoded.Encoding)
+		}(i)
+	}
+
+	wg.Wait()
+	return results, data, nil
+}
+
+// callInBatch makes the call at index i of the batch request, and returns its result and data.
+func (s *Service) callInBatch(ctx context.Context, details *strand.RequestDetails, i int, rawParams json.RawMessage, encoding wire.Encoding) (strand.BatchResult, interface{}) {
+	call := details.Batch[i]
+	result := strand.BatchResult{ID: call.ID}
+
+	// The call shares the details of the batch, such as its deadline, but has its own request ID
+	callDetails := *details
+	callDetails.RequestID = details.RequestID + "/" + call.ID
+	callDetails.FunctionName = call.FunctionName
+	callDetails.AcceptStream = false
+	callDetails.Batch = nil
+
+	// Null params can be decoded as empty raw params by some encodings
+	if len(rawParams) == 0 {
+		rawParams = json.RawMessage("null")
+	}
+
+	data, err := s.CallWithDetails(ctx, &callDetails, rawParams)
+
+	// Data that cannot be encoded would fail the whole batch, so it fails the call instead.
+	// Data that can is kept encoded, so it is not encoded again with the batch.
+	if err == nil {
+		var encoded []byte
+		if encoded, err = encoding.MarshalParams(data); err == nil {
+			data = rawData(encoding, encoded, data)
+		}
+	}
+
+	if err != nil {
+		data, result.IsInternalError = s.exportEventError(err)
+		result.IsError = true
+	}
+
+	return result, data
+}
+
+// rawData returns the encoded data as a raw message the encoding writes as is.
+// Encodings without a raw message type encode the data again.
+func rawData(encoding wire.Encoding, encoded []byte, data interface{}) interface{} {
+	switch encoding {
+	case wire.EncodingJSON:
+		return json.RawMessage(encoded)
+	case wire.EncodingMsgPack:
+		return msgpack.RawMessage(encoded)
+	}
+
+	return data
+}
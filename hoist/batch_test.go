@@ -0,0 +1,200 @@
+package hoist_test
+
+import (
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+// BatchContext is filled with the request ID of each call.
+type BatchContext struct {
+	RequestID string `hook:"id"`
+}
+
+func TestBatch(t *testing.T) {
+	var running, maxRunning int32
+	fns := map[string]interface{}{
+		"echo": func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, n)
+			}
+			defer atomic.AddInt32(&running, -1)
+
+			time.Sleep(10 * time.Millisecond)
+			return params, nil
+		},
+		"erk": func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			return nil, ErrErkError
+		},
+		"unencodable": func(ctx *TestContext, params *TestParams) (chan int, error) {
+			return make(chan int), nil
+		},
+	}
+
+	call := func(t *testing.T, s *hoist.Service, encoding wire.Encoding, batch []strand.BatchCall, params interface{}) (*strand.ResponseDetails, []json.RawMessage) {
+		t.Helper()
+
+		conn, serverConn := net.Pipe()
+		defer conn.Close()
+		go s.ServeConn(serverConn)
+
+		go func() {
+			details := &strand.RequestDetails{RequestID: reqID, Batch: batch}
+			wire.NewEncoder(conn, wire.WithEncoding(encoding)).Encode(details, params)
+		}()
+
+		decoded, err := wire.NewDecoder(conn).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var details strand.ResponseDetails
+		var results []json.RawMessage
+		if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &details); err != nil {
+			t.Fatal(err)
+		}
+		if !details.IsError {
+			if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &results); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			results = []json.RawMessage{decoded.RawParams}
+		}
+
+		return &details, results
+	}
+
+	batch := []strand.BatchCall{
+		{ID: "a", FunctionName: "echo"},
+		{ID: "b", FunctionName: "erk"},
+		{ID: "c", FunctionName: "missing"},
+		{ID: "d", FunctionName: "unencodable"},
+	}
+	params := []*TestParams{{Message: "hi"}, nil, nil, nil}
+
+	table := []struct {
+		Name     string
+		Encoding wire.Encoding
+	}{
+		{Name: "with JSON request", Encoding: wire.EncodingJSON},
+		{Name: "with MessagePack request", Encoding: wire.EncodingMsgPack},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			details, results := call(t, newTestService(fns), entry.Encoding, batch, params)
+			is.Equal(details, &strand.ResponseDetails{
+				RequestID: reqID,
+				Batch: []strand.BatchResult{
+					{ID: "a"},
+					{ID: "b", IsError: true},
+					{ID: "c", IsError: true, IsInternalError: true},
+					{ID: "d", IsError: true, IsInternalError: true},
+				},
+			})
+			is.Equal(len(results), 4)
+
+			var echoed TestParams
+			is.NoErr(json.Unmarshal(results[0], &echoed))
+			is.Equal(echoed, TestParams{Message: "hi"})
+
+			var exported erk.BaseExport
+			is.NoErr(json.Unmarshal(results[1], &exported))
+			is.Equal(exported.Kind, erk.GetKindString(ErrErkError))
+			is.NoErr(json.Unmarshal(results[2], &exported))
+			is.Equal(exported.Kind, erk.GetKindString(hoist.ErrFunctionNotFound))
+		})
+	}
+
+	t.Run("runs calls concurrently up to the limit", func(t *testing.T) {
+		is := is.New(t)
+
+		atomic.StoreInt32(&maxRunning, 0)
+		var batch []strand.BatchCall
+		var params []*TestParams
+		for _, id := range []string{"1", "2", "3", "4", "5"} {
+			batch = append(batch, strand.BatchCall{ID: id, FunctionName: "echo"})
+			params = append(params, &TestParams{Message: id})
+		}
+
+		details, results := call(t, newTestService(fns, hoist.WithMaxBatchCalls(2)), wire.EncodingJSON, batch, params)
+		is.Equal(len(details.Batch), 5)
+		is.Equal(len(results), 5)
+		is.Equal(atomic.LoadInt32(&maxRunning), int32(2))
+	})
+
+	t.Run("gives each call its own request ID", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("abc")
+		s.HookTag("id", func(req *hoist.Request) (string, error) {
+			return req.Details.RequestID, nil
+		})
+		s.RegisterAs("id", func(ctx *BatchContext, params *TestParams) (*TestParams, error) {
+			return &TestParams{Message: ctx.RequestID}, nil
+		})
+		is.Equal(len(s.Errors()), 0)
+
+		batch := []strand.BatchCall{{ID: "a", FunctionName: "id"}, {ID: "b", FunctionName: "id"}}
+		details, results := call(t, s, wire.EncodingMsgPack, batch, []*TestParams{nil, nil})
+		is.Equal(details.RequestID, reqID)
+		is.Equal(len(results), 2)
+
+		for i, id := range []string{"a", "b"} {
+			var result TestParams
+			is.NoErr(json.Unmarshal(results[i], &result))
+			is.Equal(result.Message, reqID+"/"+id)
+		}
+	})
+
+	invalidTable := []struct {
+		Name          string
+		Batch         []strand.BatchCall
+		Params        interface{}
+		ExpectedError error
+	}{
+		{
+			Name:          "with params that are not a list",
+			Batch:         batch[:1],
+			Params:        &TestParams{},
+			ExpectedError: hoist.ErrBatchParamsInvalid,
+		},
+		{
+			Name:          "with params for a different number of calls",
+			Batch:         batch[:2],
+			Params:        params[:1],
+			ExpectedError: hoist.ErrBatchParamsCount,
+		},
+		{
+			Name:          "with duplicate call IDs",
+			Batch:         []strand.BatchCall{{ID: "a", FunctionName: "echo"}, {ID: "a", FunctionName: "echo"}},
+			Params:        params[:2],
+			ExpectedError: hoist.ErrBatchDuplicateID,
+		},
+	}
+
+	for _, entry := range invalidTable {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			details, results := call(t, newTestService(fns), wire.EncodingJSON, entry.Batch, entry.Params)
+			is.Equal(details, &errRespDetailsInternalReqID)
+
+			var exported erk.BaseExport
+			is.NoErr(json.Unmarshal(results[0], &exported))
+			is.Equal(exported.Kind, erk.GetKindString(entry.ExpectedError))
+		})
+	}
+}
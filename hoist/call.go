@@ -1,30 +1,113 @@
 package hoist
 
 import (
+	"context"
+	"time"
+
 	"github.com/JosiahWitt/erk"
 	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
 )
 
-type ErkFunctionNotFound struct{ erks.Default }
+type (
+	ErkFunctionNotFound struct{ erks.Default }
+	ErkCallCanceled     struct{ erks.Default }
+)
 
 var (
 	ErrFunctionNotFound   = erk.New(ErkFunctionNotFound{}, "service '{{.serviceName}}' does not have function '{{.fnName}}'")
 	ErrFunctionCallFailed = erk.New(ErkFunctionCall{}, "service '{{.serviceName}}': error while calling function '{{.fnName}}': {{.err}}")
+	ErrCallCanceled       = erk.New(ErkCallCanceled{}, "service '{{.serviceName}}': call to function '{{.fnName}}' was canceled before it started: {{.err}}")
 )
 
 // Call a function, providing the function name and JSON encoded rawParams.
 func (s *Service) Call(fnName string, rawParams []byte) (interface{}, error) {
+	return s.CallContext(context.Background(), fnName, rawParams)
+}
+
+// CallContext calls a function, providing the function name and JSON encoded rawParams.
+// The ctx is provided to functions that accept a context.Context.
+func (s *Service) CallContext(ctx context.Context, fnName string, rawParams []byte) (interface{}, error) {
+	return s.CallWithDetails(ctx, &strand.RequestDetails{ServiceName: s.name, FunctionName: fnName}, rawParams)
+}
+
+// CallWithDetails calls the function named in the request details, providing JSON encoded rawParams.
+// The details are made available to hooks.
+//
+// If the details contain a deadline, the ctx provided to the function expires at that deadline.
+func (s *Service) CallWithDetails(ctx context.Context, details *strand.RequestDetails, rawParams []byte) (interface{}, error) {
+	return s.CallWithEncoding(ctx, details, wire.EncodingJSON, rawParams)
+}
+
+// CallWithEncoding calls the function named in the request details, providing rawParams in the wire encoding.
+// The returned data is not encoded, so it can be encoded in any encoding.
+//
+// If the details contain a deadline, the ctx provided to the function expires at that deadline.
+// Functions that stream return their items in a list.
+func (s *Service) CallWithEncoding(ctx context.Context, details *strand.RequestDetails, encoding wire.Encoding, rawParams []byte) (interface{}, error) {
+	return s.CallStream(ctx, details, encoding, rawParams, nil)
+}
+
+// CallStream calls the function like CallWithEncoding, passing each item streamed by the function to send
+// (see Sender). The returned data is nil when the function streams, unless send is nil,
+// in which case the items are returned in a list. Errors returned by send are returned to the function.
+func (s *Service) CallStream(ctx context.Context, details *strand.RequestDetails, encoding wire.Encoding, rawParams []byte, send SendFunc) (interface{}, error) {
+	if details.Deadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, time.Unix(0, details.Deadline*int64(time.Millisecond)))
+		defer cancel()
+	}
+
+	req := &Request{
+		Context:      ctx,
+		ServiceName:  s.name,
+		FunctionName: details.FunctionName,
+		Details:      *details,
+		Encoding:     encoding,
+		stream:       &stream{send: send},
+	}
+	defer req.stream.finish()
+
+	return s.call(req, rawParams)
+}
+
+func (s *Service) call(req *Request, rawParams []byte) (data interface{}, err error) {
 	s.mu.RLock()
-	fn, ok := s.funcs[fnName]
+	fn, ok := s.funcs[req.FunctionName]
+	s.mu.RUnlock()
 	if !ok {
-		return nil, erk.WithParams(ErrFunctionNotFound, erk.Params{"serviceName": s.name, "fnName": fnName})
+		s.countNotFound()
+		return nil, erk.WithParams(ErrFunctionNotFound, erk.Params{"serviceName": s.name, "fnName": req.FunctionName})
 	}
-	s.mu.RUnlock()
 
-	data, err := fn(rawParams)
+	start := time.Now()
+	defer func() {
+		s.metrics.observe(req.FunctionName, s.callOutcome(err), time.Since(start))
+	}()
+
+	// Track the call, so Shutdown can wait for it
+	done, err := s.startCall()
 	if err != nil {
-		return nil, erk.WrapAs(erk.WithParams(ErrFunctionCallFailed, erk.Params{"serviceName": s.name, "fnName": fnName}), err)
+		return nil, err
+	}
+	defer done()
+
+	// Don't start calls that can no longer finish
+	if err := req.Context.Err(); err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrCallCanceled, erk.Params{"serviceName": s.name, "fnName": req.FunctionName}), err)
+	}
+
+	data, err = s.invoke(req, fn, rawParams)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrFunctionCallFailed, erk.Params{"serviceName": s.name, "fnName": req.FunctionName}), err)
 	}
 
 	return data, nil
 }
+
+// invoke the function through the interceptors, recovering from panics in either.
+func (s *Service) invoke(req *Request, fn *function, rawParams []byte) (data interface{}, err error) {
+	defer recoverPanic(req.FunctionName, &err)
+	return s.chain(req.FunctionName, recoverInvoker(fn.call))(req, rawParams)
+}
@@ -1,10 +1,13 @@
 package hoist_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
 	"github.com/matryer/is"
 )
 
@@ -44,6 +47,46 @@ func TestCall(t *testing.T) {
 			},
 			ExpectedError: nil,
 		},
+		{
+			Name: "valid call with only params",
+			Before: func(s *hoist.Service) {
+				type MyParams struct {
+					Abc string
+				}
+
+				s.RegisterAs("myFunc", func(params MyParams) (*MyReturn, error) {
+					return &MyReturn{MyVal: params.Abc + "?"}, nil
+				})
+			},
+			FnName:        "myFunc",
+			RawParams:     `{"abc": "hi"}`,
+			ExpectedData:  &MyReturn{MyVal: "hi?"},
+			ExpectedError: nil,
+		},
+		{
+			Name: "valid call without params or data",
+			Before: func(s *hoist.Service) {
+				s.RegisterAs("myFunc", func(ctx context.Context) error {
+					return ctx.Err()
+				})
+			},
+			FnName:        "myFunc",
+			RawParams:     `not even JSON`,
+			ExpectedData:  nil,
+			ExpectedError: nil,
+		},
+		{
+			Name: "function without params or data returns an error",
+			Before: func(s *hoist.Service) {
+				s.RegisterAs("myFunc", func() error {
+					return myErr
+				})
+			},
+			FnName:        "myFunc",
+			RawParams:     `{}`,
+			ExpectedData:  nil,
+			ExpectedError: myErr,
+		},
 		{
 			Name: "function not found",
 			Before: func(s *hoist.Service) {
@@ -102,3 +145,79 @@ func TestCall(t *testing.T) {
 		})
 	}
 }
+
+type EmbeddedContext struct {
+	context.Context
+}
+
+func TestCallContext(t *testing.T) {
+	const serviceName = "myService"
+
+	t.Run("function receives context.Context", func(t *testing.T) {
+		is := is.New(t)
+
+		type ctxKey struct{}
+		s := hoist.NewService(serviceName)
+		s.RegisterAs("myFunc", func(ctx context.Context, params *MyParams) (string, error) {
+			return ctx.Value(ctxKey{}).(string), nil
+		})
+		is.Equal(len(s.Errors()), 0)
+
+		ctx := context.WithValue(context.Background(), ctxKey{}, "hello")
+		data, err := s.CallContext(ctx, "myFunc", []byte(`{}`))
+		is.NoErr(err)
+		is.Equal(data, "hello")
+	})
+
+	t.Run("function receives struct embedding context.Context", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService(serviceName)
+		s.RegisterAs("myFunc", func(ctx *EmbeddedContext, params *MyParams) (bool, error) {
+			_, hasDeadline := ctx.Deadline()
+			return hasDeadline, nil
+		})
+		is.Equal(len(s.Errors()), 0)
+
+		details := &strand.RequestDetails{
+			FunctionName: "myFunc",
+			Deadline:     time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond),
+		}
+		data, err := s.CallWithDetails(context.Background(), details, []byte(`{}`))
+		is.NoErr(err)
+		is.Equal(data, true)
+	})
+
+	t.Run("expired deadline", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService(serviceName)
+		s.RegisterAs("myFunc", func(ctx context.Context, params *MyParams) (*MyData, error) {
+			return nil, nil
+		})
+
+		details := &strand.RequestDetails{
+			FunctionName: "myFunc",
+			Deadline:     time.Now().Add(-time.Minute).UnixNano() / int64(time.Millisecond),
+		}
+		_, err := s.CallWithDetails(context.Background(), details, []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrCallCanceled))
+		is.True(errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("canceled during call", func(t *testing.T) {
+		is := is.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s := hoist.NewService(serviceName)
+		s.RegisterAs("myFunc", func(ctx context.Context, params *MyParams) (*MyData, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		_, err := s.CallContext(ctx, "myFunc", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrFunctionCallFailed))
+		is.True(errors.Is(err, context.Canceled))
+	})
+}
@@ -0,0 +1,242 @@
+package hoist
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/JosiahWitt/erk/erg"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+// DefaultMaxConnCalls is how many calls each persistent connection runs at once, unless WithMaxConnCalls is provided.
+const DefaultMaxConnCalls = 100
+
+// acceptRetryDelay is how long ServeConns waits after a temporary error accepting a connection.
+const acceptRetryDelay = 5 * time.Millisecond
+
+// ServeConns serves calls over persistent connections accepted from the listener, until the service shuts down.
+// Each connection is served with ServeConn, so a caller can run many calls at once over one connection,
+// which avoids the overhead of an HTTP request per call. Use a TCP or Unix socket listener, for example:
+//  listener, err := net.Listen("unix", "/tmp/service.sock")
+//  ...
+//  go service.ServeConns(listener)
+//  err = service.ServeContext(ctx)
+//
+// The listener is closed when the service shuts down, and ServeConns returns nil.
+func (s *Service) ServeConns(listener net.Listener) error {
+	if errs := s.Errors(); len(errs) > 0 {
+		return erg.NewAs(ErrInitializing, errs...)
+	}
+
+	if !s.trackListener(listener, true) {
+		listener.Close()
+		return nil
+	}
+	defer s.trackListener(listener, false)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isShuttingDown() {
+				return nil
+			}
+
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				time.Sleep(acceptRetryDelay)
+				continue
+			}
+
+			return err
+		}
+
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves calls over one persistent connection, until the caller closes it or the service shuts down.
+// The connection is closed when ServeConn returns.
+//
+// The caller writes wire frames back to back, each containing one call. Calls run concurrently,
+// and each response is written as soon as its call finishes, so responses can be out of order.
+// Callers match responses to calls by their request ID, which must be unique among the calls in flight.
+//
+// At most the number of calls set by WithMaxConnCalls run at once. Beyond that, no more frames are read
+// until a call finishes, so a caller that sends too fast is slowed down by the connection itself.
+//
+// If a frame cannot be decoded, an error without a request ID is written, and the connection is closed
+// after the calls in flight finish, since the next frame cannot be found.
+func (s *Service) ServeConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &serverConn{
+		service: s,
+		conn:    conn,
+		ctx:     ctx,
+		cancel:  cancel,
+		writer:  bufio.NewWriter(conn),
+		slots:   make(chan struct{}, s.options.maxConnCalls),
+	}
+
+	if !s.trackConn(c, true) {
+		cancel()
+		conn.Close()
+		return
+	}
+	defer s.trackConn(c, false)
+
+	c.serve()
+}
+
+// serverConn is a persistent connection served by ServeConn.
+type serverConn struct {
+	service *Service
+	conn    net.Conn
+
+	// ctx is canceled when the connection fails, which abandons its calls
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	calls sync.WaitGroup
+	slots chan struct{}
+}
+
+func (c *serverConn) serve() {
+	defer c.conn.Close()
+	defer c.cancel()
+
+	decoder := wire.NewDecoder(c.conn, c.service.options.decoderOptions...)
+	for {
+		decoded, err := decoder.Decode()
+		if err != nil {
+			// Errors reading the connection cannot be reported, since the caller is gone or is not listening
+			if !erk.IsKind(err, wire.ErkUnableToRead{}) {
+				c.service.countDecodeError()
+				c.write(func(w io.Writer) { c.service.writeEventError(w, nil, nil, 0, err) }, true)
+			}
+
+			break
+		}
+
+		c.slots <- struct{}{}
+		c.calls.Add(1)
+		go c.call(decoded)
+	}
+
+	c.calls.Wait()
+}
+
+func (c *serverConn) call(decoded *wire.DecodeResult) {
+	defer func() {
+		<-c.slots
+		c.calls.Done()
+	}()
+
+	c.service.serveFrame(c.ctx, decoded, c.write)
+}
+
+// write a frame, and flush it to the connection. Every frame is flushed, since other calls share the connection.
+// If the connection cannot be written, it is closed, and calls in flight are canceled.
+func (c *serverConn) write(writeFrame func(w io.Writer), flush bool) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	writeFrame(c.writer)
+	err := c.writer.Flush()
+	if err != nil {
+		c.cancel()
+		c.conn.Close()
+	}
+
+	return err
+}
+
+func (c *serverConn) stopReading() {
+	c.conn.SetReadDeadline(time.Now())
+}
+
+func (c *serverConn) close() {
+	c.cancel()
+	c.conn.Close()
+}
+
+// trackedConn is a persistent connection, which Shutdown waits for.
+type trackedConn interface {
+	// stopReading new calls, so the connection closes once its calls in flight finish.
+	stopReading()
+
+	// close the connection, abandoning its calls in flight.
+	close()
+}
+
+// trackListener adds or removes a listener served by ServeConns, so Shutdown can close it.
+// It returns false if the listener cannot be added, because the service is shutting down.
+func (s *Service) trackListener(listener net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.connListeners, listener)
+		return true
+	}
+
+	if s.shuttingDown {
+		return false
+	}
+
+	s.connListeners[listener] = struct{}{}
+	return true
+}
+
+// trackConn adds or removes a persistent connection, so Shutdown can wait for it.
+// It returns false if the connection cannot be added, because the service is shutting down.
+func (s *Service) trackConn(c trackedConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.conns, c)
+		s.connsDone.Done()
+		return true
+	}
+
+	if s.shuttingDown {
+		return false
+	}
+
+	s.conns[c] = struct{}{}
+	s.connsDone.Add(1)
+	return true
+}
+
+// stopConns closes the listeners served by ServeConns, and stops reading calls from open persistent connections.
+// If force is true, open connections are closed, abandoning their calls in flight.
+func (s *Service) stopConns(force bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for listener := range s.connListeners {
+		listener.Close()
+	}
+
+	for c := range s.conns {
+		if force {
+			c.close()
+		} else {
+			c.stopReading()
+		}
+	}
+}
+
+func (s *Service) isShuttingDown() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.shuttingDown
+}
@@ -0,0 +1,167 @@
+package hoist_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+// waitingCalls blocks calls to its wait function until the channel named by their message is released.
+type waitingCalls struct {
+	release map[string]chan struct{}
+	running int32
+}
+
+func newWaitingCalls() *waitingCalls {
+	w := &waitingCalls{release: map[string]chan struct{}{"slow": make(chan struct{}), "fast": make(chan struct{})}}
+	close(w.release["fast"])
+	return w
+}
+
+func (w *waitingCalls) wait(ctx *TestContext, params *TestParams) (*TestParams, error) {
+	atomic.AddInt32(&w.running, 1)
+	defer atomic.AddInt32(&w.running, -1)
+
+	<-w.release[params.Message]
+	return params, nil
+}
+
+func TestServeConn(t *testing.T) {
+	t.Run("responses are written as calls finish", func(t *testing.T) {
+		is := is.New(t)
+
+		calls := newWaitingCalls()
+		s := newTestService(map[string]interface{}{"wait": calls.wait})
+		conn, serverConn := net.Pipe()
+		defer conn.Close()
+		go s.ServeConn(serverConn)
+
+		go func() {
+			writeConnRequest(conn, "first", "slow")
+			writeConnRequest(conn, "second", "fast")
+		}()
+
+		decoder := wire.NewDecoder(conn)
+		details, params := readConnResponse(t, decoder)
+		is.Equal(details, &strand.ResponseDetails{RequestID: "second"})
+		is.Equal(params, &TestParams{Message: "fast"})
+
+		close(calls.release["slow"])
+		details, params = readConnResponse(t, decoder)
+		is.Equal(details, &strand.ResponseDetails{RequestID: "first"})
+		is.Equal(params, &TestParams{Message: "slow"})
+	})
+
+	t.Run("stops reading calls at the limit", func(t *testing.T) {
+		is := is.New(t)
+
+		calls := newWaitingCalls()
+		s := newTestService(map[string]interface{}{"wait": calls.wait}, hoist.WithMaxConnCalls(1))
+		conn, serverConn := net.Pipe()
+		defer conn.Close()
+		go s.ServeConn(serverConn)
+
+		go func() {
+			writeConnRequest(conn, "first", "slow")
+			writeConnRequest(conn, "second", "slow")
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		is.Equal(atomic.LoadInt32(&calls.running), int32(1))
+
+		close(calls.release["slow"])
+		decoder := wire.NewDecoder(conn)
+		details, _ := readConnResponse(t, decoder)
+		is.Equal(details.RequestID, "first")
+		details, _ = readConnResponse(t, decoder)
+		is.Equal(details.RequestID, "second")
+	})
+
+	t.Run("with invalid frame", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newTestService(map[string]interface{}{"wait": newWaitingCalls().wait})
+		conn, serverConn := net.Pipe()
+		defer conn.Close()
+		go s.ServeConn(serverConn)
+
+		go conn.Write([]byte("x:"))
+
+		decoder := wire.NewDecoder(conn)
+		details, _ := readConnResponse(t, decoder)
+		is.Equal(details, &errRespDetailsInternal)
+
+		// The connection is closed, since the next frame cannot be found
+		_, err := decoder.Decode()
+		is.True(errors.Is(err, io.EOF))
+	})
+
+	t.Run("Shutdown waits for calls in flight", func(t *testing.T) {
+		is := is.New(t)
+
+		calls := newWaitingCalls()
+		s := newTestService(map[string]interface{}{"wait": calls.wait})
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeConns(listener) }()
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		is.NoErr(err)
+		defer conn.Close()
+
+		writeConnRequest(conn, "first", "slow")
+		for atomic.LoadInt32(&calls.running) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		shutdownErr := make(chan error)
+		go func() { shutdownErr <- s.Shutdown(context.Background()) }()
+
+		// New connections are refused once shutting down
+		is.NoErr(<-serveErr)
+		_, err = net.Dial("tcp", listener.Addr().String())
+		is.True(err != nil)
+
+		close(calls.release["slow"])
+		decoder := wire.NewDecoder(conn)
+		details, params := readConnResponse(t, decoder)
+		is.Equal(details, &strand.ResponseDetails{RequestID: "first"})
+		is.Equal(params, &TestParams{Message: "slow"})
+		is.NoErr(<-shutdownErr)
+
+		_, err = decoder.Decode()
+		is.True(errors.Is(err, io.EOF))
+	})
+}
+
+func writeConnRequest(conn net.Conn, requestID, message string) {
+	details := &strand.RequestDetails{RequestID: requestID, FunctionName: "wait"}
+	wire.NewEncoder(conn).Encode(details, &TestParams{Message: message})
+}
+
+func readConnResponse(t *testing.T, decoder *wire.Decoder) (*strand.ResponseDetails, *TestParams) {
+	t.Helper()
+
+	decoded, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details, params, err := parseRequest(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return details, params
+}
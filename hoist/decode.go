@@ -0,0 +1,140 @@
+package hoist
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/msgpack"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+type (
+	ErkParamsUnknownField struct{ erks.Default }
+	ErkParamsTrailingData struct{ erks.Default }
+	ErkParamsNull         struct{ erks.Default }
+)
+
+var (
+	ErrParamsUnknownField = erk.New(ErkParamsUnknownField{}, "params contain unknown field '{{.field}}'")
+	ErrParamsTrailingData = erk.New(ErkParamsTrailingData{}, "params contain data after the encoded value")
+	ErrParamsNull         = erk.New(ErkParamsNull{}, "params cannot be null")
+)
+
+// ParamsDecoding configures how the params of functions are decoded.
+// The zero value decodes params like json.Unmarshal (or msgpack.Unmarshal for the MessagePack encoding).
+// Params in encodings added with wire.RegisterCodec are decoded by the codec, and are never strict.
+type ParamsDecoding struct {
+	// Strict rejects params containing fields that do not exist in the params type with ErrParamsUnknownField,
+	// and params containing more data after the encoded value with ErrParamsTrailingData.
+	Strict bool
+
+	// DisallowNull rejects null params with ErrParamsNull, unless the params type is a pointer or an interface.
+	DisallowNull bool
+}
+
+// unknownFieldPrefix is the start of the error returned by encoding/json for unknown fields.
+const unknownFieldPrefix = "json: unknown field "
+
+// decode the params in the wire encoding into the value pointed to by params.
+// The redactor masks the params included in errors.
+func (d ParamsDecoding) decode(rawParams []byte, encoding wire.Encoding, params reflect.Value, r *redactor) error {
+	if d.DisallowNull && isNull(rawParams, encoding) {
+		switch params.Elem().Kind() {
+		case reflect.Ptr, reflect.Interface:
+		default:
+			return ErrParamsNull
+		}
+	}
+
+	switch encoding {
+	case wire.EncodingJSON:
+	case wire.EncodingMsgPack:
+		return d.decodeMsgPack(rawParams, params, r)
+	default:
+		// Other codecs cannot report unknown fields or trailing data, so they are always lenient
+		if err := encoding.UnmarshalParams(rawParams, params.Interface()); err != nil {
+			return unmarshalErr(err, rawParams, encoding, r)
+		}
+
+		return nil
+	}
+
+	if !d.Strict {
+		if err := json.Unmarshal(rawParams, params.Interface()); err != nil {
+			return erk.WithParam(erk.WrapAs(ErrFunctionCallJSONUnmarshal, r.redactJSONErr(err)), "originalJSON", r.redact(rawParams, encoding))
+		}
+
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(rawParams))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(params.Interface()); err != nil {
+		if msg := err.Error(); strings.HasPrefix(msg, unknownFieldPrefix) {
+			field, unquoteErr := strconv.Unquote(strings.TrimPrefix(msg, unknownFieldPrefix))
+			if unquoteErr == nil {
+				return erk.WithParam(ErrParamsUnknownField, "field", field)
+			}
+		}
+
+		return erk.WithParam(erk.WrapAs(ErrFunctionCallJSONUnmarshal, r.redactJSONErr(err)), "originalJSON", r.redact(rawParams, encoding))
+	}
+
+	// Only whitespace can follow the value
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrParamsTrailingData
+	}
+
+	return nil
+}
+
+func (d ParamsDecoding) decodeMsgPack(rawParams []byte, params reflect.Value, r *redactor) error {
+	dec := msgpack.NewDecoder(rawParams)
+	if d.Strict {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(params.Interface()); err != nil {
+		if erk.IsKind(err, msgpack.ErkUnknownField{}) {
+			return erk.WithParam(ErrParamsUnknownField, "field", erk.GetParams(err)["field"])
+		}
+
+		return unmarshalErr(err, rawParams, wire.EncodingMsgPack, r)
+	}
+
+	if dec.Buffered() > 0 {
+		if d.Strict {
+			return ErrParamsTrailingData
+		}
+
+		// Unlike JSON, there is no whitespace that can follow the value
+		return unmarshalErr(msgpack.ErrTrailingData, rawParams, wire.EncodingMsgPack, r)
+	}
+
+	return nil
+}
+
+// unmarshalErr wraps an error decoding params that are not JSON encoded.
+func unmarshalErr(err error, rawParams []byte, encoding wire.Encoding, r *redactor) error {
+	wrappedErr := erk.WrapAs(ErrFunctionCallUnmarshal, err)
+	return erk.WithParams(wrappedErr, erk.Params{
+		"encoding":       encoding.String(),
+		"originalParams": r.redact(rawParams, encoding),
+	})
+}
+
+func isNull(rawParams []byte, encoding wire.Encoding) bool {
+	if encoding == wire.EncodingJSON {
+		return string(bytes.TrimSpace(rawParams)) == "null"
+	}
+
+	var value interface{}
+	return encoding.UnmarshalParams(rawParams, &value) == nil && value == nil
+}
@@ -0,0 +1,239 @@
+package hoist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/msgpack"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+type StrictParams struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestParamsDecoding(t *testing.T) {
+	echo := func(params StrictParams) (StrictParams, error) {
+		return params, nil
+	}
+	echoPtr := func(params *StrictParams) (*StrictParams, error) {
+		return params, nil
+	}
+
+	table := []struct {
+		Name          string
+		Decoding      hoist.ParamsDecoding
+		Fn            interface{}
+		Params        string
+		ExpectedData  interface{}
+		ExpectedError error
+	}{
+		{
+			Name:         "lenient ignores unknown fields",
+			Fn:           echo,
+			Params:       `{"name":"abc","nmae":"typo"}`,
+			ExpectedData: StrictParams{Name: "abc"},
+		},
+		{
+			Name:          "lenient rejects trailing data as malformed JSON",
+			Fn:            echo,
+			Params:        `{"name":"abc"} {}`,
+			ExpectedError: hoist.ErrFunctionCallJSONUnmarshal,
+		},
+		{
+			Name:         "lenient allows null",
+			Fn:           echo,
+			Params:       `null`,
+			ExpectedData: StrictParams{},
+		},
+		{
+			Name:         "strict accepts known fields",
+			Decoding:     hoist.ParamsDecoding{Strict: true},
+			Fn:           echo,
+			Params:       " {\"name\":\"abc\",\"count\":2}\n",
+			ExpectedData: StrictParams{Name: "abc", Count: 2},
+		},
+		{
+			Name:          "strict rejects unknown fields",
+			Decoding:      hoist.ParamsDecoding{Strict: true},
+			Fn:            echo,
+			Params:        `{"name":"abc","nmae":"typo"}`,
+			ExpectedError: hoist.ErrParamsUnknownField,
+		},
+		{
+			Name:          "strict rejects trailing data",
+			Decoding:      hoist.ParamsDecoding{Strict: true},
+			Fn:            echo,
+			Params:        `{"name":"abc"} {}`,
+			ExpectedError: hoist.ErrParamsTrailingData,
+		},
+		{
+			Name:          "strict rejects malformed JSON",
+			Decoding:      hoist.ParamsDecoding{Strict: true},
+			Fn:            echo,
+			Params:        `{"name":`,
+			ExpectedError: hoist.ErrFunctionCallJSONUnmarshal,
+		},
+		{
+			Name:         "strict allows null",
+			Decoding:     hoist.ParamsDecoding{Strict: true},
+			Fn:           echo,
+			Params:       `null`,
+			ExpectedData: StrictParams{},
+		},
+		{
+			Name:          "disallow null rejects null",
+			Decoding:      hoist.ParamsDecoding{DisallowNull: true},
+			Fn:            echo,
+			Params:        ` null `,
+			ExpectedError: hoist.ErrParamsNull,
+		},
+		{
+			Name:         "disallow null allows null for pointers",
+			Decoding:     hoist.ParamsDecoding{DisallowNull: true},
+			Fn:           echoPtr,
+			Params:       `null`,
+			ExpectedData: (*StrictParams)(nil),
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			s := hoist.NewService("myService", hoist.WithParamsDecoding(entry.Decoding))
+			s.RegisterAs("myFunc", entry.Fn)
+			is.Equal(len(s.Errors()), 0)
+
+			data, err := s.Call("myFunc", []byte(entry.Params))
+			if entry.ExpectedError != nil {
+				is.True(errors.Is(err, hoist.ErrFunctionCallFailed))
+				is.True(errors.Is(err, entry.ExpectedError))
+				return
+			}
+
+			is.NoErr(err)
+			is.Equal(data, entry.ExpectedData)
+		})
+	}
+
+	t.Run("unknown field is a param of the error", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService", hoist.WithParamsDecoding(hoist.ParamsDecoding{Strict: true}))
+		s.RegisterAs("myFunc", echo)
+
+		_, err := s.Call("myFunc", []byte(`{"nmae":"typo"}`))
+		is.True(erk.IsKind(errors.Unwrap(err), hoist.ErkParamsUnknownField{}))
+		is.Equal(erk.GetParams(errors.Unwrap(err))["field"], "nmae")
+	})
+
+	t.Run("function option overrides the service", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService", hoist.WithParamsDecoding(hoist.ParamsDecoding{Strict: true}))
+		s.RegisterAs("strict", echo)
+		s.RegisterAs("lenient", echo, hoist.FuncParamsDecoding(hoist.ParamsDecoding{}))
+
+		_, err := s.Call("strict", []byte(`{"nmae":"typo"}`))
+		is.True(errors.Is(err, hoist.ErrParamsUnknownField))
+
+		data, err := s.Call("lenient", []byte(`{"nmae":"typo"}`))
+		is.NoErr(err)
+		is.Equal(data, StrictParams{})
+	})
+
+	t.Run("struct methods use the function options", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterStruct(&UserStore{}, hoist.StructOptions{
+			FuncOptions: []hoist.FuncOption{hoist.FuncParamsDecoding(hoist.ParamsDecoding{Strict: true})},
+		})
+
+		_, err := s.Call("getUserByID", []byte(`{"ID":"abc","Name":"typo"}`))
+		is.True(errors.Is(err, hoist.ErrParamsUnknownField))
+	})
+
+	t.Run("MessagePack params", func(t *testing.T) {
+		msgpackTable := []struct {
+			Name          string
+			Decoding      hoist.ParamsDecoding
+			Params        []byte
+			ExpectedData  interface{}
+			ExpectedError error
+		}{
+			{
+				Name:         "lenient ignores unknown fields",
+				Params:       mustMsgPack(map[string]interface{}{"name": "abc", "nmae": "typo"}),
+				ExpectedData: StrictParams{Name: "abc"},
+			},
+			{
+				Name:          "lenient rejects trailing data as malformed",
+				Params:        append(mustMsgPack(map[string]interface{}{"name": "abc"}), 0xc0),
+				ExpectedError: hoist.ErrFunctionCallUnmarshal,
+			},
+			{
+				Name:          "lenient rejects malformed params",
+				Params:        []byte{0x81, 0xa4},
+				ExpectedError: hoist.ErrFunctionCallUnmarshal,
+			},
+			{
+				Name:          "strict rejects unknown fields",
+				Decoding:      hoist.ParamsDecoding{Strict: true},
+				Params:        mustMsgPack(map[string]interface{}{"name": "abc", "nmae": "typo"}),
+				ExpectedError: hoist.ErrParamsUnknownField,
+			},
+			{
+				Name:          "strict rejects trailing data",
+				Decoding:      hoist.ParamsDecoding{Strict: true},
+				Params:        append(mustMsgPack(map[string]interface{}{"name": "abc"}), 0xc0),
+				ExpectedError: hoist.ErrParamsTrailingData,
+			},
+			{
+				Name:          "disallow null rejects nil",
+				Decoding:      hoist.ParamsDecoding{DisallowNull: true},
+				Params:        []byte{0xc0},
+				ExpectedError: hoist.ErrParamsNull,
+			},
+		}
+
+		for _, entry := range msgpackTable {
+			entry := entry
+			t.Run(entry.Name, func(t *testing.T) {
+				is := is.New(t)
+
+				s := hoist.NewService("myService", hoist.WithParamsDecoding(entry.Decoding))
+				s.RegisterAs("myFunc", echo)
+				is.Equal(len(s.Errors()), 0)
+
+				details := &strand.RequestDetails{ServiceName: "myService", FunctionName: "myFunc"}
+				data, err := s.CallWithEncoding(context.Background(), details, wire.EncodingMsgPack, entry.Params)
+				if entry.ExpectedError != nil {
+					is.True(errors.Is(err, hoist.ErrFunctionCallFailed))
+					is.True(errors.Is(err, entry.ExpectedError))
+					return
+				}
+
+				is.NoErr(err)
+				is.Equal(data, entry.ExpectedData)
+			})
+		}
+	})
+}
+
+func mustMsgPack(v interface{}) []byte {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
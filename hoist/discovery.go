@@ -0,0 +1,235 @@
+package hoist
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+type ErkExportRendering struct{ erks.Default }
+
+var (
+	ErrExportFormatInvalid = erk.New(ErkBadRequest{}, "export format '{{.format}}' is not supported, use 'wire', 'markdown', or 'html'")
+	ErrExportRendering     = erk.New(ErkExportRendering{}, "could not render the exported service: {{.err}}")
+)
+
+// Export formats supported by the export endpoint's "format" query parameter.
+const (
+	ExportFormatWire     = "wire"
+	ExportFormatMarkdown = "markdown"
+	ExportFormatHTML     = "html"
+)
+
+// exportHandler serves the exported service, so tooling and developers can discover the API.
+//
+// The format is selected with the "format" query parameter, and defaults to the wire format.
+// Responses include an ETag, so clients can poll with If-None-Match.
+func (s *Service) exportHandler(w http.ResponseWriter, r *http.Request) {
+	exported := s.Export()
+
+	var body bytes.Buffer
+	var err error
+	contentType := ""
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", ExportFormatWire:
+		var encoded []byte
+		encoded, err = wire.Encode(&strand.ResponseDetails{}, exported)
+		body.Write(encoded)
+
+	case ExportFormatMarkdown, "md":
+		contentType = "text/markdown; charset=utf-8"
+		err = exported.WriteMarkdown(&body)
+
+	case ExportFormatHTML:
+		contentType = "text/html; charset=utf-8"
+		err = exported.WriteHTML(&body)
+
+	default:
+		s.writeBadRequest(w, erk.WithParam(ErrExportFormatInvalid, "format", format))
+		return
+	}
+
+	if err != nil {
+		s.writeEventError(w, nil, nil, 0, erk.WrapAs(ErrExportRendering, err))
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	if etagMatches(r.Header["If-None-Match"], etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Write(body.Bytes())
+}
+
+// etagMatches returns true if the If-None-Match header values match the ETag, as described in RFC 7232 section 3.2:
+// the values are lists of entity tags, compared weakly by ignoring the "W/" prefix, or "*", which matches any ETag.
+func etagMatches(ifNoneMatch []string, etag string) bool {
+	for _, value := range ifNoneMatch {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeBadRequest answers with a 400 and an error frame that is not internal, since the request was wrong.
+func (s *Service) writeBadRequest(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	wire.NewEncoder(w).Encode(&strand.ResponseDetails{IsError: true}, erk.Export(s.redactErrorParams(err)))
+}
+
+// exportView is the data provided to the documentation templates.
+type exportView struct {
+	Name        string
+	Functions   []namedSchemas
+	Definitions []namedSchemas
+}
+
+type namedSchemas struct {
+	Name    string
+	Params  string
+	Returns string
+	Schema  string
+}
+
+func (e *ExportedService) view() (*exportView, error) {
+	view := &exportView{Name: e.Name}
+
+	fnNames := make([]string, 0, len(e.Functions))
+	for name := range e.Functions {
+		fnNames = append(fnNames, name)
+	}
+	sort.Strings(fnNames)
+
+	for _, name := range fnNames {
+		fn := e.Functions[name]
+
+		params, err := indentedJSON(fn.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		returns, err := indentedJSON(fn.Returns)
+		if err != nil {
+			return nil, err
+		}
+
+		view.Functions = append(view.Functions, namedSchemas{Name: name, Params: params, Returns: returns})
+	}
+
+	defNames := make([]string, 0, len(e.Definitions))
+	for name := range e.Definitions {
+		defNames = append(defNames, name)
+	}
+	sort.Strings(defNames)
+
+	for _, name := range defNames {
+		schema, err := indentedJSON(e.Definitions[name])
+		if err != nil {
+			return nil, err
+		}
+
+		view.Definitions = append(view.Definitions, namedSchemas{Name: name, Schema: schema})
+	}
+
+	return view, nil
+}
+
+func indentedJSON(v interface{}) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	return string(b), err
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Parse(`# {{.Name}}
+{{range .Functions}}
+## {{.Name}}
+
+Params:
+
+` + "```json" + `
+{{.Params}}
+` + "```" + `
+
+Returns:
+
+` + "```json" + `
+{{.Returns}}
+` + "```" + `
+{{end}}{{if .Definitions}}
+## Definitions
+{{range .Definitions}}
+### {{.Name}}
+
+` + "```json" + `
+{{.Schema}}
+` + "```" + `
+{{end}}{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+{{range .Functions}}
+<h2 id="fn-{{.Name}}">{{.Name}}</h2>
+<p>Params:</p>
+<pre><code>{{.Params}}</code></pre>
+<p>Returns:</p>
+<pre><code>{{.Returns}}</code></pre>
+{{end}}{{if .Definitions}}
+<h2>Definitions</h2>
+{{range .Definitions}}
+<h3 id="definitions-{{.Name}}">{{.Name}}</h3>
+<pre><code>{{.Schema}}</code></pre>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// WriteMarkdown writes human readable documentation of the service as Markdown.
+func (e *ExportedService) WriteMarkdown(w io.Writer) error {
+	view, err := e.view()
+	if err != nil {
+		return err
+	}
+
+	return markdownTemplate.Execute(w, view)
+}
+
+// WriteHTML writes human readable documentation of the service as an HTML page.
+func (e *ExportedService) WriteHTML(w io.Writer) error {
+	view, err := e.view()
+	if err != nil {
+		return err
+	}
+
+	return htmlTemplate.Execute(w, view)
+}
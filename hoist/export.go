@@ -1,14 +1,26 @@
 package hoist
 
+import "sort"
+
 // ExportedFunction with name, parameters, and return values.
+//
+// Params is nil when the function takes no params, and Returns is nil when the function returns no data.
+// Functions that stream have Streams set, and Returns describes the items of functions that return a channel.
 type ExportedFunction struct {
-	Name string `json:"name"`
+	Name    string  `json:"name"`
+	Params  *Schema `json:"params"`
+	Returns *Schema `json:"returns"`
+	Streams bool    `json:"streams,omitempty"`
 }
 
 // ExportedService with name and functions.
+//
+// Named struct types used by the functions are described once in Definitions,
+// and referenced from the function schemas with "#/definitions/<name>".
 type ExportedService struct {
-	Name      string                       `json:"name"`
-	Functions map[string]*ExportedFunction `json:"functions"`
+	Name        string                       `json:"name"`
+	Functions   map[string]*ExportedFunction `json:"functions"`
+	Definitions map[string]*Schema           `json:"definitions,omitempty"`
 }
 
 // Export the service into a static representation of the API.
@@ -21,12 +33,31 @@ func (s *Service) Export() *ExportedService {
 		Functions: make(map[string]*ExportedFunction),
 	}
 
-	// Build up the functions
-	// TODO: Provide information about the parameters and return types
+	// Sort the names, so definition names are stable between exports
+	names := make([]string, 0, len(s.funcs))
 	for name := range s.funcs {
-		service.Functions[name] = &ExportedFunction{
-			Name: name,
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Build up the functions
+	builder := newSchemaBuilder()
+	for _, name := range names {
+		fn := s.funcs[name]
+		exported := &ExportedFunction{Name: name, Streams: fn.streams}
+
+		if fn.paramsType != nil {
+			exported.Params = builder.schemaFor(fn.paramsType)
+		}
+		if fn.dataType != nil {
+			exported.Returns = builder.schemaFor(fn.dataType)
 		}
+
+		service.Functions[name] = exported
+	}
+
+	if len(builder.definitions) > 0 {
+		service.Definitions = builder.definitions
 	}
 
 	return &service
@@ -0,0 +1,283 @@
+package hoist
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+type ErkInvalidHook struct{ erks.Default }
+
+var (
+	ErrInvalidHook             = erk.New(ErkInvalidHook{}, "service '{{.serviceName}}' could not register hook: {{.err}}")
+	ErrHookNotFunction         = erk.New(ErkInvalidHook{}, "a hook function was not provided")
+	ErrHookInvalidSignature    = erk.New(ErkInvalidHook{}, "hook must have the signature: func(*hoist.Request) (T, error)")
+	ErrHookMissingTag          = erk.New(ErkInvalidHook{}, "hook tag cannot be empty")
+	ErrContextFieldUnfillable  = erk.New(ErkInvalidFunction{}, "no hook can fill context field '{{.field}}' of type '{{.fieldType}}'")
+	ErrContextFieldTagMismatch = erk.New(ErkInvalidFunction{}, "hook '{{.tag}}' returns '{{.hookType}}', which cannot be assigned to context field '{{.field}}' of type '{{.fieldType}}'")
+)
+
+// hookTagName is the struct tag used to select a tagged hook for a context field.
+//
+// Example:
+//  type MyContext struct {
+//    DB        *sql.DB            // Filled by a hook registered with Hook that returns *sql.DB
+//    RequestID string `hook:"id"` // Filled by a hook registered with HookTag("id", ...)
+//    Skipped   string `hook:"-"`  // Always left as the zero value
+//  }
+const hookTagName = "hook"
+
+// requestType allows us to check that hooks accept a *Request.
+var requestType = reflect.TypeOf(&Request{})
+
+// Request describes a single call to a registered function.
+// It is provided to hooks, so they can fill the function's context.
+type Request struct {
+	Context      context.Context
+	ServiceName  string
+	FunctionName string
+	Details      strand.RequestDetails
+
+	// Encoding of the raw params provided to interceptors.
+	Encoding wire.Encoding
+
+	// stream receives the items of streaming functions
+	stream *stream
+}
+
+// contextHook is registered on every service, so functions can accept a context.Context,
+// or a struct embedding one, to learn when the call is canceled.
+var contextHook = &hook{
+	returnType: reflect.TypeOf(new(context.Context)).Elem(),
+	fn: reflect.ValueOf(func(req *Request) (context.Context, error) {
+		return req.Context, nil
+	}),
+}
+
+// hook is an internal representation of a registered hook.
+type hook struct {
+	returnType reflect.Type
+	fn         reflect.Value
+}
+
+// Hook registers a hook that fills any context field with the same type as the hook's return value.
+// Hooks are called for every function call, and must be registered before the functions that use them.
+//
+// fn must be a function with the following signature:
+//  func myHook(req *hoist.Request) (myFieldType, error)
+func (s *Service) Hook(fn interface{}) {
+	h, err := newHook(fn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors = append(s.errors, erk.WithParam(erk.WrapAs(ErrInvalidHook, err), "serviceName", s.name))
+		return
+	}
+
+	s.typeHooks = append(s.typeHooks, h)
+}
+
+// HookTag registers a hook that fills any context field tagged with `hook:"<tag>"`.
+// Hooks are called for every function call, and must be registered before the functions that use them.
+//
+// fn must be a function with the following signature:
+//  func myHook(req *hoist.Request) (myFieldType, error)
+func (s *Service) HookTag(tag string, fn interface{}) {
+	h, err := newHook(fn)
+	if err == nil && tag == "" {
+		err = ErrHookMissingTag
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors = append(s.errors, erk.WithParam(erk.WrapAs(ErrInvalidHook, err), "serviceName", s.name))
+		return
+	}
+
+	s.tagHooks[tag] = h
+}
+
+func newHook(fn interface{}) (*hook, error) {
+	fnType := reflect.TypeOf(fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return nil, ErrHookNotFunction
+	}
+
+	if fnType.NumIn() != 1 || fnType.In(0) != requestType || fnType.NumOut() != 2 || !fnType.Out(1).Implements(errorType) {
+		return nil, ErrHookInvalidSignature
+	}
+
+	return &hook{
+		returnType: fnType.Out(0),
+		fn:         reflect.ValueOf(fn),
+	}, nil
+}
+
+func (h *hook) call(req *Request) (reflect.Value, error) {
+	rets := h.fn.Call([]reflect.Value{reflect.ValueOf(req)})
+	if err, _ := rets[1].Interface().(error); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return rets[0], nil
+}
+
+// contextFiller builds a function's context by calling the hooks for each field.
+type contextFiller struct {
+	ctxType    reflect.Type
+	structType reflect.Type
+	whole      *hook
+	fields     []fieldFiller
+}
+
+type fieldFiller struct {
+	index int
+	hook  *hook
+}
+
+// newContextFiller matches each field of the context type with a hook.
+// The caller must hold at least a read lock.
+func (s *Service) newContextFiller(ctxType reflect.Type) (*contextFiller, error) {
+	filler := &contextFiller{ctxType: ctxType}
+
+	// A hook can provide the entire context
+	if h := s.findTypeHook(ctxType); h != nil {
+		filler.whole = h
+		return filler, nil
+	}
+
+	// Otherwise, fill the fields of the struct (or pointer to struct)
+	structType := ctxType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return filler, nil
+	}
+	filler.structType = structType
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" { // Unexported
+			continue
+		}
+
+		tag, hasTag := field.Tag.Lookup(hookTagName)
+		if tag == "-" {
+			continue
+		}
+
+		if hasTag {
+			h, ok := s.tagHooks[tag]
+			if !ok || !h.returnType.AssignableTo(field.Type) {
+				hookType := "<none>"
+				if ok {
+					hookType = h.returnType.String()
+				}
+
+				return nil, erk.WithParams(ErrContextFieldTagMismatch, erk.Params{
+					"tag":       tag,
+					"hookType":  hookType,
+					"field":     field.Name,
+					"fieldType": field.Type.String(),
+				})
+			}
+
+			filler.fields = append(filler.fields, fieldFiller{index: i, hook: h})
+			continue
+		}
+
+		h := s.findTypeHook(field.Type)
+		if h == nil {
+			return nil, erk.WithParams(ErrContextFieldUnfillable, erk.Params{
+				"field":     field.Name,
+				"fieldType": field.Type.String(),
+			})
+		}
+
+		filler.fields = append(filler.fields, fieldFiller{index: i, hook: h})
+	}
+
+	return filler, nil
+}
+
+// isLoneContext returns true if the only parameter of a function is its context, instead of its params:
+// the exact type returned by a hook, or a struct with a context.Context or Sender field.
+// Other types are params, even when hooks could fill them, so the params of callers are never dropped.
+// The caller must hold at least a read lock.
+func (s *Service) isLoneContext(t reflect.Type) bool {
+	for _, h := range s.typeHooks {
+		if h.returnType == t {
+			return true
+		}
+	}
+
+	structType := t
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		if fieldType := structType.Field(i).Type; fieldType == contextType || fieldType == senderType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findTypeHook returns the hook returning exactly the type.
+// Assignable hooks are not used, since interface fields would be filled by unrelated hooks.
+func (s *Service) findTypeHook(t reflect.Type) *hook {
+	for _, h := range s.typeHooks {
+		if h.returnType == t {
+			return h
+		}
+	}
+
+	return nil
+}
+
+// fill creates the context for a single call.
+func (c *contextFiller) fill(req *Request) (reflect.Value, error) {
+	if c.whole != nil {
+		v, err := c.whole.call(req)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ctx := reflect.New(c.ctxType).Elem()
+		ctx.Set(v)
+		return ctx, nil
+	}
+
+	if c.structType == nil {
+		return reflect.New(c.ctxType).Elem(), nil
+	}
+
+	ctx := reflect.New(c.structType)
+	for _, field := range c.fields {
+		v, err := field.hook.call(req)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ctx.Elem().Field(field.index).Set(v)
+	}
+
+	if c.ctxType.Kind() == reflect.Ptr {
+		return ctx, nil
+	}
+	return ctx.Elem(), nil
+}
@@ -0,0 +1,270 @@
+package hoist_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/matryer/is"
+)
+
+type HookDB struct{ Name string }
+
+type HookContext struct {
+	DB        *HookDB
+	RequestID string `hook:"id"`
+	Skipped   string `hook:"-"`
+	internal  string
+}
+
+type HookCallContext struct {
+	context.Context
+	DB        *HookDB
+	RequestID string `hook:"id"`
+}
+
+func TestHooks(t *testing.T) {
+	const serviceName = "myService"
+	var hookErr = errors.New("hook failed")
+
+	db := &HookDB{Name: "my-db"}
+	dbHook := func(*hoist.Request) (*HookDB, error) { return db, nil }
+	idHook := func(req *hoist.Request) (string, error) { return req.Details.RequestID, nil }
+
+	table := []struct {
+		Name  string
+		Check func(is *is.I, s *hoist.Service)
+	}{
+		{
+			Name: "fills context fields by type and tag",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.HookTag("id", idHook)
+
+				var received *HookContext
+				s.RegisterAs("myFn", func(ctx *HookContext, params *MyParams) (*MyData, error) {
+					received = ctx
+					return nil, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+
+				_, err := s.CallWithDetails(context.Background(), &strand.RequestDetails{RequestID: "my-id", FunctionName: "myFn"}, []byte(`{}`))
+				is.NoErr(err)
+				is.Equal(received, &HookContext{DB: db, RequestID: "my-id"})
+			},
+		},
+		{
+			Name: "fills non-pointer context",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.HookTag("id", idHook)
+
+				var received HookContext
+				s.RegisterAs("myFn", func(ctx HookContext, params *MyParams) (*MyData, error) {
+					received = ctx
+					return nil, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+
+				_, err := s.Call("myFn", []byte(`{}`))
+				is.NoErr(err)
+				is.Equal(received, HookContext{DB: db})
+			},
+		},
+		{
+			Name: "hook provides entire context",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+
+				var received *HookDB
+				s.RegisterAs("myFn", func(ctx *HookDB, params *MyParams) (*MyData, error) {
+					received = ctx
+					return nil, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+
+				_, err := s.Call("myFn", []byte(`{}`))
+				is.NoErr(err)
+				is.Equal(received, db)
+			},
+		},
+		{
+			Name: "lone parameter provided by a hook is the context",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.RegisterAs("myFn", func(ctx *HookDB) (string, error) {
+					return ctx.Name, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+				is.Equal(s.Export().Functions["myFn"].Params, nil)
+
+				data, err := s.Call("myFn", []byte(`{}`))
+				is.NoErr(err)
+				is.Equal(data, "my-db")
+			},
+		},
+		{
+			Name: "lone struct with a context.Context is the context",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.HookTag("id", idHook)
+
+				var received *HookCallContext
+				s.RegisterAs("myFn", func(ctx *HookCallContext) (*MyData, error) {
+					received = ctx
+					return nil, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+				is.Equal(s.Export().Functions["myFn"].Params, nil)
+
+				// Callers cannot provide the values of the hooks
+				_, err := s.CallWithDetails(context.Background(), &strand.RequestDetails{RequestID: "my-id", FunctionName: "myFn"}, []byte(`{"DB":{"Name":"attacker"},"RequestID":"attacker"}`))
+				is.NoErr(err)
+				is.Equal(received.DB, db)
+				is.Equal(received.RequestID, "my-id")
+				is.True(received.Context != nil)
+			},
+		},
+		{
+			Name: "lone struct with an interface field is the params",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.RegisterAs("myFn", func(params *struct {
+					Data interface{} `json:"data"`
+				}) (interface{}, error) {
+					return params.Data, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+				is.True(s.Export().Functions["myFn"].Params != nil)
+
+				data, err := s.Call("myFn", []byte(`{"data":"hi"}`))
+				is.NoErr(err)
+				is.Equal(data, "hi")
+			},
+		},
+		{
+			Name: "lone struct of a hook type is the params",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(idHook)
+				s.RegisterAs("myFn", func(params *struct{ Name string }) (string, error) {
+					return params.Name, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+				is.True(s.Export().Functions["myFn"].Params != nil)
+
+				data, err := s.Call("myFn", []byte(`{"Name":"hi"}`))
+				is.NoErr(err)
+				is.Equal(data, "hi")
+			},
+		},
+		{
+			Name: "lone interface is the params",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.RegisterAs("myFn", func(params interface{}) (interface{}, error) {
+					return params, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+
+				data, err := s.Call("myFn", []byte(`{"abc":"hi"}`))
+				is.NoErr(err)
+				is.Equal(data, map[string]interface{}{"abc": "hi"})
+			},
+		},
+		{
+			Name: "hook error is returned",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(func(*hoist.Request) (*HookDB, error) { return nil, hookErr })
+				s.HookTag("id", idHook)
+				s.RegisterAs("myFn", func(ctx *HookContext, params *MyParams) (*MyData, error) {
+					return nil, nil
+				})
+				is.Equal(len(s.Errors()), 0)
+
+				_, err := s.Call("myFn", []byte(`{}`))
+				is.True(errors.Is(err, hoist.ErrFunctionCallFailed))
+				is.True(errors.Is(err, hookErr))
+			},
+		},
+		{
+			Name: "field without a hook",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.HookTag("id", idHook)
+				s.RegisterAs("myFn", func(ctx *HookContext, params *MyParams) (*MyData, error) {
+					return nil, nil
+				})
+
+				is.Equal(len(s.Errors()), 1)
+				is.True(errors.Is(s.Errors()[0], hoist.ErrInvalidFunction))
+				is.True(errors.Is(s.Errors()[0], hoist.ErrContextFieldUnfillable))
+				is.Equal(len(s.Export().Functions), 0)
+			},
+		},
+		{
+			Name: "interface field without a hook",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.RegisterAs("myFn", func(ctx *struct {
+					context.Context
+					Extra interface{}
+				}, params *MyParams) (*MyData, error) {
+					return nil, nil
+				})
+
+				is.Equal(len(s.Errors()), 1)
+				is.True(errors.Is(s.Errors()[0], hoist.ErrContextFieldUnfillable))
+				is.True(strings.Contains(s.Errors()[0].Error(), "context field 'Extra'"))
+			},
+		},
+		{
+			Name: "tagged field without a hook",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.RegisterAs("myFn", func(ctx *HookContext, params *MyParams) (*MyData, error) {
+					return nil, nil
+				})
+
+				is.Equal(len(s.Errors()), 1)
+				is.True(errors.Is(s.Errors()[0], hoist.ErrContextFieldTagMismatch))
+			},
+		},
+		{
+			Name: "tagged hook with wrong type",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(dbHook)
+				s.HookTag("id", func(*hoist.Request) (int, error) { return 0, nil })
+				s.RegisterAs("myFn", func(ctx *HookContext, params *MyParams) (*MyData, error) {
+					return nil, nil
+				})
+
+				is.Equal(len(s.Errors()), 1)
+				is.True(errors.Is(s.Errors()[0], hoist.ErrContextFieldTagMismatch))
+			},
+		},
+		{
+			Name: "invalid hooks",
+			Check: func(is *is.I, s *hoist.Service) {
+				s.Hook(nil)
+				s.Hook(func(*hoist.Request) *HookDB { return nil })
+				s.HookTag("", idHook)
+
+				errs := s.Errors()
+				is.Equal(len(errs), 3)
+				is.True(errors.Is(errs[0], hoist.ErrInvalidHook))
+				is.True(errors.Is(errs[0], hoist.ErrHookNotFunction))
+				is.True(errors.Is(errs[1], hoist.ErrHookInvalidSignature))
+				is.True(errors.Is(errs[2], hoist.ErrHookMissingTag))
+			},
+		},
+	}
+
+	for _, entry := range table {
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			s := hoist.NewService(serviceName)
+			entry.Check(is, s)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package hoist
+
+// Invoker calls a function with the raw params, returning the data or error.
+// Interceptors use it to continue the call.
+type Invoker func(req *Request, rawParams []byte) (interface{}, error)
+
+// Interceptor runs around function calls.
+//
+// It can inspect the request and raw params, call next to continue the call, and change the
+// returned data or error. It can also short-circuit the call by returning without calling next.
+//
+// Errors returned by interceptors are treated the same as errors returned by the function.
+type Interceptor func(req *Request, rawParams []byte, next Invoker) (interface{}, error)
+
+// Intercept registers interceptors that run around every function call.
+//
+// Interceptors registered first run first, and service interceptors run before
+// interceptors registered for a single function with InterceptFunc.
+func (s *Service) Intercept(interceptors ...Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+// InterceptFunc registers interceptors that run around calls to the named function.
+//
+// Interceptors registered first run first, after the interceptors registered with Intercept.
+func (s *Service) InterceptFunc(fnName string, interceptors ...Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.funcInterceptors[fnName] = append(s.funcInterceptors[fnName], interceptors...)
+}
+
+// chain wraps the invoker with the interceptors for the named function.
+func (s *Service) chain(fnName string, invoke Invoker) Invoker {
+	s.mu.RLock()
+	interceptors := make([]Interceptor, 0, len(s.interceptors)+len(s.funcInterceptors[fnName]))
+	interceptors = append(interceptors, s.interceptors...)
+	interceptors = append(interceptors, s.funcInterceptors[fnName]...)
+	s.mu.RUnlock()
+
+	// Wrap from the inside out, so the first interceptor runs first
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := invoke
+
+		invoke = func(req *Request, rawParams []byte) (interface{}, error) {
+			return interceptor(req, rawParams, next)
+		}
+	}
+
+	return invoke
+}
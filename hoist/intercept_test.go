@@ -0,0 +1,104 @@
+package hoist_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/matryer/is"
+)
+
+func TestIntercept(t *testing.T) {
+	const serviceName = "myService"
+	var denied = errors.New("denied")
+
+	type EchoParams struct {
+		Message string
+	}
+
+	newService := func(calls *[]string) *hoist.Service {
+		s := hoist.NewService(serviceName)
+		s.RegisterAs("echo", func(ctx *MyCtx, params *EchoParams) (string, error) {
+			*calls = append(*calls, "echo")
+			return params.Message, nil
+		})
+		s.RegisterAs("other", func(ctx *MyCtx, params *EchoParams) (string, error) {
+			*calls = append(*calls, "other")
+			return params.Message, nil
+		})
+		return s
+	}
+
+	recorder := func(calls *[]string, name string) hoist.Interceptor {
+		return func(req *hoist.Request, rawParams []byte, next hoist.Invoker) (interface{}, error) {
+			*calls = append(*calls, fmt.Sprintf("%s:%s:%s:%s", name, req.ServiceName, req.FunctionName, rawParams))
+			return next(req, rawParams)
+		}
+	}
+
+	t.Run("runs service interceptors then function interceptors", func(t *testing.T) {
+		is := is.New(t)
+
+		var calls []string
+		s := newService(&calls)
+		s.InterceptFunc("echo", recorder(&calls, "fn"))
+		s.Intercept(recorder(&calls, "first"), recorder(&calls, "second"))
+
+		data, err := s.Call("echo", []byte(`{"Message":"hi"}`))
+		is.NoErr(err)
+		is.Equal(data, "hi")
+		is.Equal(calls, []string{
+			`first:myService:echo:{"Message":"hi"}`,
+			`second:myService:echo:{"Message":"hi"}`,
+			`fn:myService:echo:{"Message":"hi"}`,
+			"echo",
+		})
+
+		calls = nil
+		_, err = s.Call("other", []byte(`{}`))
+		is.NoErr(err)
+		is.Equal(calls, []string{
+			`first:myService:other:{}`,
+			`second:myService:other:{}`,
+			"other",
+		})
+	})
+
+	t.Run("short-circuits the call", func(t *testing.T) {
+		is := is.New(t)
+
+		var calls []string
+		s := newService(&calls)
+		s.Intercept(func(req *hoist.Request, rawParams []byte, next hoist.Invoker) (interface{}, error) {
+			return nil, denied
+		})
+
+		_, err := s.Call("echo", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrFunctionCallFailed))
+		is.True(errors.Is(err, denied))
+		is.Equal(len(calls), 0)
+	})
+
+	t.Run("changes the params, result and error", func(t *testing.T) {
+		is := is.New(t)
+
+		var calls []string
+		s := newService(&calls)
+		s.InterceptFunc("echo", func(req *hoist.Request, rawParams []byte, next hoist.Invoker) (interface{}, error) {
+			data, err := next(req, []byte(`{"Message":"changed"}`))
+			return data.(string) + "!", err
+		})
+		s.InterceptFunc("other", func(req *hoist.Request, rawParams []byte, next hoist.Invoker) (interface{}, error) {
+			_, err := next(req, []byte(`not-json`))
+			return nil, fmt.Errorf("wrapped: %w", err)
+		})
+
+		data, err := s.Call("echo", []byte(`{"Message":"hi"}`))
+		is.NoErr(err)
+		is.Equal(data, "changed!")
+
+		_, err = s.Call("other", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrFunctionCallJSONUnmarshal))
+	})
+}
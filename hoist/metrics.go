@@ -0,0 +1,250 @@
+package hoist
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+)
+
+var ErrMetricsBucketInvalid = erk.New(ErkHoistInit{}, "service '{{.serviceName}}' has metrics bucket {{.bound}}, but bounds must be finite and distinct")
+
+// DefaultMetricsPath is where the service serves its metrics, unless WithMetricsPath is provided.
+const DefaultMetricsPath = "/_/v1/metrics"
+
+// DefaultMetricsBuckets are the upper bounds of the call duration histogram in seconds,
+// unless WithMetricsBuckets is provided.
+var DefaultMetricsBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Outcomes of calls, used as the outcome label of metrics.
+const (
+	OutcomeOK            = "ok"
+	OutcomeUserError     = "user_error"
+	OutcomeInternalError = "internal_error"
+	OutcomeNotFound      = "not_found"
+	OutcomeDecodeError   = "decode_error"
+)
+
+// metrics of the calls to a service, which are written in the Prometheus text format.
+//
+// Calls are counted by function and outcome, and their durations are observed in a histogram.
+// Calls to functions that do not exist, and requests that cannot be decoded, are only counted,
+// with an empty function label, so callers cannot create a series for every name they send.
+type metrics struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[seriesKey]*series
+}
+
+type seriesKey struct {
+	function string
+	outcome  string
+}
+
+// series of the metrics for a function and outcome.
+type series struct {
+	count        uint64
+	observed     uint64
+	sum          float64
+	bucketCounts []uint64 // not cumulative, unlike the exposition format
+}
+
+// validBuckets returns the sorted bounds without the ones that would produce an invalid or duplicate le label,
+// and the first of those bounds, if any.
+func validBuckets(sorted []float64) ([]float64, float64, bool) {
+	valid := make([]float64, 0, len(sorted))
+	var invalid float64
+	var hasInvalid bool
+	for _, bound := range sorted {
+		if math.IsInf(bound, 0) || math.IsNaN(bound) || (len(valid) > 0 && bound == valid[len(valid)-1]) {
+			if !hasInvalid {
+				invalid, hasInvalid = bound, true
+			}
+
+			continue
+		}
+
+		valid = append(valid, bound)
+	}
+
+	return valid, invalid, hasInvalid
+}
+
+func newMetrics(buckets []float64) *metrics {
+	return &metrics{
+		buckets: buckets,
+		series:  make(map[seriesKey]*series),
+	}
+}
+
+// observe a call to the function, which took the duration.
+func (m *metrics) observe(function, outcome string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ser := m.seriesFor(function, outcome)
+	ser.count++
+	ser.observed++
+	ser.sum += duration.Seconds()
+
+	// Observations above every bucket are only in the +Inf bucket, which is the observed count
+	i := sort.SearchFloat64s(m.buckets, duration.Seconds())
+	if i < len(ser.bucketCounts) {
+		ser.bucketCounts[i]++
+	}
+}
+
+// count a request without observing its duration, such as one that cannot be decoded.
+func (m *metrics) count(function, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seriesFor(function, outcome).count++
+}
+
+func (m *metrics) seriesFor(function, outcome string) *series {
+	key := seriesKey{function: function, outcome: outcome}
+	ser, ok := m.series[key]
+	if !ok {
+		ser = &series{bucketCounts: make([]uint64, len(m.buckets))}
+		m.series[key] = ser
+	}
+
+	return ser
+}
+
+// write the metrics in the Prometheus text exposition format, labelled with the service name.
+func (m *metrics) write(w io.Writer, serviceName string) error {
+	m.mu.Lock()
+	keys := make([]seriesKey, 0, len(m.series))
+	snapshot := make(map[seriesKey]series, len(m.series))
+	for key, ser := range m.series {
+		keys = append(keys, key)
+		snapshot[key] = series{
+			count:        ser.count,
+			observed:     ser.observed,
+			sum:          ser.sum,
+			bucketCounts: append([]uint64(nil), ser.bucketCounts...),
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].function != keys[j].function {
+			return keys[i].function < keys[j].function
+		}
+
+		return keys[i].outcome < keys[j].outcome
+	})
+
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("# HELP hoist_calls_total Calls to functions, by outcome.\n")
+	bw.WriteString("# TYPE hoist_calls_total counter\n")
+	for _, key := range keys {
+		writeSample(bw, "hoist_calls_total", labels(serviceName, key), "", snapshot[key].count)
+	}
+
+	bw.WriteString("# HELP hoist_call_duration_seconds How long calls to functions took, by outcome.\n")
+	bw.WriteString("# TYPE hoist_call_duration_seconds histogram\n")
+	for _, key := range keys {
+		ser := snapshot[key]
+		if ser.observed == 0 {
+			continue
+		}
+
+		seriesLabels := labels(serviceName, key)
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += ser.bucketCounts[i]
+			le := `,le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"`
+			writeSample(bw, "hoist_call_duration_seconds_bucket", seriesLabels, le, cumulative)
+		}
+		writeSample(bw, "hoist_call_duration_seconds_bucket", seriesLabels, `,le="+Inf"`, ser.observed)
+
+		bw.WriteString("hoist_call_duration_seconds_sum{" + seriesLabels + "} " + strconv.FormatFloat(ser.sum, 'g', -1, 64) + "\n")
+		writeSample(bw, "hoist_call_duration_seconds_count", seriesLabels, "", ser.observed)
+	}
+
+	return bw.Flush()
+}
+
+func writeSample(bw *bufio.Writer, name, labels, extraLabels string, value uint64) {
+	bw.WriteString(name + "{" + labels + extraLabels + "} " + strconv.FormatUint(value, 10) + "\n")
+}
+
+func labels(serviceName string, key seriesKey) string {
+	return `service="` + escapeLabel(serviceName) + `",function="` + escapeLabel(key.function) + `",outcome="` + key.outcome + `"`
+}
+
+// labelEscaper escapes label values, as required by the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// callOutcome classifies the error returned by a call, for the outcome label.
+// Errors returned by functions are user errors, unless they are sent to callers as internal errors.
+func (s *Service) callOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case isDecodeError(err):
+		return OutcomeDecodeError
+	}
+
+	if isInternalError(err) {
+		return OutcomeInternalError
+	}
+
+	return OutcomeUserError
+}
+
+// isDecodeError returns true if the error was caused by params that could not be decoded.
+func isDecodeError(err error) bool {
+	for _, decodeErr := range []error{ErrFunctionCallJSONUnmarshal, ErrFunctionCallUnmarshal, ErrParamsUnknownField, ErrParamsTrailingData, ErrParamsNull} {
+		if errors.Is(err, decodeErr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countDecodeError counts a request that could not be decoded, so it did not call a function.
+func (s *Service) countDecodeError() {
+	s.metrics.count("", OutcomeDecodeError)
+}
+
+// countNotFound counts a call to a function that does not exist.
+func (s *Service) countNotFound() {
+	s.metrics.count("", OutcomeNotFound)
+}
+
+// WriteMetrics writes the metrics of the calls to the service in the Prometheus text exposition format.
+// They are also served at the path set by WithMetricsPath.
+//
+// The metrics are:
+//  hoist_calls_total{service, function, outcome}: counter of calls
+//  hoist_call_duration_seconds{service, function, outcome}: histogram of call durations
+//
+// The outcome is one of OutcomeOK, OutcomeUserError, OutcomeInternalError, OutcomeNotFound, or OutcomeDecodeError.
+// Calls to functions that do not exist, and requests that cannot be decoded, are only counted, with an empty function.
+func (s *Service) WriteMetrics(w io.Writer) error {
+	return s.metrics.write(w, s.name)
+}
+
+func (s *Service) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.WriteMetrics(w)
+}
@@ -0,0 +1,178 @@
+package hoist_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/matryer/is"
+)
+
+func TestMetrics(t *testing.T) {
+	fns := map[string]interface{}{
+		"echo": echoParams,
+		"fail": func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			return nil, errors.New("failed")
+		},
+		"panic": func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			panic("oops")
+		},
+	}
+	buckets := hoist.WithMetricsBuckets(60, 0)
+
+	t.Run("counts calls by function and outcome", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newTestService(fns, buckets)
+		s.Call("echo", []byte(`{}`))
+		s.Call("echo", []byte(`{}`))
+		s.Call("echo", []byte(`{`))
+		s.Call("fail", []byte(`{}`))
+		s.Call("panic", []byte(`{}`))
+		s.Call("missing", []byte(`{}`))
+
+		var buf bytes.Buffer
+		is.NoErr(s.WriteMetrics(&buf))
+		metrics := buf.String()
+
+		for _, line := range []string{
+			"# TYPE hoist_calls_total counter\n",
+			`hoist_calls_total{service="abc",function="",outcome="not_found"} 1` + "\n",
+			`hoist_calls_total{service="abc",function="echo",outcome="decode_error"} 1` + "\n",
+			`hoist_calls_total{service="abc",function="echo",outcome="ok"} 2` + "\n",
+			`hoist_calls_total{service="abc",function="fail",outcome="user_error"} 1` + "\n",
+			`hoist_calls_total{service="abc",function="panic",outcome="internal_error"} 1` + "\n",
+			"# TYPE hoist_call_duration_seconds histogram\n",
+			`hoist_call_duration_seconds_bucket{service="abc",function="echo",outcome="ok",le="0"} 0` + "\n",
+			`hoist_call_duration_seconds_bucket{service="abc",function="echo",outcome="ok",le="60"} 2` + "\n",
+			`hoist_call_duration_seconds_bucket{service="abc",function="echo",outcome="ok",le="+Inf"} 2` + "\n",
+			`hoist_call_duration_seconds_sum{service="abc",function="echo",outcome="ok"} `,
+			`hoist_call_duration_seconds_count{service="abc",function="echo",outcome="ok"} 2` + "\n",
+		} {
+			is.True(strings.Contains(metrics, line)) // metrics contain line
+		}
+
+		// Calls that did not reach a function are only counted
+		is.True(!strings.Contains(metrics, `hoist_call_duration_seconds_count{service="abc",function="",outcome="not_found"}`))
+	})
+
+	bucketsTable := []struct {
+		Name    string
+		Buckets []float64
+		Bound   float64
+	}{
+		{Name: "with duplicate bucket", Buckets: []float64{1, 0.5, 1}, Bound: 1},
+		{Name: "with +Inf bucket", Buckets: []float64{1, math.Inf(1)}, Bound: math.Inf(1)},
+		{Name: "with -Inf bucket", Buckets: []float64{1, math.Inf(-1)}, Bound: math.Inf(-1)},
+	}
+
+	for _, entry := range bucketsTable {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			s := newTestService(fns, hoist.WithMetricsBuckets(entry.Buckets...))
+			errs := s.Errors()
+			is.Equal(len(errs), 1)
+			is.True(errors.Is(errs[0], hoist.ErrMetricsBucketInvalid))
+			is.Equal(erk.GetParams(errs[0])["bound"], entry.Bound)
+
+			// The invalid bounds are not written
+			s.Call("echo", []byte(`{}`))
+			var buf bytes.Buffer
+			is.NoErr(s.WriteMetrics(&buf))
+			is.Equal(strings.Count(buf.String(), `hoist_call_duration_seconds_bucket{service="abc",function="echo",outcome="ok",le="1"}`), 1)
+			is.Equal(strings.Count(buf.String(), `le="+Inf"`), 1)
+			is.True(!strings.Contains(buf.String(), `le="-Inf"`))
+		})
+	}
+
+	t.Run("escapes label values", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService(`a"b\c`)
+		s.Call("missing", nil)
+
+		var buf bytes.Buffer
+		is.NoErr(s.WriteMetrics(&buf))
+		is.True(strings.Contains(buf.String(), `hoist_calls_total{service="a\"b\\c",function="",outcome="not_found"} 1`))
+	})
+
+	serve := func(t *testing.T, opts ...hoist.Option) (addr string, client *http.Client, stop func()) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := newTestService(fns, append([]hoist.Option{buckets, hoist.WithListener(listener)}, opts...)...)
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(ctx) }()
+
+		// Idle connections are closed before stopping, so the server does not wait for them
+		transport := &http.Transport{}
+		return "http://" + listener.Addr().String(), &http.Client{Transport: transport}, func() {
+			transport.CloseIdleConnections()
+			cancel()
+			<-serveErr
+		}
+	}
+
+	t.Run("serves metrics over HTTP", func(t *testing.T) {
+		is := is.New(t)
+
+		addr, client, stop := serve(t)
+		defer stop()
+
+		// Frames that cannot be decoded are counted
+		resp, err := client.Post(addr+"/_/v1/fn", "", strings.NewReader("x:"))
+		is.NoErr(err)
+		resp.Body.Close()
+
+		resp, err = client.Get(addr + "/_/v1/metrics")
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		is.NoErr(err)
+		is.Equal(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+		is.True(strings.Contains(string(body), `hoist_calls_total{service="abc",function="",outcome="decode_error"} 1`))
+	})
+
+	t.Run("WithMetricsPath", func(t *testing.T) {
+		is := is.New(t)
+
+		addr, client, stop := serve(t, hoist.WithMetricsPath("/metrics"))
+		defer stop()
+
+		resp, err := client.Get(addr + "/metrics")
+		is.NoErr(err)
+		resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusOK)
+
+		resp, err = client.Get(addr + "/_/v1/metrics")
+		is.NoErr(err)
+		resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("without metrics path", func(t *testing.T) {
+		is := is.New(t)
+
+		addr, client, stop := serve(t, hoist.WithMetricsPath(""))
+		defer stop()
+
+		resp, err := client.Get(addr + "/_/v1/metrics")
+		is.NoErr(err)
+		resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusNotFound)
+	})
+}
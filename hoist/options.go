@@ -0,0 +1,288 @@
+package hoist
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hoistup/hoist-go/websocket"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+// Option configures a Service.
+type Option func(*options)
+
+type options struct {
+	addr            string
+	host            string
+	listener        net.Listener
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	maxHeaderBytes  int
+	maxBodyBytes    int64
+	decoderOptions  []wire.DecoderOption
+	compressionMin  int
+	maxConnCalls    int
+	maxBatchCalls   int
+	shutdownTimeout time.Duration
+	paramsDecoding  ParamsDecoding
+
+	webSocketPingInterval time.Duration
+	webSocketOrigins      []string
+
+	metricsPath    string
+	metricsBuckets []float64
+
+	maxRawErrorBytes  int
+	errorParamsPolicy ErrorParamsPolicy
+}
+
+// defaultOptions are the settings Hoist deployments expect.
+func defaultOptions() options {
+	return options{
+		host:            "localhost",
+		readTimeout:     30 * time.Second,
+		writeTimeout:    30 * time.Second,
+		idleTimeout:     60 * time.Second,
+		maxHeaderBytes:  250,
+		shutdownTimeout: DefaultShutdownTimeout,
+		compressionMin:  wire.DefaultCompressionMinBytes,
+		maxConnCalls:    DefaultMaxConnCalls,
+		maxBatchCalls:   DefaultMaxBatchCalls,
+
+		webSocketPingInterval: DefaultWebSocketPingInterval,
+
+		metricsPath:    DefaultMetricsPath,
+		metricsBuckets: DefaultMetricsBuckets,
+
+		maxRawErrorBytes:  DefaultMaxRawErrorBytes,
+		errorParamsPolicy: AllowAllErrorParams,
+	}
+}
+
+// WithAddr sets the address the service listens on, for example ":8080".
+// By default, the service listens on localhost, at the port in the PORT environment variable.
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// WithHost sets the host the service listens on, while still using the port in the PORT environment variable.
+// For example, use "0.0.0.0" to be reachable from outside a container. Defaults to "localhost".
+func WithHost(host string) Option {
+	return func(o *options) {
+		o.host = host
+	}
+}
+
+// WithListener serves the service on the provided listener, instead of listening on an address.
+// The listener is closed when the service shuts down, or when serving fails,
+// so serving again after a failure needs a new service with a new listener.
+// It is not closed when Serve returns ErrAlreadyServing or ErrShuttingDown.
+func WithListener(listener net.Listener) Option {
+	return func(o *options) {
+		o.listener = listener
+	}
+}
+
+// WithReadTimeout sets the maximum duration for reading an entire request. Defaults to 30 seconds.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the maximum duration for writing a response. Defaults to 30 seconds.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+// WithIdleTimeout sets how long idle keep-alive connections are kept open. Defaults to 60 seconds.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = timeout
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of request headers. Defaults to 250 bytes.
+func WithMaxHeaderBytes(size int) Option {
+	return func(o *options) {
+		o.maxHeaderBytes = size
+	}
+}
+
+// WithMaxBodyBytes sets the maximum size of request bodies. Defaults to no limit.
+// Larger bodies fail with ErrBodyTooLarge, which is sent to the caller as a non-internal error.
+func WithMaxBodyBytes(size int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = size
+	}
+}
+
+// WithDecoderOptions limits the size of the parts of requests, such as wire.WithMaxParamsBytes.
+// Defaults to the wire.NewDecoder defaults.
+// Requests over the limits fail with a wire.ErkFrameTooLarge error, which is not internal.
+func WithDecoderOptions(opts ...wire.DecoderOption) Option {
+	return func(o *options) {
+		o.decoderOptions = append(o.decoderOptions, opts...)
+	}
+}
+
+// WithCompressionMinBytes sets the size results must reach before they are compressed.
+// Defaults to wire.DefaultCompressionMinBytes. Use a negative size to never compress results.
+//
+// Results are only compressed when the request lists a registered compression in its AcceptCompression details.
+// Compressed requests are always accepted, and decompressed within the limits set by WithDecoderOptions.
+func WithCompressionMinBytes(size int) Option {
+	return func(o *options) {
+		o.compressionMin = size
+	}
+}
+
+// WithMaxConnCalls sets how many calls each persistent connection served by ServeConn runs at once.
+// Defaults to DefaultMaxConnCalls. Values below one are treated as one.
+func WithMaxConnCalls(calls int) Option {
+	return func(o *options) {
+		if calls < 1 {
+			calls = 1
+		}
+
+		o.maxConnCalls = calls
+	}
+}
+
+// WithMaxBatchCalls sets how many calls of each batch request run at once.
+// Defaults to DefaultMaxBatchCalls. Values below one are treated as one.
+func WithMaxBatchCalls(calls int) Option {
+	return func(o *options) {
+		if calls < 1 {
+			calls = 1
+		}
+
+		o.maxBatchCalls = calls
+	}
+}
+
+// WithWebSocketPingInterval sets how often WebSocket connections are pinged to keep them alive.
+// Connections that send nothing for two intervals are closed. Defaults to DefaultWebSocketPingInterval.
+// Use zero to never ping, and never close idle connections.
+func WithWebSocketPingInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.webSocketPingInterval = interval
+	}
+}
+
+// WithWebSocketOrigins allows pages from the origins, such as "https://app.example.com", to open WebSocket connections.
+// Use "*" to allow any origin. By default, only pages served from the host of the service are allowed,
+// so other sites cannot call functions with the cookies of the user.
+func WithWebSocketOrigins(origins ...string) Option {
+	return func(o *options) {
+		o.webSocketOrigins = append(o.webSocketOrigins, origins...)
+	}
+}
+
+// WithMetricsPath sets where the service serves its metrics (see Service.WriteMetrics).
+// Defaults to DefaultMetricsPath. Use an empty path to not serve metrics.
+func WithMetricsPath(path string) Option {
+	return func(o *options) {
+		o.metricsPath = path
+	}
+}
+
+// WithMetricsBuckets sets the upper bounds of the call duration histogram, in seconds.
+// Defaults to DefaultMetricsBuckets. Bounds must be finite and distinct; others are dropped,
+// and reported by Service.Errors as ErrMetricsBucketInvalid.
+func WithMetricsBuckets(buckets ...float64) Option {
+	return func(o *options) {
+		o.metricsBuckets = append([]float64(nil), buckets...)
+		sort.Float64s(o.metricsBuckets)
+	}
+}
+
+// WithShutdownTimeout sets how long ServeContext waits for in-flight calls when shutting down.
+// Defaults to DefaultShutdownTimeout.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = timeout
+	}
+}
+
+// WithParamsDecoding sets how the params of every function are decoded. Defaults to the zero ParamsDecoding.
+// Functions can override it with FuncParamsDecoding.
+func WithParamsDecoding(decoding ParamsDecoding) Option {
+	return func(o *options) {
+		o.paramsDecoding = decoding
+	}
+}
+
+// WithMaxRawErrorBytes sets the length raw payloads are cut to when included in errors,
+// such as the originalJSON param of ErrFunctionCallJSONUnmarshal. Defaults to DefaultMaxRawErrorBytes.
+// Use zero to always replace them with Redacted.
+//
+// Fields of the params tagged with `hoist:"secret"` are replaced with Redacted before cutting the payload.
+// If the payload is invalid JSON, and the params have secret fields, it is replaced with Redacted.
+// Payloads in other encodings, such as the originalParams param of ErrFunctionCallUnmarshal,
+// are converted to JSON first, and replaced with Redacted if they are invalid.
+func WithMaxRawErrorBytes(size int) Option {
+	return func(o *options) {
+		o.maxRawErrorBytes = size
+	}
+}
+
+// WithErrorParamsPolicy sets which error params may be sent to callers. Defaults to AllowAllErrorParams.
+func WithErrorParamsPolicy(policy ErrorParamsPolicy) Option {
+	return func(o *options) {
+		o.errorParamsPolicy = policy
+	}
+}
+
+// FuncOption configures a single function registered with RegisterAs.
+type FuncOption func(*funcOptions)
+
+type funcOptions struct {
+	paramsDecoding *ParamsDecoding
+}
+
+// FuncParamsDecoding sets how the params of the function are decoded, instead of using the service setting.
+func FuncParamsDecoding(decoding ParamsDecoding) FuncOption {
+	return func(o *funcOptions) {
+		o.paramsDecoding = &decoding
+	}
+}
+
+// checkWebSocketOrigin returns true if the origin of the request is allowed to open a WebSocket connection.
+func (o *options) checkWebSocketOrigin(r *http.Request) bool {
+	if websocket.SameOrigin(r) {
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	for _, allowed := range o.webSocketOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// address returns the address to listen on.
+func (o *options) address() (string, error) {
+	if o.addr != "" {
+		return o.addr, nil
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		return "", ErrPortMissing
+	}
+
+	return net.JoinHostPort(o.host, port), nil
+}
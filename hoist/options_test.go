@@ -0,0 +1,228 @@
+package hoist_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+	"github.com/phayes/freeport"
+)
+
+var ErrLoginFailed = erk.New(ErkError{}, "could not log in {{.user}} with {{.token}}")
+
+func TestOptions(t *testing.T) {
+	serve := func(s *hoist.Service) (stop func() error) {
+		serveErr := make(chan error, 1)
+		go func() { serveErr <- s.Serve() }()
+
+		return func() error {
+			if err := s.Shutdown(context.Background()); err != nil {
+				return err
+			}
+			return <-serveErr
+		}
+	}
+
+	post := func(url string, body []byte) (*wire.DecodeResult, error) {
+		resp, err := http.Post(url+"/_/v1/fn", "", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		return wire.NewDecoder(resp.Body).Decode()
+	}
+
+	echoRequest := func(message string) []byte {
+		body, err := wire.Encode(&strand.RequestDetails{RequestID: reqID, FunctionName: "echo"}, &TestParams{Message: message})
+		if err != nil {
+			panic(err)
+		}
+		return body
+	}
+
+	// Frames that are too large are the caller's fault, so their errors are not internal
+	isBadRequest := func(is *is.I, strands *wire.DecodeResult) {
+		var details strand.ResponseDetails
+		is.NoErr(json.Unmarshal(strands.RawDetails, &details))
+		is.Equal(details, strand.ResponseDetails{IsError: true})
+	}
+
+	fns := map[string]interface{}{"echo": echoParams}
+
+	t.Run("WithListener", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+
+		originalPORT := os.Getenv("PORT")
+		os.Unsetenv("PORT")
+		defer os.Setenv("PORT", originalPORT)
+
+		s := newTestService(fns, hoist.WithListener(listener))
+		stop := serve(s)
+
+		strands, err := post("http://"+listener.Addr().String(), echoRequest("hi"))
+		is.NoErr(err)
+		is.Equal(string(strands.RawParams), `{"Message":"hi"}`)
+		is.NoErr(stop())
+	})
+
+	t.Run("WithAddr", func(t *testing.T) {
+		is := is.New(t)
+
+		port, err := freeport.GetFreePort()
+		is.NoErr(err)
+		addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+		s := newTestService(fns, hoist.WithAddr(addr))
+		stop := serve(s)
+		waitForAddr(addr)
+
+		strands, err := post("http://"+addr, echoRequest("hi"))
+		is.NoErr(err)
+		is.Equal(string(strands.RawParams), `{"Message":"hi"}`)
+		is.NoErr(stop())
+	})
+
+	t.Run("WithHost", setPORT(func(t *testing.T) {
+		is := is.New(t)
+
+		s := newTestService(fns, hoist.WithHost("127.0.0.1"))
+		stop := serve(s)
+		addr := "127.0.0.1:" + os.Getenv("PORT")
+		waitForAddr(addr)
+
+		strands, err := post("http://"+addr, echoRequest("hi"))
+		is.NoErr(err)
+		is.Equal(string(strands.RawParams), `{"Message":"hi"}`)
+		is.NoErr(stop())
+	}))
+
+	t.Run("WithMaxBodyBytes", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+
+		s := newTestService(fns, hoist.WithListener(listener), hoist.WithMaxBodyBytes(64))
+		stop := serve(s)
+
+		strands, err := post("http://"+listener.Addr().String(), echoRequest("small"))
+		is.NoErr(err)
+		is.Equal(string(strands.RawParams), `{"Message":"small"}`)
+
+		strands, err = post("http://"+listener.Addr().String(), echoRequest(strings.Repeat("large", 20)))
+		is.NoErr(err)
+		errEqual(is, strands, hoist.ErrBodyTooLarge, "request body is larger than the maximum of 64 bytes")
+		isBadRequest(is, strands)
+		is.NoErr(stop())
+	})
+
+	t.Run("WithDecoderOptions", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+
+		s := newTestService(fns, hoist.WithListener(listener), hoist.WithDecoderOptions(wire.WithMaxParamsBytes(32)))
+		stop := serve(s)
+
+		strands, err := post("http://"+listener.Addr().String(), echoRequest("small"))
+		is.NoErr(err)
+		is.Equal(string(strands.RawParams), `{"Message":"small"}`)
+
+		strands, err = post("http://"+listener.Addr().String(), echoRequest(strings.Repeat("large", 20)))
+		is.NoErr(err)
+		errEqual(is, strands, wire.ErrParamsTooLarge, "params length 114 is larger than the maximum of 32 bytes")
+		isBadRequest(is, strands)
+		is.NoErr(stop())
+	})
+
+	t.Run("WithCompressionMinBytes", func(t *testing.T) {
+		is := is.New(t)
+
+		compressedEcho := func(url string) *wire.DecodeResult {
+			var body bytes.Buffer
+			reqDetails := &strand.RequestDetails{RequestID: reqID, FunctionName: "echo", AcceptCompression: []wire.Compression{wire.CompressionGzip}}
+			err := wire.NewEncoder(&body, wire.WithCompression(wire.CompressionGzip, 0)).Encode(reqDetails, &TestParams{Message: strings.Repeat("abc", 200)})
+			is.NoErr(err)
+
+			strands, err := post(url, body.Bytes())
+			is.NoErr(err)
+			is.Equal(string(strands.RawParams), `{"Message":"`+strings.Repeat("abc", 200)+`"}`)
+			return strands
+		}
+
+		for _, entry := range []struct {
+			MinBytes            int
+			ExpectedCompression wire.Compression
+		}{
+			{MinBytes: 16, ExpectedCompression: wire.CompressionGzip},
+			{MinBytes: -1, ExpectedCompression: wire.CompressionNone},
+		} {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			is.NoErr(err)
+
+			s := newTestService(fns, hoist.WithListener(listener), hoist.WithCompressionMinBytes(entry.MinBytes))
+			stop := serve(s)
+
+			strands := compressedEcho("http://" + listener.Addr().String())
+			is.Equal(strands.Compression, entry.ExpectedCompression)
+
+			var respDetails strand.ResponseDetails
+			is.NoErr(json.Unmarshal(strands.RawDetails, &respDetails))
+			is.Equal(respDetails.AcceptCompression, wire.Compressions())
+			is.NoErr(stop())
+		}
+	})
+
+	t.Run("WithErrorParamsPolicy", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+
+		s := newTestService(fns, hoist.WithListener(listener), hoist.WithErrorParamsPolicy(hoist.AllowErrorParams("user")))
+		s.RegisterAs("login", func(ctx *TestContext, params *TestParams) error {
+			return erk.WithParams(ErrLoginFailed, erk.Params{"user": "abc", "token": "secret"})
+		})
+		stop := serve(s)
+
+		body, err := wire.Encode(&strand.RequestDetails{RequestID: reqID, FunctionName: "login"}, &TestParams{})
+		is.NoErr(err)
+
+		strands, err := post("http://"+listener.Addr().String(), body)
+		is.NoErr(err)
+
+		var respErr erk.ExportedError
+		is.NoErr(json.Unmarshal(strands.RawParams, &respErr))
+		is.Equal(respErr.Message, "could not log in abc with [REDACTED]")
+		is.Equal(respErr.Params, erk.Params{"user": "abc", "token": hoist.Redacted})
+		is.NoErr(stop())
+	})
+
+	t.Run("with address in use", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+		defer listener.Close()
+
+		s := newTestService(fns, hoist.WithAddr(listener.Addr().String()))
+		is.True(errors.Is(s.Serve(), hoist.ErrListening))
+	})
+}
@@ -0,0 +1,46 @@
+package hoist
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+)
+
+type ErkFunctionPanicked struct{ erks.Default }
+
+var ErrFunctionPanicked = erk.New(ErkFunctionPanicked{}, "function '{{.fnName}}' panicked: {{.err}}")
+
+// PanicError is wrapped by ErrFunctionPanicked when a function or interceptor panics.
+//
+// Interceptors can log the stack trace using errors.As.
+// Only the panic value is included in the error message, so the stack trace is never sent to the caller.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error returns the panic value.
+func (p *PanicError) Error() string {
+	return fmt.Sprint(p.Value)
+}
+
+// recoverPanic converts a panic into an ErrFunctionPanicked error.
+// It must be deferred directly.
+func recoverPanic(fnName string, err *error) {
+	if r := recover(); r != nil {
+		*err = erk.WrapAs(erk.WithParam(ErrFunctionPanicked, "fnName", fnName), &PanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		})
+	}
+}
+
+// recoverInvoker wraps the invoker, so panics are returned as errors to the interceptors.
+func recoverInvoker(invoke Invoker) Invoker {
+	return func(req *Request, rawParams []byte) (data interface{}, err error) {
+		defer recoverPanic(req.FunctionName, &err)
+		return invoke(req, rawParams)
+	}
+}
@@ -0,0 +1,59 @@
+package hoist_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/matryer/is"
+)
+
+func TestPanics(t *testing.T) {
+	const serviceName = "myService"
+
+	t.Run("function panic", func(t *testing.T) {
+		is := is.New(t)
+
+		var interceptedErr error
+		s := hoist.NewService(serviceName)
+		s.Intercept(func(req *hoist.Request, rawParams []byte, next hoist.Invoker) (interface{}, error) {
+			data, err := next(req, rawParams)
+			interceptedErr = err
+			return data, err
+		})
+		s.RegisterAs("myFunc", func(*MyCtx, *MyParams) (*MyData, error) {
+			panic("boom")
+		})
+
+		_, err := s.Call("myFunc", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrFunctionPanicked))
+		is.True(errors.Is(interceptedErr, hoist.ErrFunctionPanicked))
+
+		// The stack trace is available to interceptors
+		var panicErr *hoist.PanicError
+		is.True(errors.As(interceptedErr, &panicErr))
+		is.Equal(panicErr.Value, "boom")
+		is.Equal(panicErr.Error(), "boom")
+		is.True(strings.Contains(string(panicErr.Stack), "panic_test.go"))
+	})
+
+	t.Run("interceptor panic", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService(serviceName)
+		s.Intercept(func(req *hoist.Request, rawParams []byte, next hoist.Invoker) (interface{}, error) {
+			panic(errors.New("interceptor boom"))
+		})
+		s.RegisterAs("myFunc", func(*MyCtx, *MyParams) (*MyData, error) {
+			return nil, nil
+		})
+
+		_, err := s.Call("myFunc", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrFunctionPanicked))
+
+		var panicErr *hoist.PanicError
+		is.True(errors.As(err, &panicErr))
+		is.Equal(panicErr.Error(), "interceptor boom")
+	})
+}
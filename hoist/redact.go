@@ -0,0 +1,216 @@
+package hoist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/internal/jsonfields"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+// Redacted replaces values that must not be included in errors.
+const Redacted = "[REDACTED]"
+
+// DefaultMaxRawErrorBytes is the default length raw payloads are cut to when included in errors.
+const DefaultMaxRawErrorBytes = 256
+
+// ErrorParamsPolicy decides whether an error param may be sent to callers,
+// given the kind of the error (see erk.GetKindString) and the key of the param.
+// Params that are not allowed are replaced with Redacted, including where they appear in the error message.
+type ErrorParamsPolicy func(kind, key string) bool
+
+// AllowAllErrorParams sends every error param to callers. It is the default policy.
+func AllowAllErrorParams(kind, key string) bool {
+	return true
+}
+
+// AllowNoErrorParams never sends error params to callers.
+func AllowNoErrorParams(kind, key string) bool {
+	return false
+}
+
+// AllowErrorParams only sends the params with the provided keys to callers.
+func AllowErrorParams(keys ...string) ErrorParamsPolicy {
+	return func(kind, key string) bool {
+		return contains(keys, key)
+	}
+}
+
+// redactor masks the secret fields of raw params, and cuts them to a maximum length.
+type redactor struct {
+	paramsType reflect.Type
+	maxBytes   int
+	structs    map[reflect.Type]*redactedStruct
+	hasSecret  bool
+}
+
+// redactedStruct is a struct reachable from the params type.
+type redactedStruct struct {
+	fields  []jsonfields.Field
+	secrets map[string]bool // By JSON name
+}
+
+// newRedactor finds the secret fields of every struct reachable from the params type.
+// Validation rules are checked when registering, so they are valid here.
+func newRedactor(paramsType reflect.Type, maxBytes int) *redactor {
+	r := &redactor{
+		paramsType: paramsType,
+		maxBytes:   maxBytes,
+		structs:    make(map[reflect.Type]*redactedStruct),
+	}
+	r.compile(paramsType)
+
+	return r
+}
+
+func (r *redactor) compile(t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		r.compile(t.Elem())
+
+	case reflect.Struct:
+		if _, ok := r.structs[t]; ok {
+			return
+		}
+
+		rs := &redactedStruct{fields: jsonfields.Of(t), secrets: make(map[string]bool)}
+		r.structs[t] = rs // Add before compiling fields, to support recursive types
+
+		for _, field := range rs.fields {
+			if rules, _ := parseRules(field.StructField, field.Name); rules != nil && rules.secret {
+				rs.secrets[field.Name] = true
+				r.hasSecret = true
+			}
+
+			r.compile(field.Type)
+		}
+	}
+}
+
+// redact the raw params in the wire encoding, so they can be included in errors.
+// Params that are not JSON encoded are converted to JSON.
+func (r *redactor) redact(rawParams []byte, encoding wire.Encoding) string {
+	if r.maxBytes <= 0 {
+		return Redacted
+	}
+
+	raw := rawParams
+	if r.hasSecret || encoding != wire.EncodingJSON {
+		// Secrets cannot be found in invalid params, so they are not included
+		var value interface{}
+		if err := unmarshalGeneric(rawParams, encoding, &value); err != nil {
+			return Redacted
+		}
+
+		var err error
+		raw, err = json.Marshal(r.mask(value, r.paramsType))
+		if err != nil {
+			return Redacted
+		}
+	}
+
+	return truncate(string(raw), r.maxBytes)
+}
+
+// redactJSONErr removes the value encoding/json includes in type errors, such as "number 31337",
+// when the params have secret fields, since it could be the value of one.
+func (r *redactor) redactJSONErr(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if !r.hasSecret || !errors.As(err, &typeErr) {
+		return err
+	}
+
+	redacted := *typeErr
+	redacted.Value = strings.SplitN(redacted.Value, " ", 2)[0]
+	return &redacted
+}
+
+// unmarshalGeneric decodes the raw params without a params type, keeping JSON numbers exact.
+func unmarshalGeneric(rawParams []byte, encoding wire.Encoding, value *interface{}) error {
+	if encoding != wire.EncodingJSON {
+		return encoding.UnmarshalParams(rawParams, value)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(rawParams))
+	dec.UseNumber()
+	return dec.Decode(value)
+}
+
+// mask the values of secret fields, using the type to find them.
+func (r *redactor) mask(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		rs, isStruct := r.structs[t]
+		for key, fieldValue := range value {
+			if !isStruct {
+				if t.Kind() == reflect.Map {
+					value[key] = r.mask(fieldValue, t.Elem())
+				}
+				continue
+			}
+
+			// encoding/json prefers exact names, but also matches them case insensitively
+			field, ok := jsonfields.Find(rs.fields, key)
+			switch {
+			case !ok:
+				// Unknown fields cannot be secret
+			case rs.secrets[field.Name]:
+				value[key] = Redacted
+			default:
+				value[key] = r.mask(fieldValue, field.Type)
+			}
+		}
+
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, item := range value {
+				value[i] = r.mask(item, t.Elem())
+			}
+		}
+	}
+
+	return value
+}
+
+// truncate s to at most maxBytes, without splitting characters.
+func truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+
+	s = s[:maxBytes]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+
+	return s + "..."
+}
+
+// redactErrorParams replaces the params of err that the policy does not allow with Redacted.
+// Only the params of err itself are checked, not those of the errors it wraps.
+func (s *Service) redactErrorParams(err error) error {
+	policy := s.options.errorParamsPolicy
+	paramable, ok := err.(erk.Paramable)
+	if policy == nil || !ok {
+		return err
+	}
+
+	kind := erk.GetKindString(err)
+	redacted := erk.Params{}
+	for key := range paramable.Params() {
+		if !policy(kind, key) {
+			redacted[key] = Redacted
+		}
+	}
+
+	return paramable.WithParams(redacted)
+}
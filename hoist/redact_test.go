@@ -0,0 +1,196 @@
+package hoist_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/msgpack"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+type LoginParams struct {
+	User     string              `json:"user"`
+	Password string              `json:"password" hoist:"required,secret"`
+	Age      int                 `json:"age"`
+	Keys     []LoginKey          `json:"keys"`
+	ByName   map[string]LoginKey `json:"byName"`
+}
+
+type LoginKey struct {
+	Name  string `json:"name"`
+	Value string `json:"value" hoist:"secret"`
+}
+
+// ShadowedParams has two embedded fields named Token at the same depth, where the tagged one wins.
+type ShadowedParams struct {
+	ShadowedDisplay
+	ShadowedCredentials
+	Age int `json:"age"`
+}
+
+type ShadowedDisplay struct {
+	Token string
+}
+
+type ShadowedCredentials struct {
+	Secret string `json:"Token" hoist:"secret"`
+}
+
+func TestRedaction(t *testing.T) {
+	login := func(params *LoginParams) error {
+		return nil
+	}
+	echo := func(params *TestParams) error {
+		return nil
+	}
+
+	table := []struct {
+		Name         string
+		Options      []hoist.Option
+		Fn           interface{}
+		Params       string
+		ExpectedJSON string
+	}{
+		{
+			Name:         "masks secret fields",
+			Fn:           login,
+			Params:       `{"user":"abc","Password":"hunter2","age":"old"}`,
+			ExpectedJSON: `{"Password":"[REDACTED]","age":"old","user":"abc"}`,
+		},
+		{
+			Name:         "masks nested secret fields",
+			Fn:           login,
+			Params:       `{"age":"old","keys":[{"name":"a","value":"s1"}],"byName":{"b":{"value":"s2"}}}`,
+			ExpectedJSON: `{"age":"old","byName":{"b":{"value":"[REDACTED]"}},"keys":[{"name":"a","value":"[REDACTED]"}]}`,
+		},
+		{
+			Name:         "omits invalid JSON with secret fields",
+			Fn:           login,
+			Params:       `{"password":"hunter2"`,
+			ExpectedJSON: hoist.Redacted,
+		},
+		{
+			Name:         "keeps invalid JSON without secret fields",
+			Fn:           echo,
+			Params:       `{"Message":"hi"`,
+			ExpectedJSON: `{"Message":"hi"`,
+		},
+		{
+			Name:         "cuts long params",
+			Options:      []hoist.Option{hoist.WithMaxRawErrorBytes(8)},
+			Fn:           echo,
+			Params:       `{"Message":"ééé"`,
+			ExpectedJSON: `{"Messag...`,
+		},
+		{
+			Name:         "does not split characters",
+			Options:      []hoist.Option{hoist.WithMaxRawErrorBytes(15)},
+			Fn:           echo,
+			Params:       `{"Message":"ééé"`,
+			ExpectedJSON: `{"Message":"é...`,
+		},
+		{
+			Name:         "omits params with no length",
+			Options:      []hoist.Option{hoist.WithMaxRawErrorBytes(0)},
+			Fn:           echo,
+			Params:       `{"Message":"hi"`,
+			ExpectedJSON: hoist.Redacted,
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			s := hoist.NewService("myService", entry.Options...)
+			s.RegisterAs("myFunc", entry.Fn)
+			is.Equal(len(s.Errors()), 0)
+
+			_, err := s.Call("myFunc", []byte(entry.Params))
+			is.True(errors.Is(err, hoist.ErrFunctionCallJSONUnmarshal))
+			is.Equal(erk.GetParams(errors.Unwrap(err))["originalJSON"], entry.ExpectedJSON)
+			is.True(!strings.Contains(err.Error(), "hunter2"))
+			is.True(!strings.Contains(err.Error(), "s1"))
+		})
+	}
+
+	t.Run("masks secret values in type errors", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterAs("myFunc", func(params *struct {
+			PIN int8 `json:"pin" hoist:"secret"`
+		}) error {
+			return nil
+		})
+
+		_, err := s.Call("myFunc", []byte(`{"pin":31337}`))
+		is.True(errors.Is(err, hoist.ErrFunctionCallJSONUnmarshal))
+		is.Equal(erk.GetParams(errors.Unwrap(err))["originalJSON"], `{"pin":"[REDACTED]"}`)
+		is.True(!strings.Contains(err.Error(), "31337"))
+		is.True(!strings.Contains(erk.GetParams(errors.Unwrap(err))["err"].(error).Error(), "31337"))
+	})
+
+	t.Run("follows the encoding/json rules for shadowed fields", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterAs("myFunc", func(params *ShadowedParams) error {
+			return nil
+		})
+		is.Equal(len(s.Errors()), 0)
+
+		_, err := s.Call("myFunc", []byte(`{"Token":"hunter2","age":"old"}`))
+		is.True(errors.Is(err, hoist.ErrFunctionCallJSONUnmarshal))
+		is.Equal(erk.GetParams(errors.Unwrap(err))["originalJSON"], `{"Token":"[REDACTED]","age":"old"}`)
+
+		// The schema describes the same field
+		token := s.Export().Definitions["ShadowedParams"].Properties["Token"]
+		is.True(token.WriteOnly)
+	})
+
+	t.Run("MessagePack params are converted to JSON and masked", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterAs("login", login)
+
+		rawParams, err := msgpack.Marshal(map[string]interface{}{"user": "abc", "password": "hunter2", "age": "old"})
+		is.NoErr(err)
+
+		details := &strand.RequestDetails{ServiceName: "myService", FunctionName: "login"}
+		_, err = s.CallWithEncoding(context.Background(), details, wire.EncodingMsgPack, rawParams)
+		is.True(errors.Is(err, hoist.ErrFunctionCallUnmarshal))
+		is.Equal(erk.GetParams(errors.Unwrap(err))["originalParams"], `{"age":"old","password":"[REDACTED]","user":"abc"}`)
+		is.True(!strings.Contains(err.Error(), "hunter2"))
+	})
+
+	t.Run("secret fields are write only in the schema", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterAs("login", login)
+
+		definitions := s.Export().Definitions
+		is.True(definitions["LoginParams"].Properties["password"].WriteOnly)
+		is.True(!definitions["LoginParams"].Properties["user"].WriteOnly)
+		is.True(definitions["LoginKey"].Properties["value"].WriteOnly)
+	})
+
+	t.Run("secret fields are still validated", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterAs("login", login)
+
+		_, err := s.Call("login", []byte(`{"user":"abc"}`))
+		is.True(errors.Is(err, hoist.ErrParamsInvalid))
+	})
+}
@@ -1,7 +1,7 @@
 package hoist
 
 import (
-	"encoding/json"
+	"context"
 	"reflect"
 
 	"github.com/JosiahWitt/erk"
@@ -14,25 +14,65 @@ type (
 )
 
 var (
-	ErrInvalidParameterNumber    = erk.New(ErkInvalidFunction{}, "function must have exactly two parameters: (context, params), got: {{.numParams}}")
-	ErrInvalidReturnNumber       = erk.New(ErkInvalidFunction{}, "function must have exactly two return values: (data, error), got: {{.numReturns}}")
-	ErrInvalidReturnMissingError = erk.New(ErkInvalidFunction{}, "second return value must be an error")
+	ErrInvalidParameterNumber    = erk.New(ErkInvalidFunction{}, "function must have at most two parameters: (context, params), got: {{.numParams}}")
+	ErrInvalidReturnNumber       = erk.New(ErkInvalidFunction{}, "function must have one or two return values: (data, error) or (error), got: {{.numReturns}}")
+	ErrInvalidReturnMissingError = erk.New(ErkInvalidFunction{}, "last return value must be an error")
 	ErrNotFunction               = erk.New(ErkInvalidFunction{}, "a function was not provided")
 	ErrInvalidFunction           = erk.New(ErkInvalidFunction{}, "service '{{.serviceName}}' could not register function '{{.fnName}}': {{.err}}")
 
-	ErrFunctionCallJSONUnmarshal = erk.New(ErkFunctionCall{}, "could not unmarshal JSON '{{.originalJSON}}'")
+	ErrFunctionCallJSONUnmarshal = erk.New(ErkFunctionCall{}, "could not unmarshal JSON params: {{.err}}")
+	ErrFunctionCallUnmarshal     = erk.New(ErkFunctionCall{}, "could not unmarshal {{.encoding}} params: {{.err}}")
 )
 
-// errorType allows us to check that the second parameter is an error.
-var errorType = reflect.TypeOf(new(error)).Elem()
+var (
+	// errorType allows us to check that the last return value is an error.
+	errorType = reflect.TypeOf(new(error)).Elem()
+
+	// contextType allows us to check if a lone parameter is a context.
+	contextType = reflect.TypeOf(new(context.Context)).Elem()
+)
 
 // RegisterAs allows you to register a function as the provided name.
 //
 // fn must be a function with the following signature:
 //  func myFunction(ctx myContextType, params myParamType) (myDataType, error)
-func (s *Service) RegisterAs(fnName string, fn interface{}) {
+//
+// The context, params, and data can also be omitted, so these signatures are also accepted:
+//  func myFunction(ctx myContextType, params myParamType) error
+//  func myFunction(params myParamType) (myDataType, error)
+//  func myFunction(ctx myContextType) (myDataType, error)
+//  func myFunction() (myDataType, error)
+//  func myFunction() error
+//  ...
+//
+// When there is a single parameter, it is the context if it is a context.Context, the exact type
+// returned by a hook, or a struct with a context.Context or Sender field (for example, by embedding it).
+// Otherwise, it is the params.
+//
+// The fields of myParamType can be validated before the function is called, using rules in
+// `hoist:"..."` struct tags (see FieldError for the errors, and the rules below):
+//  required       the field must not be the zero value (nil for pointers)
+//  min=<n>        minimum value of numbers, characters in strings, or items in slices and maps
+//  max=<n>        maximum value of numbers, characters in strings, or items in slices and maps
+//  enum=<a|b|c>   the field must be one of the values
+//  pattern=<re>   strings must match the regular expression; it must be the last rule, so it can contain commas
+//  secret         the value is masked in errors (see WithMaxRawErrorBytes); it is not validated
+// Rules other than required also apply to zero values, and are only skipped for nil pointers,
+// so optional fields with rules should be pointers.
+//
+// The fields of myContextType are filled by the registered hooks (see Hook and HookTag).
+// myContextType can also be a context.Context, or a struct embedding one, which is canceled
+// when the caller disconnects or the request deadline expires.
+//
+// Functions can stream their response, instead of returning data: by having a context field of type Sender,
+// or by returning a receive only channel (see Sender):
+//  func myFunction(ctx myContextTypeWithSender, params myParamType) error
+//  func myFunction(ctx myContextType, params myParamType) (<-chan myItemType, error)
+//
+// The params are decoded according to WithParamsDecoding, unless opts includes FuncParamsDecoding.
+func (s *Service) RegisterAs(fnName string, fn interface{}, opts ...FuncOption) {
 	// Wrap the function
-	wrappedFn, err := s.funcWrapper(fn)
+	wrappedFn, err := s.funcWrapper(fn, opts...)
 
 	// Acquire the lock
 	s.mu.Lock()
@@ -49,7 +89,7 @@ func (s *Service) RegisterAs(fnName string, fn interface{}) {
 	s.funcs[fnName] = wrappedFn
 }
 
-func (s *Service) funcWrapper(fn interface{}) (rawFunc, error) {
+func (s *Service) funcWrapper(fn interface{}, opts ...FuncOption) (*function, error) {
 	// Get the function type
 	fnType := reflect.TypeOf(fn)
 	if fnType == nil || fnType.Kind() != reflect.Func {
@@ -57,36 +97,125 @@ func (s *Service) funcWrapper(fn interface{}) (rawFunc, error) {
 	}
 
 	// Check the parameter and return counts
-	if fnType.NumIn() != 2 {
+	if fnType.NumIn() > 2 {
 		return nil, erk.WithParam(ErrInvalidParameterNumber, "numParams", fnType.NumIn())
 	}
-	if fnType.NumOut() != 2 {
+	if fnType.NumOut() < 1 || fnType.NumOut() > 2 {
 		return nil, erk.WithParam(ErrInvalidReturnNumber, "numReturns", fnType.NumOut())
 	}
 
-	// Check the second return value
-	if !fnType.Out(1).Implements(errorType) {
+	// Check the last return value
+	if !fnType.Out(fnType.NumOut() - 1).Implements(errorType) {
 		return nil, ErrInvalidReturnMissingError
 	}
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Determine which parameters are present
+	var ctxType, paramsType reflect.Type
+	switch fnType.NumIn() {
+	case 2:
+		ctxType, paramsType = fnType.In(0), fnType.In(1)
+	case 1:
+		if in := fnType.In(0); s.isLoneContext(in) {
+			ctxType = in
+		} else {
+			paramsType = in
+		}
+	}
+
+	var dataType reflect.Type
+	if fnType.NumOut() == 2 {
+		dataType = fnType.Out(0)
+	}
+	streamsChannel := isStreamChannel(dataType)
+
+	// Parse the validation rules of the params
+	var paramsValidator *validator
+	var paramsRedactor *redactor
+	if paramsType != nil {
+		var err error
+		paramsValidator, err = newValidator(paramsType)
+		if err != nil {
+			return nil, err
+		}
+
+		paramsRedactor = newRedactor(paramsType, s.options.maxRawErrorBytes)
+	}
+
+	// Determine how the params are decoded
+	fnOptions := funcOptions{}
+	for _, opt := range opts {
+		opt(&fnOptions)
+	}
+
+	decoding := s.options.paramsDecoding
+	if fnOptions.paramsDecoding != nil {
+		decoding = *fnOptions.paramsDecoding
+	}
+
+	// Match the context fields with hooks
+	var filler *contextFiller
+	if ctxType != nil {
+		var err error
+		filler, err = s.newContextFiller(ctxType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Functions stream by filling a Sender, or by returning a receive only channel
+	streamsWithSender := filler != nil && filler.streamsWithSender()
+	if streamsWithSender && dataType != nil {
+		return nil, ErrStreamingReturnsData
+	}
+
 	// Create the function
-	wrappedFn := func(rawParams []byte) (interface{}, error) {
-		// Create the context and params
-		ctx := reflect.New(fnType.In(0))
-		params := reflect.New(fnType.In(1))
-
-		// Fill the params with the JSON
-		if err := json.Unmarshal(rawParams, params.Interface()); err != nil {
-			return nil, erk.WithParam(erk.WrapAs(ErrFunctionCallJSONUnmarshal, err), "originalJSON", string(rawParams))
+	fnValue := reflect.ValueOf(fn)
+	wrappedFn := func(req *Request, rawParams []byte) (interface{}, error) {
+		args := make([]reflect.Value, 0, 2)
+		if req.stream == nil {
+			req.stream = &stream{}
+		}
+
+		// Create the params
+		var params reflect.Value
+		if paramsType != nil {
+			params = reflect.New(paramsType)
+
+			// Fill the params from the request's encoding
+			if err := decoding.decode(rawParams, req.Encoding, params, paramsRedactor); err != nil {
+				return nil, err
+			}
+
+			// Check the params against their validation rules
+			if paramsValidator != nil {
+				if err := paramsValidator.validate(params.Elem()); err != nil {
+					return nil, err
+				}
+			}
 		}
 
-		// TODO: Fill ctx with hooks
+		// Fill the context with hooks
+		if filler != nil {
+			ctx, err := filler.fill(req)
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, ctx)
+		}
+
+		if paramsType != nil {
+			args = append(args, params.Elem())
+		}
 
 		// Call the function
-		rets := reflect.ValueOf(fn).Call([]reflect.Value{ctx.Elem(), params.Elem()})
+		rets := fnValue.Call(args)
 
 		// Check for an error
-		if err := rets[1].Interface(); err != nil {
+		if err := rets[len(rets)-1].Interface(); err != nil {
 			err, ok := err.(error)
 			if !ok {
 				return nil, ErrInvalidReturnMissingError
@@ -96,9 +225,31 @@ func (s *Service) funcWrapper(fn interface{}) (rawFunc, error) {
 			}
 		}
 
-		// Return the data
+		// Send the streamed items
+		switch {
+		case streamsWithSender:
+			return req.stream.result(), nil
+		case streamsChannel:
+			return req.stream.drain(req.Context, rets[0])
+		}
+
+		// Return the data, if there is any
+		if dataType == nil {
+			return nil, nil
+		}
+
 		return rets[0].Interface(), nil
 	}
 
-	return wrappedFn, nil
+	wrapped := &function{
+		call:       wrappedFn,
+		paramsType: paramsType,
+		dataType:   dataType,
+		streams:    streamsWithSender || streamsChannel,
+	}
+	if streamsChannel {
+		wrapped.dataType = dataType.Elem()
+	}
+
+	return wrapped, nil
 }
@@ -1,6 +1,7 @@
 package hoist_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -28,13 +29,10 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name: {
-							Name: name,
-						},
-						name2: {
-							Name: name2,
-						},
+						name:  noopExport(name),
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -53,10 +51,9 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -75,36 +72,33 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
 		},
 		{
-			Name: "invalid function: too few params",
+			Name: "valid functions: optional context, params, and data",
 			Check: func(is *is.I, s *hoist.Service) {
-				name := "myFn"
-				name2 := "myFn2"
+				s.RegisterAs("noCtx", func(*MyParams) (*MyData, error) { return nil, nil })
+				s.RegisterAs("noParams", func(context.Context) (*MyData, error) { return nil, nil })
+				s.RegisterAs("noData", func(*MyCtx, *MyParams) error { return nil })
+				s.RegisterAs("nothing", func() error { return nil })
 
-				badFn := func(*MyParams) (*MyData, error) {
-					return nil, nil
-				}
-				s.RegisterAs(name, badFn)
-				s.RegisterAs(name2, validNoopFn)
-
-				is.Equal(len(s.Errors()), 1)
-				is.True(errors.Is(s.Errors()[0], hoist.ErrInvalidParameterNumber))
+				is.Equal(len(s.Errors()), 0)
 
+				noop := noopExport("")
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						"noCtx":    {Name: "noCtx", Params: noop.Params, Returns: noop.Returns},
+						"noParams": {Name: "noParams", Returns: noop.Returns},
+						"noData":   {Name: "noData", Params: noop.Params},
+						"nothing":  {Name: "nothing"},
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -127,10 +121,9 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -141,9 +134,7 @@ func TestRegisterAs(t *testing.T) {
 				name := "myFn"
 				name2 := "myFn2"
 
-				badFn := func(*MyCtx, *MyParams) error {
-					return nil
-				}
+				badFn := func(*MyCtx, *MyParams) {}
 				s.RegisterAs(name, badFn)
 				s.RegisterAs(name2, validNoopFn)
 
@@ -153,10 +144,9 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -179,10 +169,9 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -205,10 +194,9 @@ func TestRegisterAs(t *testing.T) {
 				expected := &hoist.ExportedService{
 					Name: serviceName,
 					Functions: map[string]*hoist.ExportedFunction{
-						name2: {
-							Name: name2,
-						},
+						name2: noopExport(name2),
 					},
+					Definitions: noopDefinitions(),
 				}
 				is.Equal(s.Export(), expected)
 			},
@@ -222,7 +210,7 @@ func TestRegisterAs(t *testing.T) {
 				badFn := func(*MyCtx, *MyParams) (*MyData, *MyData) {
 					return nil, nil
 				}
-				badFn2 := func(*MyCtx) (*MyData, error) {
+				badFn2 := func(*MyCtx, *MyParams, *MyParams) (*MyData, error) {
 					return nil, nil
 				}
 				s.RegisterAs(name, badFn)
@@ -260,3 +248,24 @@ type MyData struct{}
 func validNoopFn(*MyCtx, *MyParams) (*MyData, error) {
 	return nil, nil
 }
+
+func noopExport(name string) *hoist.ExportedFunction {
+	return &hoist.ExportedFunction{
+		Name: name,
+		Params: &hoist.Schema{AnyOf: []*hoist.Schema{
+			{Ref: "#/definitions/MyParams"},
+			{Type: hoist.SchemaType{hoist.SchemaTypeNull}},
+		}},
+		Returns: &hoist.Schema{AnyOf: []*hoist.Schema{
+			{Ref: "#/definitions/MyData"},
+			{Type: hoist.SchemaType{hoist.SchemaTypeNull}},
+		}},
+	}
+}
+
+func noopDefinitions() map[string]*hoist.Schema {
+	return map[string]*hoist.Schema{
+		"MyParams": {Type: hoist.SchemaType{hoist.SchemaTypeObject}, Properties: map[string]*hoist.Schema{}},
+		"MyData":   {Type: hoist.SchemaType{hoist.SchemaTypeObject}, Properties: map[string]*hoist.Schema{}},
+	}
+}
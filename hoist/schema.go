@@ -0,0 +1,328 @@
+package hoist
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoistup/hoist-go/internal/jsonfields"
+)
+
+// Schema is a JSON Schema (draft-07 compatible) description of a type.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 SchemaType         `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"`
+}
+
+// SchemaType is the list of JSON types allowed by a Schema.
+// It is encoded as a string when it contains one type, and as an array otherwise.
+type SchemaType []string
+
+// JSON Schema types
+const (
+	SchemaTypeNull    = "null"
+	SchemaTypeBoolean = "boolean"
+	SchemaTypeInteger = "integer"
+	SchemaTypeNumber  = "number"
+	SchemaTypeString  = "string"
+	SchemaTypeArray   = "array"
+	SchemaTypeObject  = "object"
+)
+
+// MarshalJSON encodes a single type as a string, and multiple types as an array.
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON decodes a type from either a string or an array.
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaType{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+
+	*t = multiple
+	return nil
+}
+
+// Enum can be implemented by types that only allow a fixed set of values.
+// The values are included in the exported Schema.
+type Enum interface {
+	EnumValues() []interface{}
+}
+
+// definitionsPrefix is where named types are referenced from.
+const definitionsPrefix = "#/definitions/"
+
+var (
+	enumType          = reflect.TypeOf(new(Enum)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf(new(json.Marshaler)).Elem()
+	textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+)
+
+// schemaBuilder builds schemas, collecting named struct types into definitions.
+type schemaBuilder struct {
+	definitions map[string]*Schema
+	names       map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		definitions: make(map[string]*Schema),
+		names:       make(map[reflect.Type]string),
+	}
+}
+
+// schemaFor the provided type.
+func (b *schemaBuilder) schemaFor(t reflect.Type) *Schema {
+	schema := b.baseSchemaFor(t)
+
+	// Types that list their values
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(enumType) {
+		enum := reflect.New(t).Interface().(Enum)
+		schema.Enum = enum.EnumValues()
+	}
+
+	return schema
+}
+
+func (b *schemaBuilder) baseSchemaFor(t reflect.Type) *Schema {
+	// Types with custom encodings
+	switch {
+	case t == timeType:
+		return &Schema{Type: SchemaType{SchemaTypeString}, Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() != reflect.Ptr && (t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType)):
+		return &Schema{}
+	case t.Kind() != reflect.Ptr && (t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)):
+		return &Schema{Type: SchemaType{SchemaTypeString}}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: SchemaType{SchemaTypeBoolean}}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: SchemaType{SchemaTypeInteger}}
+
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaType{SchemaTypeNumber}}
+
+	case reflect.String:
+		return &Schema{Type: SchemaType{SchemaTypeString}}
+
+	case reflect.Ptr:
+		return nullable(b.schemaFor(t.Elem()))
+
+	case reflect.Slice:
+		// []byte is encoded as a base64 string
+		if t.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(t.Elem()).Implements(jsonMarshalerType) && !reflect.PtrTo(t.Elem()).Implements(textMarshalerType) {
+			return nullable(&Schema{Type: SchemaType{SchemaTypeString}, ContentEncoding: "base64"})
+		}
+
+		return nullable(&Schema{Type: SchemaType{SchemaTypeArray}, Items: b.schemaFor(t.Elem())})
+
+	case reflect.Array:
+		length := t.Len()
+		return &Schema{
+			Type:     SchemaType{SchemaTypeArray},
+			Items:    b.schemaFor(t.Elem()),
+			MinItems: &length,
+			MaxItems: &length,
+		}
+
+	case reflect.Map:
+		return nullable(&Schema{Type: SchemaType{SchemaTypeObject}, AdditionalProperties: b.schemaFor(t.Elem())})
+
+	case reflect.Struct:
+		return b.structRef(t)
+
+	default:
+		// Interfaces can hold any value, and other kinds cannot be encoded
+		return &Schema{}
+	}
+}
+
+// structRef returns a reference to a named struct, or the inline schema of an anonymous struct.
+func (b *schemaBuilder) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return b.structSchema(t)
+	}
+
+	if name, ok := b.names[t]; ok {
+		return &Schema{Ref: definitionsPrefix + name}
+	}
+
+	// Use a unique name for the definition
+	name := t.Name()
+	for i := 2; b.definitions[name] != nil; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+
+	// Reserve the name before building, to support recursive types
+	b.names[t] = name
+	b.definitions[name] = &Schema{}
+	*b.definitions[name] = *b.structSchema(t)
+
+	return &Schema{Ref: definitionsPrefix + name}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       SchemaType{SchemaTypeObject},
+		Properties: make(map[string]*Schema),
+	}
+
+	b.addFields(schema, t)
+	sort.Strings(schema.Required)
+
+	return schema
+}
+
+// addFields to the schema, following the encoding/json rules for names and embedded structs.
+func (b *schemaBuilder) addFields(schema *Schema, t reflect.Type) {
+	for _, field := range jsonfields.Of(t) {
+		fieldSchema := b.schemaFor(field.Type)
+		if field.Quoted {
+			fieldSchema = &Schema{Type: SchemaType{SchemaTypeString}}
+		}
+
+		// Validation rules are checked when registering, so they are valid here
+		rules, _ := parseRules(field.StructField, field.Name)
+		switch {
+		case rules == nil:
+		case field.Quoted:
+			// The rules describe the value in the string, not the string
+			fieldSchema.WriteOnly = rules.secret
+		default:
+			applyRules(fieldSchema, rules, field.Type)
+		}
+
+		// encoding/json decodes missing fields as zero values, so only the required rule makes fields required
+		schema.Properties[field.Name] = fieldSchema
+		if rules != nil && rules.required {
+			schema.Required = append(schema.Required, field.Name)
+		}
+	}
+}
+
+// applyRules adds the validation rules of a field to its schema.
+func applyRules(schema *Schema, rules *fieldRules, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	toInt := func(f *float64) *int {
+		if f == nil {
+			return nil
+		}
+
+		i := int(*f)
+		return &i
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema.MinLength, schema.MaxLength = toInt(rules.min), toInt(rules.max)
+	case reflect.Slice, reflect.Array:
+		if rules.min != nil {
+			schema.MinItems = toInt(rules.min)
+		}
+		if rules.max != nil {
+			schema.MaxItems = toInt(rules.max)
+		}
+	case reflect.Map:
+		schema.MinProperties, schema.MaxProperties = toInt(rules.min), toInt(rules.max)
+	default:
+		schema.Minimum, schema.Maximum = rules.min, rules.max
+	}
+
+	if rules.pattern != nil {
+		schema.Pattern = rules.pattern.String()
+	}
+
+	// Secrets are sent by callers, but never echoed back
+	schema.WriteOnly = rules.secret
+
+	if rules.enumValues != nil {
+		schema.Enum = append([]interface{}{}, rules.enumValues...)
+		if contains(schema.Type, SchemaTypeNull) {
+			schema.Enum = append(schema.Enum, nil)
+		}
+	}
+}
+
+// nullable allows the schema to also be null.
+func nullable(schema *Schema) *Schema {
+	switch {
+	case schema.Ref != "":
+		return &Schema{AnyOf: []*Schema{schema, {Type: SchemaType{SchemaTypeNull}}}}
+	case len(schema.Type) == 0: // Already allows any type
+		return schema
+	case contains(schema.Type, SchemaTypeNull):
+		return schema
+	}
+
+	schema.Type = append(schema.Type, SchemaTypeNull)
+	if schema.Enum != nil {
+		schema.Enum = append(schema.Enum, nil)
+	}
+
+	return schema
+}
+
+type jsonTagOptions string
+
+func parseJSONTag(tag string) (string, jsonTagOptions) {
+	if i := strings.Index(tag, ","); i != -1 {
+		return tag[:i], jsonTagOptions(tag[i+1:])
+	}
+
+	return tag, ""
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package hoist_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/matryer/is"
+)
+
+type SchemaColor string
+
+func (SchemaColor) EnumValues() []interface{} {
+	return []interface{}{"red", "green"}
+}
+
+type SchemaBase struct {
+	ID      string `json:"id"`
+	Comment string `json:"comment"`
+}
+
+type SchemaNode struct {
+	Value    int           `json:"value"`
+	Children []*SchemaNode `json:"children,omitempty"`
+}
+
+type SchemaParams struct {
+	SchemaBase
+	Comment  int               `json:"comment"`
+	Name     string            `json:"name"`
+	Nickname *string           `json:"nickname"`
+	Tags     []string          `json:"tags,omitempty"`
+	Scores   map[string]int    `json:"scores"`
+	Color    SchemaColor       `json:"color"`
+	Created  time.Time         `json:"created"`
+	Raw      []byte            `json:"raw"`
+	Pair     [2]float64        `json:"pair"`
+	Count    int64             `json:"count,string"`
+	Level    int               `json:"level,string" hoist:"required,min=1,max=5"`
+	Title    string            `json:"title,omitempty" hoist:"required,max=10"`
+	Any      interface{}       `json:"any"`
+	Tree     SchemaNode        `json:"tree"`
+	Inline   struct{ On bool } `json:"inline"`
+	Ignored  string            `json:"-"`
+	private  string
+}
+
+func TestExportSchema(t *testing.T) {
+	is := is.New(t)
+
+	s := hoist.NewService("myService")
+	s.RegisterAs("myFn", func(*MyCtx, SchemaParams) ([]SchemaColor, error) {
+		return nil, nil
+	})
+	is.Equal(len(s.Errors()), 0)
+
+	str := func(types ...string) hoist.SchemaType { return hoist.SchemaType(types) }
+	two, ten := 2, 10
+
+	exported := s.Export()
+	is.Equal(exported.Functions["myFn"], &hoist.ExportedFunction{
+		Name:   "myFn",
+		Params: &hoist.Schema{Ref: "#/definitions/SchemaParams"},
+		Returns: &hoist.Schema{
+			Type:  str(hoist.SchemaTypeArray, hoist.SchemaTypeNull),
+			Items: &hoist.Schema{Type: str(hoist.SchemaTypeString), Enum: []interface{}{"red", "green"}},
+		},
+	})
+
+	is.Equal(exported.Definitions["SchemaNode"], &hoist.Schema{
+		Type: str(hoist.SchemaTypeObject),
+		Properties: map[string]*hoist.Schema{
+			"value": {Type: str(hoist.SchemaTypeInteger)},
+			"children": {
+				Type: str(hoist.SchemaTypeArray, hoist.SchemaTypeNull),
+				Items: &hoist.Schema{AnyOf: []*hoist.Schema{
+					{Ref: "#/definitions/SchemaNode"},
+					{Type: str(hoist.SchemaTypeNull)},
+				}},
+			},
+		},
+	})
+
+	is.Equal(exported.Definitions["SchemaParams"], &hoist.Schema{
+		Type: str(hoist.SchemaTypeObject),
+		Properties: map[string]*hoist.Schema{
+			"id":       {Type: str(hoist.SchemaTypeString)},
+			"comment":  {Type: str(hoist.SchemaTypeInteger)},
+			"name":     {Type: str(hoist.SchemaTypeString)},
+			"nickname": {Type: str(hoist.SchemaTypeString, hoist.SchemaTypeNull)},
+			"tags": {
+				Type:  str(hoist.SchemaTypeArray, hoist.SchemaTypeNull),
+				Items: &hoist.Schema{Type: str(hoist.SchemaTypeString)},
+			},
+			"scores": {
+				Type:                 str(hoist.SchemaTypeObject, hoist.SchemaTypeNull),
+				AdditionalProperties: &hoist.Schema{Type: str(hoist.SchemaTypeInteger)},
+			},
+			"color":   {Type: str(hoist.SchemaTypeString), Enum: []interface{}{"red", "green"}},
+			"created": {Type: str(hoist.SchemaTypeString), Format: "date-time"},
+			"raw":     {Type: str(hoist.SchemaTypeString, hoist.SchemaTypeNull), ContentEncoding: "base64"},
+			"pair": {
+				Type:     str(hoist.SchemaTypeArray),
+				Items:    &hoist.Schema{Type: str(hoist.SchemaTypeNumber)},
+				MinItems: &two,
+				MaxItems: &two,
+			},
+			"count": {Type: str(hoist.SchemaTypeString)},
+			"level": {Type: str(hoist.SchemaTypeString)},
+			"title": {Type: str(hoist.SchemaTypeString), MaxLength: &ten},
+			"any":   {},
+			"tree":  {Ref: "#/definitions/SchemaNode"},
+			"inline": {
+				Type:       str(hoist.SchemaTypeObject),
+				Properties: map[string]*hoist.Schema{"On": {Type: str(hoist.SchemaTypeBoolean)}},
+			},
+		},
+		Required: []string{"level", "title"},
+	})
+}
+
+func TestSchemaTypeJSON(t *testing.T) {
+	is := is.New(t)
+
+	single, err := json.Marshal(hoist.SchemaType{hoist.SchemaTypeString})
+	is.NoErr(err)
+	is.Equal(string(single), `"string"`)
+
+	multiple, err := json.Marshal(hoist.SchemaType{hoist.SchemaTypeString, hoist.SchemaTypeNull})
+	is.NoErr(err)
+	is.Equal(string(multiple), `["string","null"]`)
+
+	var decoded hoist.SchemaType
+	is.NoErr(json.Unmarshal(single, &decoded))
+	is.Equal(decoded, hoist.SchemaType{hoist.SchemaTypeString})
+	is.NoErr(json.Unmarshal(multiple, &decoded))
+	is.Equal(decoded, hoist.SchemaType{hoist.SchemaTypeString, hoist.SchemaTypeNull})
+}
@@ -1,13 +1,13 @@
 package hoist
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
-	"os"
 	"reflect"
-	"time"
 
 	"github.com/JosiahWitt/erk"
 	"github.com/JosiahWitt/erk/erg"
@@ -24,111 +24,379 @@ type (
 var (
 	ErrInitializing      = erk.New(ErkHoistInit{}, "could not initialize")
 	ErrPortMissing       = erk.New(ErkHoistInit{}, "PORT environment variable not set (this should be set automatically by Hoist)")
+	ErrListening         = erk.New(ErkHoistInit{}, "could not listen on '{{.addr}}': {{.err}}")
 	ErrJSONParamsInvalid = erk.New(ErkBadRequest{}, "function params are invalid JSON")
+	ErrBodyTooLarge      = erk.New(ErkBadRequest{}, "request body is larger than the maximum of {{.max}} bytes")
 )
 
 // Serve the Hoisted application.
 //
-// Serve blocks with a call to http.Server{}.ListenAndServe().
+// Serve blocks until the service is shut down with Shutdown.
 func (s *Service) Serve() error {
+	return s.ServeContext(context.Background())
+}
+
+// ServeContext serves the Hoisted application until ctx is done, and then shuts it down gracefully.
+// In-flight calls are given the shutdown timeout (see WithShutdownTimeout) to finish.
+//
+// ServeContext returns nil when the service shuts down cleanly.
+// If the server fails, its error is returned, and the service can be served again,
+// except on a listener provided with WithListener, which the failed server has closed.
+// To shut down on SIGINT or SIGTERM, use SignalContext to create ctx.
+func (s *Service) ServeContext(ctx context.Context) error {
 	if errs := s.Errors(); len(errs) > 0 {
 		return erg.NewAs(ErrInitializing, errs...)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		return ErrPortMissing
-	}
-
 	mux := http.NewServeMux()
 	mux.HandleFunc("/_/v1/fn", s.handler)
+	mux.HandleFunc("/_/v1/export", s.exportHandler)
+	mux.HandleFunc("/_/v1/ws", s.webSocketHandler)
+	if s.options.metricsPath != "" {
+		mux.HandleFunc(s.options.metricsPath, s.metricsHandler)
+	}
 
-	server := http.Server{
-		Addr:           "localhost:" + port,
+	server := &http.Server{
 		Handler:        mux,
-		MaxHeaderBytes: 250,
-		ReadTimeout:    30 * time.Second,
-		WriteTimeout:   30 * time.Second,
-		IdleTimeout:    60 * time.Second,
+		MaxHeaderBytes: s.options.maxHeaderBytes,
+		ReadTimeout:    s.options.readTimeout,
+		WriteTimeout:   s.options.writeTimeout,
+		IdleTimeout:    s.options.idleTimeout,
 	}
 
-	fmt.Printf("Serving at http://localhost:%s\n", port)
-	return server.ListenAndServe()
+	// Claim the service before listening, so a second call cannot disturb the listener of the first
+	s.mu.Lock()
+	if s.server != nil {
+		s.mu.Unlock()
+		return erk.WithParam(ErrAlreadyServing, "serviceName", s.name)
+	}
+	if s.shuttingDown {
+		// Shutdown already ran, so it would never stop the server
+		s.mu.Unlock()
+		return erk.WithParam(ErrShuttingDown, "serviceName", s.name)
+	}
+	s.server = server
+	s.mu.Unlock()
+
+	listener, err := s.listen()
+	if err != nil {
+		s.clearServer()
+		return err
+	}
+
+	fmt.Printf("Serving at http://%s\n", listener.Addr())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != http.ErrServerClosed {
+			// The server failed, so the service can be served again.
+			// The listener was closed by the server, so a listener from WithListener cannot be reused.
+			s.clearServer()
+			return err
+		}
+
+		// Shutdown was called directly, so wait for it to finish
+		<-s.shutdownDone
+		return s.shutdownErr
+
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.options.shutdownTimeout)
+		defer cancel()
+
+		err := s.Shutdown(shutdownCtx)
+		<-serveErr
+		return err
+	}
 }
 
 func (s *Service) handler(w http.ResponseWriter, r *http.Request) {
-	details, err := s.handleHTTPEvent(w, r)
+	var body *limitedBody
+	if s.options.maxBodyBytes > 0 {
+		body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, s.options.maxBodyBytes), max: s.options.maxBodyBytes}
+		r.Body = body
+	}
 
-	// Handle error, if present
+	decoded, err := wire.NewDecoder(r.Body, s.options.decoderOptions...).Decode()
 	if err != nil {
-		errDetails := &strand.ResponseDetails{IsError: true}
-		if details != nil {
-			errDetails.RequestID = details.RequestID
+		if body != nil && body.exceeded {
+			err = erk.WrapAs(erk.WithParam(ErrBodyTooLarge, "max", body.max), err)
 		}
 
-		// Export the error
-		params, isInternalError := s.exportEventError(err)
-		errDetails.IsInternalError = isInternalError
+		s.countDecodeError()
+		s.writeEventError(w, nil, nil, 0, err)
+		return
+	}
 
-		// Encode the error
-		bytes, err := wire.Encode(errDetails, params)
-		if err != nil {
-			errDetails := `{"err":true,"ierr":true}`
-			errParams := `{"kind":"wire_encoding_error","message":"unable to encode error to wire format"}`
-			w.Write([]byte(`1,24,80:` + errDetails + errParams))
-			return
+	// The request context is canceled when the client disconnects
+	flusher, _ := w.(http.Flusher)
+	s.serveFrame(r.Context(), decoded, func(writeFrame func(w io.Writer), flush bool) error {
+		writeFrame(w)
+		if flush && flusher != nil {
+			flusher.Flush()
 		}
+		return nil
+	})
+}
 
-		// Write the error
-		w.Write(bytes)
+// listen on the listener provided with WithListener, or on the address of the service.
+func (s *Service) listen() (net.Listener, error) {
+	if s.options.listener != nil {
+		return s.options.listener, nil
 	}
-}
 
-func (s *Service) handleHTTPEvent(w http.ResponseWriter, r *http.Request) (*strand.RequestDetails, error) {
-	decoded, err := wire.NewDecoder(r.Body).Decode()
+	addr, err := s.options.address()
 	if err != nil {
 		return nil, err
 	}
 
-	details := strand.RequestDetails{}
-	if err := json.Unmarshal(decoded.RawDetails, &details); err != nil {
-		return nil, erk.WrapAs(ErrJSONParamsInvalid, err)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParam(ErrListening, "addr", addr), err)
 	}
 
-	result, err := s.Call(details.FunctionName, decoded.RawParams)
-	if err != nil {
-		return &details, err
+	return listener, nil
+}
+
+// limitedBody records when the http.MaxBytesReader it wraps fails because the body is over its limit.
+type limitedBody struct {
+	io.ReadCloser
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+
+	// The limit is only reported once it has been read, since the body could end there
+	if err != nil && err != io.EOF && b.read >= b.max {
+		b.exceeded = true
 	}
 
-	bytes, err := wire.Encode(&strand.ResponseDetails{RequestID: details.RequestID}, result)
-	if err != nil {
-		return &details, err
+	return n, err
+}
+
+// clearServer after it stops without shutting down, so the service can be served again.
+func (s *Service) clearServer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.server = nil
+}
+
+// frameWriter writes a response frame to the transport of a call.
+// The frame is sent immediately if flush is true, while the final frame can wait for the call to finish.
+type frameWriter func(writeFrame func(w io.Writer), flush bool) error
+
+// serveFrame calls the function requested by the decoded frame, and writes the response.
+// If the request accepts streams, each item streamed by the function is written in its own frame as it is sent.
+func (s *Service) serveFrame(ctx context.Context, decoded *wire.DecodeResult, write frameWriter) {
+	details := &strand.RequestDetails{}
+	if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, details); err != nil {
+		s.countDecodeError()
+		write(func(w io.Writer) { s.writeEventError(w, nil, decoded, 0, erk.WrapAs(ErrJSONParamsInvalid, err)) }, false)
+		return
+	}
+
+	if len(details.Batch) > 0 {
+		s.serveBatch(ctx, details, decoded, write)
+		return
 	}
 
-	_, err = w.Write(bytes)
-	return &details, err
+	// Only send changes sent, and it is not called after CallStream returns
+	var sent uint64
+	var send SendFunc
+	if details.AcceptStream {
+		send = func(item interface{}) error {
+			respDetails := &strand.ResponseDetails{RequestID: details.RequestID, Sequence: sent + 1}
+
+			var err error
+			writeErr := write(func(w io.Writer) {
+				err = wire.NewEncoder(w, s.responseEncoderOptions(details, decoded, respDetails)...).Encode(respDetails, item)
+			}, true)
+			if err == nil {
+				err = writeErr
+			}
+			if err == nil {
+				sent++
+			}
+
+			return err
+		}
+	}
+
+	result, err := s.CallStream(ctx, details, decoded.Encoding, decoded.RawParams, send)
+
+	// Streams end with a final frame, even if no items were sent
+	var sequence uint64
+	if details.AcceptStream && s.streams(details.FunctionName) {
+		sequence = sent + 1
+	}
+
+	write(func(w io.Writer) { s.writeResponse(w, details, decoded, sequence, result, err) }, false)
 }
 
-func (s *Service) exportEventError(err error) (interface{}, bool) {
-	// Check if the error denotes the function call failed
-	if errors.Is(err, ErrFunctionCallFailed) {
-		// Attempt to unwrap the error
-		wrappedErr := errors.Unwrap(err)
-		if wrappedErr == nil {
-			return erk.Export(err), true
+// writeResponse writes the result, or the error if the call failed or the result cannot be encoded,
+// in the encoding of the request. The sequence is that of the final frame of a stream, or zero for a single frame.
+func (s *Service) writeResponse(w io.Writer, details *strand.RequestDetails, decoded *wire.DecodeResult, sequence uint64, result interface{}, err error) {
+	if err == nil {
+		respDetails := &strand.ResponseDetails{RequestID: details.RequestID}
+		endStream(respDetails, sequence)
+
+		encoder := wire.NewEncoder(w, s.responseEncoderOptions(details, decoded, respDetails)...)
+		if err = encoder.Encode(respDetails, result); err == nil {
+			return
 		}
+	}
 
-		// Check if an "Export" method exists with no input params and one return value
-		exportMethod := reflect.ValueOf(wrappedErr).MethodByName("Export")
-		if exportMethod.IsValid() && exportMethod.Type().NumOut() == 1 && exportMethod.Type().NumIn() == 0 {
-			// Call the "Export" method
-			ret := exportMethod.Call([]reflect.Value{})
-			return ret[0].Interface(), false
+	s.writeEventError(w, details, decoded, sequence, err)
+}
+
+// endStream marks the final frame of a stream, unless the sequence is zero.
+func endStream(respDetails *strand.ResponseDetails, sequence uint64) {
+	if sequence > 0 {
+		respDetails.Sequence = sequence
+		respDetails.EndOfStream = true
+	}
+}
+
+// writeEventError in the encoding of the request, falling back to a JSON error if it cannot be encoded.
+// The request is nil if it could not be decoded. The error ends the stream, unless the sequence is zero.
+func (s *Service) writeEventError(w io.Writer, details *strand.RequestDetails, decoded *wire.DecodeResult, sequence uint64, err error) {
+	errDetails := &strand.ResponseDetails{IsError: true}
+	if details != nil {
+		errDetails.RequestID = details.RequestID
+	}
+	endStream(errDetails, sequence)
+
+	// Export the error
+	params, isInternalError := s.exportEventError(err)
+	errDetails.IsInternalError = isInternalError
+
+	// Encode and write the error
+	encoder := wire.NewEncoder(w, s.responseEncoderOptions(details, decoded, errDetails)...)
+	if err := encoder.Encode(errDetails, params); err != nil && !erk.IsKind(err, wire.ErkUnableToWrite{}) {
+		errDetails := `{"err":true,"ierr":true}`
+		errParams := `{"kind":"wire_encoding_error","message":"unable to encode error to wire format"}`
+		w.Write([]byte(`1,24,80:` + errDetails + errParams))
+	}
+}
+
+// responseEncoderOptions encode the response like the request: in its encoding, with a checksum if it had one,
+// and compressed if it accepts compression. The details and request are nil if the request could not be decoded.
+func (s *Service) responseEncoderOptions(details *strand.RequestDetails, decoded *wire.DecodeResult, respDetails *strand.ResponseDetails) []wire.EncoderOption {
+	if decoded == nil {
+		return nil
+	}
+
+	opts := []wire.EncoderOption{wire.WithEncoding(decoded.Encoding)}
+	if decoded.Checksummed {
+		opts = append(opts, wire.WithChecksum())
+	}
+
+	if compression := s.negotiateCompression(details, respDetails); compression != wire.CompressionNone {
+		opts = append(opts, wire.WithCompression(compression, s.options.compressionMin))
+	}
+
+	return opts
+}
+
+// negotiateCompression lists the compressions the service accepts in the response details, if the request accepts any,
+// and returns the first compression accepted by the request that the service supports.
+// Requests that do not accept compression, such as those from older callers, never receive compressed responses.
+func (s *Service) negotiateCompression(details *strand.RequestDetails, respDetails *strand.ResponseDetails) wire.Compression {
+	if details == nil || len(details.AcceptCompression) == 0 {
+		return wire.CompressionNone
+	}
+
+	respDetails.AcceptCompression = wire.Compressions()
+	if s.options.compressionMin < 0 {
+		return wire.CompressionNone
+	}
+
+	for _, compression := range details.AcceptCompression {
+		if _, ok := wire.LookupCompressor(compression); ok {
+			return compression
 		}
+	}
+
+	return wire.CompressionNone
+}
+
+// isInternalError returns true if the error is sent to the caller as an internal error.
+// Only errors returned by functions are not internal, unless the function panicked,
+// and frames that are too large, since the caller sent them.
+func isInternalError(err error) bool {
+	if panicErr(err) != nil {
+		return true
+	}
+	if isFrameTooLarge(err) {
+		return false
+	}
+
+	return !errors.Is(err, ErrFunctionCallFailed) || errors.Unwrap(err) == nil
+}
+
+// panicErr returns the error in the chain caused by a panic, or nil if the function did not panic.
+func panicErr(err error) error {
+	for wrappedErr := err; wrappedErr != nil; wrappedErr = errors.Unwrap(wrappedErr) {
+		if erk.IsKind(wrappedErr, ErkFunctionPanicked{}) {
+			return wrappedErr
+		}
+	}
+
+	return nil
+}
+
+// isFrameTooLarge returns true if the request was rejected for being over a size limit.
+func isFrameTooLarge(err error) bool {
+	if errors.Is(err, ErrBodyTooLarge) {
+		return true
+	}
+
+	for wrappedErr := err; wrappedErr != nil; wrappedErr = errors.Unwrap(wrappedErr) {
+		if erk.IsKind(wrappedErr, wire.ErkFrameTooLarge{}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exportEventError exports the error sent to the caller, and whether it is internal.
+// Error params are redacted according to the service's ErrorParamsPolicy.
+func (s *Service) exportEventError(err error) (interface{}, bool) {
+	// Panics are always internal, and only the panic error is exported
+	if panicErr := panicErr(err); panicErr != nil {
+		return erk.Export(s.redactErrorParams(panicErr)), true
+	}
+
+	if isInternalError(err) {
+		return erk.Export(s.redactErrorParams(erk.ToErk(err))), true
+	}
+
+	// The caller sent a frame that is too large, so the error is exported as a bad request
+	if isFrameTooLarge(err) {
+		return erk.Export(s.redactErrorParams(erk.ToErk(err))), false
+	}
+
+	// The function call failed, so the error returned by the function is exported
+	wrappedErr := s.redactErrorParams(errors.Unwrap(err))
 
-		// Fallback to calling the Error method
-		return wrappedErr.Error(), false
+	// Check if an "Export" method exists with no input params and one return value
+	exportMethod := reflect.ValueOf(wrappedErr).MethodByName("Export")
+	if exportMethod.IsValid() && exportMethod.Type().NumOut() == 1 && exportMethod.Type().NumIn() == 0 {
+		// Call the "Export" method
+		ret := exportMethod.Call([]reflect.Value{})
+		return ret[0].Interface(), false
 	}
 
-	return erk.Export(err), true
+	// Fallback to calling the Error method
+	return wrappedErr.Error(), false
 }
@@ -2,19 +2,23 @@ package hoist_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/JosiahWitt/erk"
 	"github.com/hoistup/hoist-go/erks"
 	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/msgpack"
 	"github.com/hoistup/hoist-go/strand"
 	"github.com/hoistup/hoist-go/wire"
 	"github.com/matryer/is"
@@ -79,7 +83,12 @@ func TestServe(t *testing.T) {
 	})
 
 	t.Run("with running service", setPORT(func(t *testing.T) {
-		startService()
+		s := startService()
+		defer func() {
+			if err := s.Shutdown(context.Background()); err != nil {
+				t.Errorf("while shutting down, expected no err, got: %v", err)
+			}
+		}()
 
 		t.Run("with valid service", func(t *testing.T) {
 			is := is.New(t)
@@ -214,6 +223,221 @@ func TestServe(t *testing.T) {
 			errEqual(is, strands, ErrErkError)
 		})
 
+		t.Run("with invalid params", func(t *testing.T) {
+			is := is.New(t)
+
+			reqDetails := strand.RequestDetails{
+				RequestID:    reqID,
+				ServiceName:  "abc",
+				FunctionName: "validated",
+			}
+
+			respDetails, _, strands, err := makeRequest(&reqDetails, &TestParams{})
+			is.NoErr(err)
+
+			is.Equal(respDetails, &errRespDetailsNotInternal)
+			errEqual(is, strands, hoist.ErrParamsInvalid, "params are invalid: Message is required")
+
+			var respErr struct {
+				Params struct{ Fields []hoist.FieldError }
+			}
+			is.NoErr(json.Unmarshal(strands.RawParams, &respErr))
+			is.Equal(respErr.Params.Fields, []hoist.FieldError{{Path: "Message", Rule: "required", Message: "is required"}})
+		})
+
+		t.Run("with function that panics", func(t *testing.T) {
+			is := is.New(t)
+
+			reqDetails := strand.RequestDetails{
+				RequestID:    reqID,
+				ServiceName:  "abc",
+				FunctionName: "panic",
+			}
+
+			respDetails, _, strands, err := makeRequest(&reqDetails, nil)
+			is.NoErr(err)
+
+			is.Equal(respDetails, &errRespDetailsInternalReqID)
+			errEqual(is, strands, hoist.ErrFunctionPanicked, "function 'panic' panicked: boom")
+			is.True(!strings.Contains(string(strands.RawParams), "goroutine"))
+		})
+
+		t.Run("export in wire format", func(t *testing.T) {
+			is := is.New(t)
+
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/_/v1/export", os.Getenv("PORT")))
+			is.NoErr(err)
+			defer resp.Body.Close()
+			etag := resp.Header.Get("ETag")
+			is.True(etag != "")
+
+			strands, err := wire.NewDecoder(resp.Body).Decode()
+			is.NoErr(err)
+
+			var exported hoist.ExportedService
+			is.NoErr(json.Unmarshal(strands.RawParams, &exported))
+			is.Equal(exported.Name, "abc")
+			is.Equal(exported.Functions["echo"].Params.AnyOf[0].Ref, "#/definitions/TestParams")
+
+			// Polling with the ETag, which is parsed as described in RFC 7232 section 3.2
+			for _, entry := range []struct {
+				IfNoneMatch    []string
+				ExpectedStatus int
+			}{
+				{IfNoneMatch: []string{etag}, ExpectedStatus: http.StatusNotModified},
+				{IfNoneMatch: []string{"W/" + etag}, ExpectedStatus: http.StatusNotModified},
+				{IfNoneMatch: []string{`"other", ` + etag}, ExpectedStatus: http.StatusNotModified},
+				{IfNoneMatch: []string{`"other"`, etag}, ExpectedStatus: http.StatusNotModified},
+				{IfNoneMatch: []string{"*"}, ExpectedStatus: http.StatusNotModified},
+				{IfNoneMatch: []string{`"other", W/"another"`}, ExpectedStatus: http.StatusOK},
+				{IfNoneMatch: []string{strings.Trim(etag, `"`)}, ExpectedStatus: http.StatusOK},
+			} {
+				req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%s/_/v1/export", os.Getenv("PORT")), nil)
+				is.NoErr(err)
+				req.Header["If-None-Match"] = entry.IfNoneMatch
+				resp2, err := http.DefaultClient.Do(req)
+				is.NoErr(err)
+				resp2.Body.Close()
+				is.Equal(resp2.StatusCode, entry.ExpectedStatus) // If-None-Match matches
+			}
+		})
+
+		t.Run("export in human readable formats", func(t *testing.T) {
+			is := is.New(t)
+
+			for format, expected := range map[string]string{"markdown": "## echo", "html": `<h2 id="fn-echo">echo</h2>`} {
+				resp, err := http.Get(fmt.Sprintf("http://localhost:%s/_/v1/export?format=%s", os.Getenv("PORT"), format))
+				is.NoErr(err)
+				body, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				is.NoErr(err)
+				is.True(strings.Contains(string(body), expected))
+			}
+		})
+
+		t.Run("export in unknown format", func(t *testing.T) {
+			is := is.New(t)
+
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/_/v1/export?format=pdf", os.Getenv("PORT")))
+			is.NoErr(err)
+			defer resp.Body.Close()
+			is.Equal(resp.StatusCode, http.StatusBadRequest)
+
+			strands, err := wire.NewDecoder(resp.Body).Decode()
+			is.NoErr(err)
+
+			var details strand.ResponseDetails
+			is.NoErr(json.Unmarshal(strands.RawDetails, &details))
+			is.Equal(details, strand.ResponseDetails{IsError: true})
+			errEqual(is, strands, hoist.ErrExportFormatInvalid, "export format 'pdf' is not supported, use 'wire', 'markdown', or 'html'")
+		})
+
+		t.Run("with request that accepts compression", func(t *testing.T) {
+			is := is.New(t)
+
+			reqDetails := strand.RequestDetails{
+				RequestID:         reqID,
+				ServiceName:       "abc",
+				FunctionName:      "echo",
+				AcceptCompression: []wire.Compression{99, wire.CompressionGzip},
+			}
+			message := strings.Repeat("testing 123 ", 200)
+
+			respDetails, respParams, strands, err := makeRequest(&reqDetails, &TestParams{Message: message})
+			is.NoErr(err)
+
+			is.Equal(strands.Compression, wire.CompressionGzip)
+			is.Equal(respDetails, &strand.ResponseDetails{
+				RequestID:         reqID,
+				AcceptCompression: wire.Compressions(),
+			})
+			is.Equal(respParams, &TestParams{Message: "echo: " + message})
+
+			// Small results are not compressed
+			_, _, strands, err = makeRequest(&reqDetails, &TestParams{Message: "hi"})
+			is.NoErr(err)
+			is.Equal(strands.Compression, wire.CompressionNone)
+		})
+
+		t.Run("with checksummed request", func(t *testing.T) {
+			is := is.New(t)
+
+			reqDetails := strand.RequestDetails{
+				RequestID:    reqID,
+				ServiceName:  "abc",
+				FunctionName: "echo",
+			}
+
+			var body bytes.Buffer
+			is.NoErr(wire.NewEncoder(&body, wire.WithChecksum()).Encode(&reqDetails, &TestParams{Message: "hi"}))
+
+			strands, err := makeRawRequest(body.Bytes())
+			is.NoErr(err)
+			is.True(strands.Checksummed)
+
+			// Corrupt the params
+			corrupted := bytes.Replace(body.Bytes(), []byte("hi"), []byte("ho"), 1)
+			strands, err = makeRawRequest(corrupted)
+			is.NoErr(err)
+
+			var respErr erk.ExportedError
+			is.NoErr(json.Unmarshal(strands.RawParams, &respErr))
+			is.Equal(respErr.Kind, erk.GetKindString(wire.ErrChecksumMismatch))
+		})
+
+		t.Run("with MessagePack request", func(t *testing.T) {
+			is := is.New(t)
+
+			reqDetails := strand.RequestDetails{
+				RequestID:    reqID,
+				ServiceName:  "abc",
+				FunctionName: "echo",
+			}
+
+			var body bytes.Buffer
+			is.NoErr(wire.NewEncoder(&body, wire.WithEncoding(wire.EncodingMsgPack)).Encode(&reqDetails, &TestParams{Message: "hi"}))
+
+			strands, err := makeRawRequest(body.Bytes())
+			is.NoErr(err)
+			is.Equal(strands.Encoding, wire.EncodingMsgPack)
+
+			var respDetails strand.ResponseDetails
+			is.NoErr(msgpack.Unmarshal(strands.RawDetails, &respDetails))
+			is.Equal(respDetails, strand.ResponseDetails{RequestID: reqID})
+
+			var respParams TestParams
+			is.NoErr(msgpack.Unmarshal(strands.RawParams, &respParams))
+			is.Equal(respParams, TestParams{Message: "echo: hi"})
+		})
+
+		t.Run("with MessagePack request that fails", func(t *testing.T) {
+			is := is.New(t)
+
+			reqDetails := strand.RequestDetails{
+				RequestID:    reqID,
+				ServiceName:  "abc",
+				FunctionName: "echo",
+			}
+
+			// The params are a string, instead of a map
+			var body bytes.Buffer
+			is.NoErr(wire.NewEncoder(&body, wire.WithEncoding(wire.EncodingMsgPack)).Encode(&reqDetails, "hi"))
+
+			strands, err := makeRawRequest(body.Bytes())
+			is.NoErr(err)
+			is.Equal(strands.Encoding, wire.EncodingMsgPack)
+
+			var respDetails strand.ResponseDetails
+			is.NoErr(msgpack.Unmarshal(strands.RawDetails, &respDetails))
+			is.Equal(respDetails, errRespDetailsNotInternal)
+
+			var respErr erk.ExportedError
+			is.NoErr(msgpack.Unmarshal(strands.RawParams, &respErr))
+			is.Equal(respErr.Kind, erk.GetKindString(hoist.ErrFunctionCallUnmarshal))
+			is.Equal(respErr.Params["encoding"], "msgpack")
+			is.Equal(respErr.Params["originalParams"], `"hi"`)
+		})
+
 		t.Run("with function that returns unmarshalable error", func(t *testing.T) {
 			is := is.New(t)
 
@@ -246,7 +470,7 @@ func setPORT(fn func(t *testing.T)) func(t *testing.T) {
 	}
 }
 
-func startService() {
+func startService() *hoist.Service {
 	s := hoist.NewService("abc")
 
 	s.RegisterAs("echo", func(ctx *TestContext, params *TestParams) (*TestParams, error) {
@@ -270,12 +494,21 @@ func startService() {
 		return nil, ErrErkError
 	})
 
+	s.RegisterAs("validated", func(ctx *TestContext, params *struct {
+		Message string `hoist:"required"`
+	}) error {
+		return nil
+	})
+
+	s.RegisterAs("panic", func(ctx *TestContext, params *TestParams) (chan int, error) {
+		panic("boom")
+	})
+
 	s.RegisterAs("unmarshalable-error", func(ctx *TestContext, params *TestParams) (chan int, error) {
 		return nil, erk.WithParam(ErrErkError, "unmarshalable", make(chan int))
 	})
 
 	// Start the service in a goroutine
-	// TODO: Shutdown the service
 	go func() {
 		err := s.Serve()
 		if err != nil {
@@ -283,10 +516,35 @@ func startService() {
 		}
 	}()
 
-	// Wait until the server starts
+	waitForServer()
+	return s
+}
+
+// newTestService creates a service named "abc" with the options, and registers the functions by name.
+func newTestService(fns map[string]interface{}, opts ...hoist.Option) *hoist.Service {
+	s := hoist.NewService("abc", opts...)
+	for name, fn := range fns {
+		s.RegisterAs(name, fn)
+	}
+
+	return s
+}
+
+// echoParams returns the params it is called with.
+func echoParams(ctx *TestContext, params *TestParams) (*TestParams, error) {
+	return params, nil
+}
+
+// waitForServer blocks until the server on PORT starts.
+func waitForServer() {
+	waitForAddr("localhost:" + os.Getenv("PORT"))
+}
+
+// waitForAddr blocks until a server is listening on addr.
+func waitForAddr(addr string) {
 	deadline := time.Now().Add(30 * time.Second)
 	for {
-		_, err := http.Get(fmt.Sprintf("http://localhost:%s", os.Getenv("PORT")))
+		_, err := http.Get(fmt.Sprintf("http://%s", addr))
 		if err == nil {
 			break
 		}
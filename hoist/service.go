@@ -2,28 +2,82 @@
 package hoist
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
 	"sync"
+
+	"github.com/JosiahWitt/erk"
 )
 
-// rawFunc is an internal representation of a registered function.
-type rawFunc func(rawParams []byte) (interface{}, error)
+// function is an internal representation of a registered function.
+type function struct {
+	call       Invoker
+	paramsType reflect.Type // nil when the function takes no params
+	dataType   reflect.Type // nil when the function returns no data, and the item type of channels that stream
+	streams    bool
+}
 
 // Service represents a server instance of a hoist application.
 type Service struct {
 	mu sync.RWMutex
 
-	name   string
-	errors []error
+	name    string
+	errors  []error
+	options options
+
+	funcs map[string]*function
+
+	typeHooks []*hook
+	tagHooks  map[string]*hook
 
-	funcs map[string]rawFunc
+	interceptors     []Interceptor
+	funcInterceptors map[string][]Interceptor
+
+	metrics *metrics
+
+	server        *http.Server
+	connListeners map[net.Listener]struct{}
+	conns         map[trackedConn]struct{}
+	connsDone     sync.WaitGroup
+	calls         sync.WaitGroup
+	shuttingDown  bool
+	shutdownHooks []func(ctx context.Context) error
+	shutdownOnce  sync.Once
+	shutdownDone  chan struct{}
+	shutdownErr   error
 }
 
-// NewService creates a new service with the provided name.
-func NewService(name string) *Service {
-	return &Service{
-		name:  name,
-		funcs: make(map[string]rawFunc),
+// NewService creates a new service with the provided name and options.
+func NewService(name string, opts ...Option) *Service {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	buckets, invalidBucket, hasInvalidBucket := validBuckets(o.metricsBuckets)
+
+	s := &Service{
+		name:      name,
+		options:   o,
+		funcs:     make(map[string]*function),
+		typeHooks: []*hook{contextHook, senderHook},
+		tagHooks:  make(map[string]*hook),
+
+		funcInterceptors: make(map[string][]Interceptor),
+		metrics:          newMetrics(buckets),
+
+		connListeners: make(map[net.Listener]struct{}),
+		conns:         make(map[trackedConn]struct{}),
+		shutdownDone:  make(chan struct{}),
 	}
+
+	if hasInvalidBucket {
+		s.errors = append(s.errors, erk.WithParams(ErrMetricsBucketInvalid, erk.Params{"serviceName": name, "bound": invalidBucket}))
+	}
+
+	return s
 }
 
 // Errors returns all the errors associated with the service.
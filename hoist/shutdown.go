@@ -0,0 +1,149 @@
+package hoist
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/JosiahWitt/erk/erg"
+	"github.com/hoistup/hoist-go/erks"
+)
+
+type (
+	ErkShutdown     struct{ erks.Default }
+	ErkShuttingDown struct{ erks.Default }
+)
+
+var (
+	ErrAlreadyServing       = erk.New(ErkHoistInit{}, "service '{{.serviceName}}' is already serving")
+	ErrShutdown             = erk.New(ErkShutdown{}, "service '{{.serviceName}}' did not shut down cleanly")
+	ErrShutdownServer       = erk.New(ErkShutdown{}, "could not stop the server: {{.err}}")
+	ErrShutdownDrainTimeout = erk.New(ErkShutdown{}, "in-flight calls did not finish before the shutdown deadline: {{.err}}")
+	ErrShutdownHook         = erk.New(ErkShutdown{}, "shutdown hook failed: {{.err}}")
+	ErrShuttingDown         = erk.New(ErkShuttingDown{}, "service '{{.serviceName}}' is shutting down")
+)
+
+// DefaultShutdownTimeout is how long ServeContext waits for in-flight calls when shutting down,
+// unless WithShutdownTimeout is provided.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// OnShutdown registers a hook that is called when the service shuts down, after in-flight calls finish.
+// Hooks are called in the order they are registered.
+func (s *Service) OnShutdown(hook func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
+// Shutdown gracefully shuts down the service.
+//
+// It stops accepting connections, including persistent connections, rejects new calls with ErrShuttingDown, and waits for in-flight
+// calls to finish or ctx to be done. Then it calls the shutdown hooks with ctx.
+//
+// Shutdown returns nil when the service shuts down cleanly.
+// Calling Shutdown more than once returns the result of the first call.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown(ctx)
+		close(s.shutdownDone)
+	})
+
+	<-s.shutdownDone
+	return s.shutdownErr
+}
+
+func (s *Service) shutdown(ctx context.Context) error {
+	var errs []error
+
+	// No new calls are started after this point
+	s.mu.Lock()
+	s.shuttingDown = true
+	server := s.server
+	hooks := s.shutdownHooks
+	s.mu.Unlock()
+
+	// Stop accepting connections, and wait for in-flight requests
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, erk.WrapAs(ErrShutdownServer, err))
+		}
+	}
+
+	// Stop accepting persistent connections, and reading new calls from open ones
+	s.stopConns(false)
+
+	// Wait for calls that did not come through the server, and for persistent connections to write their responses
+	drained := make(chan struct{})
+	go func() {
+		s.calls.Wait()
+		s.connsDone.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		s.stopConns(true)
+		errs = append(errs, erk.WrapAs(ErrShutdownDrainTimeout, ctx.Err()))
+	}
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, erk.WrapAs(ErrShutdownHook, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return erg.NewAs(erk.WithParam(ErrShutdown, "serviceName", s.name), errs...)
+	}
+
+	return nil
+}
+
+// startCall tracks an in-flight call, so Shutdown can wait for it.
+// The returned function must be called when the call finishes.
+func (s *Service) startCall() (func(), error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.shuttingDown {
+		return nil, erk.WithParam(ErrShuttingDown, "serviceName", s.name)
+	}
+
+	s.calls.Add(1)
+	return s.calls.Done, nil
+}
+
+// SignalContext returns a copy of parent that is canceled when the process receives one of the signals,
+// or SIGINT and SIGTERM if no signals are provided.
+//
+// It is useful with ServeContext:
+//  ctx, cancel := hoist.SignalContext(context.Background())
+//  defer cancel()
+//  err := service.ServeContext(ctx)
+func SignalContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-ctx.Done():
+		}
+
+		signal.Stop(ch)
+	}()
+
+	return ctx, cancel
+}
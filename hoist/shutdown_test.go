@@ -0,0 +1,225 @@
+package hoist_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/matryer/is"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Run("ServeContext drains calls and runs hooks", setPORT(func(t *testing.T) {
+		is := is.New(t)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		s := hoist.NewService("abc")
+		s.RegisterAs("slow", func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			close(started)
+			<-release
+			return params, nil
+		})
+
+		var hookCalls []string
+		s.OnShutdown(func(context.Context) error {
+			hookCalls = append(hookCalls, "first")
+			return nil
+		})
+		s.OnShutdown(func(context.Context) error {
+			hookCalls = append(hookCalls, "second")
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(ctx) }()
+		waitForServer()
+
+		// Start a call that is in-flight during shutdown
+		type callResult struct {
+			details *strand.ResponseDetails
+			params  *TestParams
+			err     error
+		}
+		callDone := make(chan callResult)
+		go func() {
+			details, params, _, err := makeRequest(&strand.RequestDetails{RequestID: reqID, FunctionName: "slow"}, &TestParams{Message: "hi"})
+			callDone <- callResult{details, params, err}
+		}()
+		<-started
+
+		cancel()
+
+		// Calls started after shutdown are rejected
+		time.Sleep(50 * time.Millisecond)
+		_, err := s.Call("slow", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrShuttingDown))
+
+		close(release)
+		result := <-callDone
+		is.NoErr(result.err)
+		is.Equal(result.params, &TestParams{Message: "hi"})
+
+		is.NoErr(<-serveErr)
+		is.Equal(hookCalls, []string{"first", "second"})
+	}))
+
+	t.Run("Shutdown reports drain timeout and hook errors", func(t *testing.T) {
+		is := is.New(t)
+		hookErr := errors.New("hook failed")
+
+		release := make(chan struct{})
+		defer close(release)
+
+		s := hoist.NewService("abc")
+		s.RegisterAs("blocked", func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			<-release
+			return params, nil
+		})
+		s.OnShutdown(func(context.Context) error { return hookErr })
+
+		called := make(chan struct{})
+		go func() {
+			close(called)
+			s.Call("blocked", []byte(`{}`))
+		}()
+		<-called
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := s.Shutdown(ctx)
+		is.True(errors.Is(err, hoist.ErrShutdown))
+		is.True(errors.Is(err, hoist.ErrShutdownDrainTimeout))
+		is.True(errors.Is(err, hoist.ErrShutdownHook))
+
+		// Later calls return the same result
+		is.Equal(s.Shutdown(context.Background()), err)
+	})
+
+	t.Run("Serve after Shutdown", setPORT(func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("abc")
+		serveErr := make(chan error)
+		go func() { serveErr <- s.Serve() }()
+		waitForServer()
+
+		is.NoErr(s.Shutdown(context.Background()))
+		is.NoErr(<-serveErr)
+		is.True(errors.Is(s.Serve(), hoist.ErrAlreadyServing))
+	}))
+
+	t.Run("ServeContext after Shutdown without serving", setPORT(func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("abc")
+		is.NoErr(s.Shutdown(context.Background()))
+
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(context.Background()) }()
+
+		select {
+		case err := <-serveErr:
+			is.True(errors.Is(err, hoist.ErrShuttingDown))
+		case <-time.After(time.Second):
+			t.Fatal("ServeContext did not return")
+		}
+	}))
+
+	t.Run("Serve after the server fails", func(t *testing.T) {
+		is := is.New(t)
+
+		inner, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+		defer inner.Close()
+
+		listener := &failingListener{Listener: inner, fail: 1}
+		s := hoist.NewService("abc", hoist.WithListener(listener))
+		is.Equal(s.Serve(), errListenerFailed)
+
+		// The service can be served again
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(ctx) }()
+		waitForAddr(inner.Addr().String())
+
+		cancel()
+		is.NoErr(<-serveErr)
+	})
+
+	t.Run("Serve again while serving with WithListener", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+
+		s := hoist.NewService("abc", hoist.WithListener(listener))
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(ctx) }()
+		waitForAddr(listener.Addr().String())
+
+		is.True(errors.Is(s.Serve(), hoist.ErrAlreadyServing))
+
+		// The listener of the running server is still open
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		is.NoErr(err)
+		conn.Close()
+
+		cancel()
+		is.NoErr(<-serveErr)
+	})
+}
+
+var errListenerFailed = errors.New("listener failed")
+
+// failingListener fails to accept the first connection, and stays open when the failed server closes it.
+type failingListener struct {
+	net.Listener
+	fail   int32
+	closes int32
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	if atomic.AddInt32(&l.fail, -1) >= 0 {
+		return nil, errListenerFailed
+	}
+
+	return l.Listener.Accept()
+}
+
+func (l *failingListener) Close() error {
+	if atomic.AddInt32(&l.closes, 1) == 1 {
+		return nil
+	}
+
+	return l.Listener.Close()
+}
+
+func TestSignalContext(t *testing.T) {
+	is := is.New(t)
+
+	ctx, cancel := hoist.SignalContext(context.Background(), syscall.SIGUSR1)
+	defer cancel()
+
+	p, err := os.FindProcess(os.Getpid())
+	is.NoErr(err)
+	is.NoErr(p.Signal(syscall.SIGUSR1))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not canceled by the signal")
+	}
+}
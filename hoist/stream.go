@@ -0,0 +1,161 @@
+package hoist
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/JosiahWitt/erk"
+)
+
+var (
+	ErrStreamingReturnsData = erk.New(ErkInvalidFunction{}, "functions that stream with a Sender can only return an error")
+	ErrStreamCanceled       = erk.New(ErkCallCanceled{}, "stream was canceled before the channel was closed: {{.err}}")
+	ErrStreamFinished       = erk.New(ErkFunctionCall{}, "items cannot be sent after the function returns")
+)
+
+// Sender sends the items of a streamed response, as the function produces them.
+//
+// Functions stream by having a context field of type Sender, which is filled for every call:
+//  type StreamContext struct {
+//    Sender hoist.Sender
+//  }
+//
+//  func exportRows(ctx *StreamContext, params *ExportParams) error {
+//    for _, row := range rows {
+//      if err := ctx.Sender.Send(row); err != nil {
+//        return err
+//      }
+//    }
+//    return nil
+//  }
+//
+// Functions can also stream by returning a receive only channel, such as (<-chan Row, error).
+// Its items are sent until it is closed, or the call is canceled. The function should stop sending
+// to the channel when its context is canceled, since the channel is no longer read.
+//
+// Callers that accept streams (see strand.RequestDetails.AcceptStream) receive a frame for each item,
+// followed by a final frame. Other callers, including Call, receive all the items at once, in a list.
+type Sender interface {
+	// Send the item to the caller. It is safe for concurrent use, but must not be called after the function returns.
+	// If the item cannot be encoded or written, the error is returned, and the function should return it.
+	Send(item interface{}) error
+}
+
+// SendFunc receives the items of a streamed response, as they are sent (see CallStream).
+type SendFunc func(item interface{}) error
+
+// senderType allows us to check if a context field is a Sender.
+var senderType = reflect.TypeOf(new(Sender)).Elem()
+
+// senderHook is registered on every service, so functions can stream with a Sender.
+var senderHook = &hook{
+	returnType: senderType,
+	fn: reflect.ValueOf(func(req *Request) (Sender, error) {
+		return req.stream, nil
+	}),
+}
+
+// stream passes the items of a call to a SendFunc, or collects them when there is none.
+type stream struct {
+	mu       sync.Mutex
+	send     SendFunc
+	items    []interface{}
+	finished bool
+}
+
+func (s *stream) Send(item interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished {
+		return ErrStreamFinished
+	}
+
+	if s.send == nil {
+		s.items = append(s.items, item)
+		return nil
+	}
+
+	return s.send(item)
+}
+
+// drain sends the items received from the channel, until it is closed or ctx is done.
+func (s *stream) drain(ctx context.Context, ch reflect.Value) (interface{}, error) {
+	if ch.IsNil() {
+		return s.result(), nil
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 1 {
+			return nil, erk.WrapAs(ErrStreamCanceled, ctx.Err())
+		}
+		if !ok {
+			return s.result(), nil
+		}
+
+		if err := s.Send(item.Interface()); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// result of a streaming function: nil if the items were sent, or the list of collected items.
+func (s *stream) result() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.send != nil {
+		return nil
+	}
+
+	if s.items == nil {
+		return []interface{}{}
+	}
+
+	return s.items
+}
+
+// finish the stream, so later items are rejected.
+func (s *stream) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finished = true
+}
+
+// streams returns true if the function is registered, and streams its response.
+func (s *Service) streams(fnName string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fn, ok := s.funcs[fnName]
+	return ok && fn.streams
+}
+
+// streamsWithSender returns true if the context filler fills a Sender.
+func (c *contextFiller) streamsWithSender() bool {
+	if c.whole != nil {
+		return c.whole == senderHook
+	}
+
+	for _, field := range c.fields {
+		if field.hook == senderHook {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isStreamChannel returns true if the type is a receive only channel, which streams its items.
+// Bidirectional channels are not streamed.
+func isStreamChannel(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Chan && t.ChanDir() == reflect.RecvDir
+}
@@ -0,0 +1,210 @@
+package hoist_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+type StreamContext struct {
+	Sender hoist.Sender
+}
+
+func TestStream(t *testing.T) {
+	release := make(chan struct{})
+	fns := map[string]interface{}{
+		"channel": func(ctx context.Context, params *TestParams) (<-chan *TestParams, error) {
+			items := make(chan *TestParams)
+			go func() {
+				defer close(items)
+				for _, message := range []string{"a", "b"} {
+					select {
+					case items <- &TestParams{Message: message}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return items, nil
+		},
+		"sender": func(ctx *StreamContext, params *TestParams) error {
+			for _, message := range []string{"a", "b"} {
+				if err := ctx.Sender.Send(&TestParams{Message: message}); err != nil {
+					return err
+				}
+				if params.Message == "slow" {
+					<-release
+				}
+			}
+			return nil
+		},
+		"failing": func(ctx *StreamContext, params *TestParams) error {
+			if err := ctx.Sender.Send(&TestParams{Message: "a"}); err != nil {
+				return err
+			}
+			return ErrErkError
+		},
+		"empty": func(ctx *StreamContext, params *TestParams) error {
+			return nil
+		},
+		"echo": echoParams,
+	}
+
+	t.Run("callers that do not accept streams receive a list", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newTestService(fns)
+		for _, fnName := range []string{"channel", "sender"} {
+			data, err := s.Call(fnName, []byte(`{}`))
+			is.NoErr(err)
+			is.Equal(data, []interface{}{&TestParams{Message: "a"}, &TestParams{Message: "b"}})
+		}
+
+		data, err := s.Call("empty", []byte(`{}`))
+		is.NoErr(err)
+		is.Equal(data, []interface{}{})
+	})
+
+	table := []struct {
+		Name     string
+		FnName   string
+		Expected []strand.ResponseDetails
+		Items    []string
+	}{
+		{
+			Name:   "streams the items of a channel",
+			FnName: "channel",
+			Expected: []strand.ResponseDetails{
+				{RequestID: reqID, Sequence: 1},
+				{RequestID: reqID, Sequence: 2},
+				{RequestID: reqID, Sequence: 3, EndOfStream: true},
+			},
+			Items: []string{"a", "b"},
+		},
+		{
+			Name:   "streams the items of a Sender",
+			FnName: "sender",
+			Expected: []strand.ResponseDetails{
+				{RequestID: reqID, Sequence: 1},
+				{RequestID: reqID, Sequence: 2},
+				{RequestID: reqID, Sequence: 3, EndOfStream: true},
+			},
+			Items: []string{"a", "b"},
+		},
+		{
+			Name:   "ends the stream with the error",
+			FnName: "failing",
+			Expected: []strand.ResponseDetails{
+				{RequestID: reqID, Sequence: 1},
+				{RequestID: reqID, IsError: true, Sequence: 2, EndOfStream: true},
+			},
+			Items: []string{"a"},
+		},
+		{
+			Name:   "with stream without items",
+			FnName: "empty",
+			Expected: []strand.ResponseDetails{
+				{RequestID: reqID, Sequence: 1, EndOfStream: true},
+			},
+		},
+		{
+			Name:   "with function that does not stream",
+			FnName: "echo",
+			Expected: []strand.ResponseDetails{
+				{RequestID: reqID},
+			},
+			Items: []string{"hi"},
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			s := newTestService(fns)
+			conn, serverConn := net.Pipe()
+			defer conn.Close()
+			go s.ServeConn(serverConn)
+
+			go func() {
+				details := &strand.RequestDetails{RequestID: reqID, FunctionName: entry.FnName, AcceptStream: true}
+				wire.NewEncoder(conn).Encode(details, &TestParams{Message: "hi"})
+			}()
+
+			decoder := wire.NewDecoder(conn)
+			for i, expected := range entry.Expected {
+				details, params := readConnResponse(t, decoder)
+				is.Equal(*details, expected)
+				if i < len(entry.Items) {
+					is.Equal(params, &TestParams{Message: entry.Items[i]})
+				}
+			}
+		})
+	}
+
+	t.Run("items are sent over HTTP as they are streamed", func(t *testing.T) {
+		is := is.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		is.NoErr(err)
+		s := newTestService(fns, hoist.WithListener(listener))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(ctx) }()
+		defer func() {
+			cancel()
+			<-serveErr
+		}()
+
+		body, err := wire.Encode(&strand.RequestDetails{RequestID: reqID, FunctionName: "sender", AcceptStream: true}, &TestParams{Message: "slow"})
+		is.NoErr(err)
+		resp, err := http.Post("http://"+listener.Addr().String()+"/_/v1/fn", "", bytes.NewReader(body))
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		// The first item arrives while the function waits
+		decoder := wire.NewDecoder(resp.Body)
+		details, params := readConnResponse(t, decoder)
+		is.Equal(details, &strand.ResponseDetails{RequestID: reqID, Sequence: 1})
+		is.Equal(params, &TestParams{Message: "a"})
+
+		close(release)
+		details, params = readConnResponse(t, decoder)
+		is.Equal(details, &strand.ResponseDetails{RequestID: reqID, Sequence: 2})
+		is.Equal(params, &TestParams{Message: "b"})
+		details, _ = readConnResponse(t, decoder)
+		is.Equal(details, &strand.ResponseDetails{RequestID: reqID, Sequence: 3, EndOfStream: true})
+	})
+
+	t.Run("functions that stream with a Sender cannot return data", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("abc")
+		s.RegisterAs("bad", func(ctx *StreamContext, params *TestParams) (*TestParams, error) {
+			return params, nil
+		})
+
+		is.Equal(len(s.Errors()), 1)
+		is.True(errors.Is(s.Errors()[0], hoist.ErrStreamingReturnsData))
+	})
+
+	t.Run("exports streaming functions", func(t *testing.T) {
+		is := is.New(t)
+
+		exported := newTestService(fns).Export()
+		is.True(exported.Functions["channel"].Streams)
+		is.True(exported.Functions["sender"].Streams)
+		is.True(!exported.Functions["echo"].Streams)
+		is.Equal(exported.Functions["channel"].Returns, exported.Functions["echo"].Returns)
+	})
+}
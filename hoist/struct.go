@@ -0,0 +1,98 @@
+package hoist
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/JosiahWitt/erk"
+)
+
+var ErrNotStruct = erk.New(ErkInvalidFunction{}, "service '{{.serviceName}}' could not register methods: '{{.type}}' is not a struct or pointer to a struct")
+
+// NamingStrategy converts a method name into a function name.
+type NamingStrategy func(methodName string) string
+
+// StructOptions configure RegisterStruct.
+type StructOptions struct {
+	// Naming converts method names into function names.
+	// Defaults to NameLowerCamel.
+	Naming NamingStrategy
+
+	// FuncOptions are applied to every registered method.
+	FuncOptions []FuncOption
+}
+
+// RegisterStruct registers each exported method of obj as a function, named using the naming strategy.
+//
+// Each method must have the same signature as functions provided to RegisterAs.
+// Methods that do not are reported by Errors.
+// To include methods with pointer receivers, obj must be a pointer.
+func (s *Service) RegisterStruct(obj interface{}, opts StructOptions) {
+	naming := opts.Naming
+	if naming == nil {
+		naming = NameLowerCamel
+	}
+
+	objValue := reflect.ValueOf(obj)
+	objType := reflect.TypeOf(obj)
+	if objType == nil || !(objType.Kind() == reflect.Struct || objType.Kind() == reflect.Ptr && objType.Elem().Kind() == reflect.Struct) {
+		typeName := "<nil>"
+		if objType != nil {
+			typeName = objType.String()
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.errors = append(s.errors, erk.WithParams(ErrNotStruct, erk.Params{"serviceName": s.name, "type": typeName}))
+		return
+	}
+
+	for i := 0; i < objType.NumMethod(); i++ {
+		s.RegisterAs(naming(objType.Method(i).Name), objValue.Method(i).Interface(), opts.FuncOptions...)
+	}
+}
+
+// NameAsIs uses the method name as the function name.
+func NameAsIs(methodName string) string {
+	return methodName
+}
+
+// NameLowerCamel converts the method name to lowerCamelCase, for example: GetUserByID -> getUserByID.
+func NameLowerCamel(methodName string) string {
+	runes := []rune(methodName)
+
+	// Lowercase the leading run of uppercase letters, keeping the start of the next word
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
+
+// NameKebab converts the method name to kebab-case, for example: GetUserByID -> get-user-by-id.
+func NameKebab(methodName string) string {
+	runes := []rune(methodName)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			// Start a word after a lowercase letter or digit, or at the last capital of an acronym
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteRune('-')
+			}
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
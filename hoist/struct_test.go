@@ -0,0 +1,111 @@
+package hoist_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/matryer/is"
+)
+
+type UserStore struct {
+	prefix string
+}
+
+type UserParams struct {
+	ID string
+}
+
+func (u *UserStore) GetUserByID(ctx *MyCtx, params *UserParams) (string, error) {
+	return u.prefix + params.ID, nil
+}
+
+func (u UserStore) HTTPStatus(ctx *MyCtx, params *MyParams) (int, error) {
+	return 200, nil
+}
+
+func (u *UserStore) Reset(a, b, c int) error {
+	return nil
+}
+
+func TestRegisterStruct(t *testing.T) {
+	t.Run("registers methods with the naming strategy", func(t *testing.T) {
+		table := []struct {
+			Naming   hoist.NamingStrategy
+			Expected []string
+		}{
+			{Naming: nil, Expected: []string{"getUserByID", "httpStatus"}},
+			{Naming: hoist.NameLowerCamel, Expected: []string{"getUserByID", "httpStatus"}},
+			{Naming: hoist.NameKebab, Expected: []string{"get-user-by-id", "http-status"}},
+			{Naming: hoist.NameAsIs, Expected: []string{"GetUserByID", "HTTPStatus"}},
+		}
+
+		for _, entry := range table {
+			is := is.New(t)
+
+			s := hoist.NewService("myService")
+			s.RegisterStruct(&UserStore{prefix: "user-"}, hoist.StructOptions{Naming: entry.Naming})
+
+			// Reset does not have a valid signature
+			is.Equal(len(s.Errors()), 1)
+			is.True(errors.Is(s.Errors()[0], hoist.ErrInvalidParameterNumber))
+
+			exported := s.Export()
+			is.Equal(len(exported.Functions), len(entry.Expected))
+			for _, name := range entry.Expected {
+				is.True(exported.Functions[name] != nil)
+			}
+
+			data, err := s.Call(entry.Expected[0], []byte(`{"ID":"abc"}`))
+			is.NoErr(err)
+			is.Equal(data, "user-abc")
+		}
+	})
+
+	t.Run("only value receiver methods of a struct value", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterStruct(UserStore{}, hoist.StructOptions{})
+
+		is.Equal(len(s.Errors()), 0)
+		is.Equal(len(s.Export().Functions), 1)
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterStruct(nil, hoist.StructOptions{})
+		s.RegisterStruct("abc", hoist.StructOptions{})
+
+		is.Equal(len(s.Errors()), 2)
+		is.True(errors.Is(s.Errors()[0], hoist.ErrNotStruct))
+		is.True(errors.Is(s.Errors()[1], hoist.ErrNotStruct))
+	})
+}
+
+func TestNamingStrategies(t *testing.T) {
+	table := []struct {
+		Method     string
+		LowerCamel string
+		Kebab      string
+	}{
+		{Method: "Get", LowerCamel: "get", Kebab: "get"},
+		{Method: "GetUser", LowerCamel: "getUser", Kebab: "get-user"},
+		{Method: "GetUserByID", LowerCamel: "getUserByID", Kebab: "get-user-by-id"},
+		{Method: "HTTPServer", LowerCamel: "httpServer", Kebab: "http-server"},
+		{Method: "ID", LowerCamel: "id", Kebab: "id"},
+		{Method: "V2Call", LowerCamel: "v2Call", Kebab: "v2-call"},
+	}
+
+	for _, entry := range table {
+		t.Run(entry.Method, func(t *testing.T) {
+			is := is.New(t)
+
+			is.Equal(hoist.NameAsIs(entry.Method), entry.Method)
+			is.Equal(hoist.NameLowerCamel(entry.Method), entry.LowerCamel)
+			is.Equal(hoist.NameKebab(entry.Method), entry.Kebab)
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package hoist
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JosiahWitt/erk"
+)
+
+var (
+	ErrParamsInvalid         = erk.New(ErkBadRequest{}, "params are invalid: {{.summary}}")
+	ErrValidationTagInvalid  = erk.New(ErkInvalidFunction{}, "field '{{.field}}' has invalid rule '{{.rule}}': {{.reason}}")
+	ErrValidationRuleInvalid = erk.New(ErkInvalidFunction{}, "field '{{.field}}' of type '{{.type}}' cannot use rule '{{.rule}}'")
+)
+
+// validateTagName is the struct tag containing the validation rules for a params field.
+// See RegisterAs for the rules.
+//
+// Example:
+//  type MyParams struct {
+//    Name  string `json:"name" hoist:"required,min=1,max=64"`
+//    Color string `json:"color" hoist:"enum=red|green|blue"`
+//    Code  string `json:"code" hoist:"pattern=^[A-Z]{2,3}$"`
+//    Token string `json:"token" hoist:"required,secret"`
+//  }
+const validateTagName = "hoist"
+
+// Validation rule names
+const (
+	ruleRequired = "required"
+	ruleMin      = "min"
+	ruleMax      = "max"
+	ruleEnum     = "enum"
+	rulePattern  = "pattern"
+	ruleSecret   = "secret"
+)
+
+// FieldError describes a params field that failed validation.
+// ErrParamsInvalid contains a list of them in the "fields" param.
+type FieldError struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// fieldRules are the parsed validation rules for a struct field.
+type fieldRules struct {
+	index int
+	name  string // JSON name, or empty for promoted fields of embedded structs
+
+	required   bool
+	secret     bool
+	min, max   *float64
+	pattern    *regexp.Regexp
+	enumValues []interface{}
+	enumSet    map[string]bool
+}
+
+// validator validates params against the rules in their struct tags.
+type validator struct {
+	structs map[reflect.Type][]*fieldRules
+	hasRule bool
+}
+
+// newValidator parses the rules of every struct reachable from the params type.
+// It returns nil if the params have no rules.
+func newValidator(paramsType reflect.Type) (*validator, error) {
+	v := &validator{structs: make(map[reflect.Type][]*fieldRules)}
+	if err := v.compile(paramsType); err != nil {
+		return nil, err
+	}
+
+	if !v.hasRule {
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+func (v *validator) compile(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return v.compile(t.Elem())
+
+	case reflect.Struct:
+		if _, ok := v.structs[t]; ok {
+			return nil
+		}
+
+		v.structs[t] = nil // Reserve, to support recursive types
+
+		var fields []*fieldRules
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" && !field.Anonymous { // Unexported
+				continue
+			}
+
+			jsonTag := field.Tag.Get("json")
+			if jsonTag == "-" {
+				continue
+			}
+
+			name, _ := parseJSONTag(jsonTag)
+			if name == "" && !field.Anonymous {
+				name = field.Name
+			}
+
+			rules, err := parseRules(field, name)
+			if err != nil {
+				return err
+			}
+			rules.index = i
+			v.hasRule = v.hasRule || rules.hasRule()
+			fields = append(fields, rules)
+
+			if err := v.compile(field.Type); err != nil {
+				return err
+			}
+		}
+
+		v.structs[t] = fields
+	}
+
+	return nil
+}
+
+// parseRules parses the validation tag of a field.
+func parseRules(field reflect.StructField, name string) (*fieldRules, error) {
+	rules := &fieldRules{name: name}
+
+	tag, ok := field.Tag.Lookup(validateTagName)
+	if !ok || tag == "" {
+		return rules, nil
+	}
+
+	// Rules apply to the value pointed to
+	t := field.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	tagErr := func(rule, reason string) error {
+		return erk.WithParams(ErrValidationTagInvalid, erk.Params{"field": field.Name, "rule": rule, "reason": reason})
+	}
+	ruleErr := func(rule string) error {
+		return erk.WithParams(ErrValidationRuleInvalid, erk.Params{"field": field.Name, "type": field.Type.String(), "rule": rule})
+	}
+
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, rulePattern+"=") {
+			rule, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i != -1 {
+			rule, tag = tag[:i], tag[i+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+
+		key, value := rule, ""
+		if i := strings.Index(rule, "="); i != -1 {
+			key, value = rule[:i], rule[i+1:]
+		}
+
+		switch key {
+		case ruleRequired:
+			rules.required = true
+
+		case ruleSecret:
+			rules.secret = true
+
+		case ruleMin, ruleMax:
+			if !isNumber(t) && !hasLength(t) {
+				return nil, ruleErr(key)
+			}
+
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, tagErr(rule, "not a number")
+			}
+
+			if key == ruleMin {
+				rules.min = &n
+			} else {
+				rules.max = &n
+			}
+
+		case rulePattern:
+			if t.Kind() != reflect.String {
+				return nil, ruleErr(key)
+			}
+
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return nil, tagErr(rule, err.Error())
+			}
+			rules.pattern = pattern
+
+		case ruleEnum:
+			rules.enumSet = make(map[string]bool)
+			for _, rawValue := range strings.Split(value, "|") {
+				enumValue, err := parseEnumValue(t, rawValue)
+				if err != nil {
+					return nil, ruleErr(key)
+				}
+
+				rules.enumValues = append(rules.enumValues, enumValue)
+				rules.enumSet[formatScalar(reflect.ValueOf(enumValue))] = true
+			}
+
+		default:
+			return nil, tagErr(rule, "unknown rule")
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *fieldRules) hasRule() bool {
+	return r.required || r.min != nil || r.max != nil || r.pattern != nil || r.enumSet != nil
+}
+
+// validate the params, returning ErrParamsInvalid listing every failing field.
+func (v *validator) validate(params reflect.Value) error {
+	var failures []FieldError
+	v.validateValue(params, "", &failures)
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		messages = append(messages, failure.Path+" "+failure.Message)
+	}
+
+	return erk.WithParams(ErrParamsInvalid, erk.Params{
+		"summary": strings.Join(messages, "; "),
+		"fields":  failures,
+	})
+}
+
+func (v *validator) validateValue(value reflect.Value, path string, failures *[]FieldError) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			v.validateValue(value.Elem(), path, failures)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			v.validateValue(value.Index(i), path+"["+strconv.Itoa(i)+"]", failures)
+		}
+
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			v.validateValue(iter.Value(), path+"["+formatScalar(iter.Key())+"]", failures)
+		}
+
+	case reflect.Struct:
+		for _, rules := range v.structs[value.Type()] {
+			field := value.Field(rules.index)
+
+			fieldPath := path
+			if rules.name != "" {
+				fieldPath = joinPath(path, rules.name)
+			}
+
+			rules.check(field, fieldPath, failures)
+			v.validateValue(field, fieldPath, failures)
+		}
+	}
+}
+
+// check the rules against the field value.
+func (r *fieldRules) check(field reflect.Value, path string, failures *[]FieldError) {
+	fail := func(rule, message string) {
+		*failures = append(*failures, FieldError{Path: path, Rule: rule, Message: message})
+	}
+
+	if r.required && field.IsZero() {
+		fail(ruleRequired, "is required")
+		return
+	}
+
+	// Other rules apply to the value pointed to, and are skipped for nil pointers, which are absent optional fields
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return
+		}
+		field = field.Elem()
+	}
+
+	if r.min != nil || r.max != nil {
+		size, unit := measure(field)
+		if r.min != nil && size < *r.min {
+			fail(ruleMin, "must be at least "+formatFloat(*r.min)+unit)
+		}
+		if r.max != nil && size > *r.max {
+			fail(ruleMax, "must be at most "+formatFloat(*r.max)+unit)
+		}
+	}
+
+	if r.pattern != nil && !r.pattern.MatchString(field.String()) {
+		fail(rulePattern, "must match pattern "+strconv.Quote(r.pattern.String()))
+	}
+
+	if r.enumSet != nil && !r.enumSet[formatScalar(field)] {
+		values := make([]string, 0, len(r.enumValues))
+		for _, enumValue := range r.enumValues {
+			values = append(values, formatScalar(reflect.ValueOf(enumValue)))
+		}
+		fail(ruleEnum, "must be one of: "+strings.Join(values, ", "))
+	}
+}
+
+// measure returns the size compared by min and max, and its unit for messages.
+func measure(value reflect.Value) (float64, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), ""
+	default:
+		return value.Float(), ""
+	}
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func hasLength(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+
+	return false
+}
+
+// parseEnumValue parses an enum value into the JSON type of the field.
+func parseEnumValue(t reflect.Type, raw string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.ParseUint(raw, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	default:
+		return nil, ErrValidationRuleInvalid
+	}
+}
+
+// formatScalar formats a scalar value, so values of different types can be compared.
+func formatScalar(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return formatFloat(value.Float())
+	default:
+		return value.String()
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,212 @@
+package hoist_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/matryer/is"
+)
+
+type ValidatedAddress struct {
+	Street string  `json:"street" hoist:"required"`
+	Zip    *string `json:"zip" hoist:"pattern=^[0-9]{5}(-[0-9]{4})?$"`
+}
+
+type ValidatedParams struct {
+	Name      string                      `json:"name" hoist:"required,min=1,max=8"`
+	Age       *int                        `json:"age" hoist:"min=0,max=150"`
+	Color     *string                     `json:"color" hoist:"enum=red|green"`
+	Level     *int                        `json:"level" hoist:"enum=1|2|3"`
+	Tags      []string                    `json:"tags" hoist:"max=2"`
+	Address   *ValidatedAddress           `json:"address"`
+	Addresses []ValidatedAddress          `json:"addresses"`
+	ByName    map[string]ValidatedAddress `json:"byName"`
+}
+
+func TestValidation(t *testing.T) {
+	newService := func() *hoist.Service {
+		s := hoist.NewService("myService")
+		s.RegisterAs("myFunc", func(params *ValidatedParams) (string, error) {
+			return params.Name, nil
+		})
+		return s
+	}
+
+	t.Run("valid params", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newService()
+		is.Equal(len(s.Errors()), 0)
+
+		data, err := s.Call("myFunc", []byte(`{
+			"name": "abc",
+			"age": 30,
+			"color": "red",
+			"level": 2,
+			"tags": ["a"],
+			"address": {"street": "Main", "zip": "12345-6789"},
+			"addresses": [{"street": "Second"}]
+		}`))
+		is.NoErr(err)
+		is.Equal(data, "abc")
+	})
+
+	t.Run("invalid params list every failing field", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newService()
+		_, err := s.Call("myFunc", []byte(`{
+			"name": "much too long",
+			"age": -1,
+			"color": "blue",
+			"level": 4,
+			"tags": ["a", "b", "c"],
+			"address": {"zip": "1"},
+			"addresses": [{"street": "Second"}, {"street": ""}],
+			"byName": {"home": {}}
+		}`))
+		is.True(errors.Is(err, hoist.ErrFunctionCallFailed))
+		is.True(errors.Is(err, hoist.ErrParamsInvalid))
+
+		fields := erk.GetParams(errors.Unwrap(err))["fields"]
+		is.Equal(fields, []hoist.FieldError{
+			{Path: "name", Rule: "max", Message: "must be at most 8 characters"},
+			{Path: "age", Rule: "min", Message: "must be at least 0"},
+			{Path: "color", Rule: "enum", Message: "must be one of: red, green"},
+			{Path: "level", Rule: "enum", Message: "must be one of: 1, 2, 3"},
+			{Path: "tags", Rule: "max", Message: "must be at most 2 items"},
+			{Path: "address.street", Rule: "required", Message: "is required"},
+			{Path: "address.zip", Rule: "pattern", Message: `must match pattern "^[0-9]{5}(-[0-9]{4})?$"`},
+			{Path: "addresses[1].street", Rule: "required", Message: "is required"},
+			{Path: "byName[home].street", Rule: "required", Message: "is required"},
+		})
+	})
+
+	t.Run("required field", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newService()
+		_, err := s.Call("myFunc", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrParamsInvalid))
+		is.Equal(errors.Unwrap(err).Error(), "params are invalid: name is required")
+	})
+
+	t.Run("rules apply to zero values", func(t *testing.T) {
+		is := is.New(t)
+
+		s := hoist.NewService("myService")
+		s.RegisterAs("myFunc", func(params *struct {
+			Count int    `json:"count" hoist:"min=1,max=10"`
+			Color string `json:"color" hoist:"enum=red|green"`
+			Name  string `json:"name" hoist:"min=1"`
+			Limit *int   `json:"limit" hoist:"min=1"`
+		}) error {
+			return nil
+		})
+		is.Equal(len(s.Errors()), 0)
+
+		_, err := s.Call("myFunc", []byte(`{}`))
+		is.True(errors.Is(err, hoist.ErrParamsInvalid))
+		is.Equal(erk.GetParams(errors.Unwrap(err))["fields"], []hoist.FieldError{
+			{Path: "count", Rule: "min", Message: "must be at least 1"},
+			{Path: "color", Rule: "enum", Message: "must be one of: red, green"},
+			{Path: "name", Rule: "min", Message: "must be at least 1 characters"},
+		})
+	})
+
+	t.Run("invalid rules are reported when registering", func(t *testing.T) {
+		table := []struct {
+			Name          string
+			Fn            interface{}
+			ExpectedError error
+		}{
+			{
+				Name: "unknown rule",
+				Fn: func(struct {
+					A string `hoist:"unknown"`
+				}) error {
+					return nil
+				},
+				ExpectedError: hoist.ErrValidationTagInvalid,
+			},
+			{
+				Name: "min is not a number",
+				Fn: func(struct {
+					A string `hoist:"min=abc"`
+				}) error {
+					return nil
+				},
+				ExpectedError: hoist.ErrValidationTagInvalid,
+			},
+			{
+				Name: "invalid pattern",
+				Fn: func(struct {
+					A string `hoist:"pattern=(("`
+				}) error {
+					return nil
+				},
+				ExpectedError: hoist.ErrValidationTagInvalid,
+			},
+			{
+				Name: "pattern on a number",
+				Fn: func(struct {
+					A int `hoist:"pattern=^1$"`
+				}) error {
+					return nil
+				},
+				ExpectedError: hoist.ErrValidationRuleInvalid,
+			},
+			{
+				Name: "min on a struct",
+				Fn: func(struct {
+					A struct{} `hoist:"min=1"`
+				}) error {
+					return nil
+				},
+				ExpectedError: hoist.ErrValidationRuleInvalid,
+			},
+			{
+				Name: "enum value of the wrong type",
+				Fn: func(struct {
+					A int `hoist:"enum=a|b"`
+				}) error {
+					return nil
+				},
+				ExpectedError: hoist.ErrValidationRuleInvalid,
+			},
+		}
+
+		for _, entry := range table {
+			t.Run(entry.Name, func(t *testing.T) {
+				is := is.New(t)
+
+				s := hoist.NewService("myService")
+				s.RegisterAs("myFunc", entry.Fn)
+
+				is.Equal(len(s.Errors()), 1)
+				is.True(errors.Is(s.Errors()[0], hoist.ErrInvalidFunction))
+				is.True(errors.Is(s.Errors()[0], entry.ExpectedError))
+			})
+		}
+	})
+
+	t.Run("rules are exported in the schema", func(t *testing.T) {
+		is := is.New(t)
+
+		s := newService()
+		definition := s.Export().Definitions["ValidatedParams"]
+
+		one, eight, zero, oneFifty, two := 1, 8, 0.0, 150.0, 2
+		is.Equal(definition.Properties["name"].MinLength, &one)
+		is.Equal(definition.Properties["name"].MaxLength, &eight)
+		is.Equal(definition.Properties["age"].Minimum, &zero)
+		is.Equal(definition.Properties["age"].Maximum, &oneFifty)
+		is.Equal(definition.Properties["color"].Enum, []interface{}{"red", "green", nil})
+		is.Equal(definition.Properties["level"].Enum, []interface{}{int64(1), int64(2), int64(3), nil})
+		is.Equal(definition.Properties["tags"].MaxItems, &two)
+		is.Equal(s.Export().Definitions["ValidatedAddress"].Properties["zip"].Pattern, "^[0-9]{5}(-[0-9]{4})?$")
+		is.Equal(s.Export().Definitions["ValidatedAddress"].Required, []string{"street"})
+	})
+}
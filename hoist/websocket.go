@@ -0,0 +1,173 @@
+package hoist
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hoistup/hoist-go/websocket"
+	"github.com/hoistup/hoist-go/wire"
+)
+
+// DefaultWebSocketPingInterval is how often WebSocket connections are pinged, unless WithWebSocketPingInterval is provided.
+const DefaultWebSocketPingInterval = 30 * time.Second
+
+// webSocketHandler serves calls over a WebSocket connection, for callers such as browsers.
+//
+// Each message contains one wire frame, and is answered with a message of the same type containing the response,
+// in the encoding of the request, or a binary message if the response is not valid UTF-8.
+// Calls run concurrently, up to the limit set by WithMaxConnCalls, and responses are written
+// as calls finish, so callers match them to calls by request ID.
+// Unlike ServeConn, a message that cannot be decoded is answered with an error without a request ID,
+// and the connection stays open.
+//
+// The connection is pinged at the interval set by WithWebSocketPingInterval, and closed if the caller
+// sends nothing, not even a pong, for two intervals.
+func (s *Service) webSocketHandler(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: s.options.checkWebSocketOrigin}
+	conn, err := upgrader.Upgrade(w, r)
+	if err != nil {
+		return // The upgrader responded with the error
+	}
+
+	if s.options.maxBodyBytes > 0 {
+		conn.SetMaxMessageBytes(s.options.maxBodyBytes)
+	}
+	conn.SetWriteTimeout(s.options.writeTimeout)
+	if s.options.webSocketPingInterval > 0 {
+		conn.SetReadTimeout(2 * s.options.webSocketPingInterval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &webSocketConn{
+		service: s,
+		conn:    conn,
+		ctx:     ctx,
+		cancel:  cancel,
+		slots:   make(chan struct{}, s.options.maxConnCalls),
+		done:    make(chan struct{}),
+	}
+
+	if !s.trackConn(c, true) {
+		c.close()
+		return
+	}
+	defer s.trackConn(c, false)
+
+	c.serve()
+}
+
+// webSocketConn is a WebSocket connection served by webSocketHandler.
+type webSocketConn struct {
+	service *Service
+	conn    *websocket.Conn
+
+	// ctx is canceled when the connection fails, which abandons its calls
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	calls sync.WaitGroup
+	slots chan struct{}
+
+	stopping int32         // set to 1 when the service is shutting down
+	done     chan struct{} // closed when the connection stops reading
+}
+
+func (c *webSocketConn) serve() {
+	go c.keepAlive()
+
+	for {
+		messageType, message, err := c.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		decoded, err := wire.NewDecoder(bytes.NewReader(message), c.service.options.decoderOptions...).Decode()
+		if err != nil {
+			c.service.countDecodeError()
+			c.write(messageType, func(w io.Writer) { c.service.writeEventError(w, nil, nil, 0, err) })
+			continue
+		}
+
+		c.slots <- struct{}{}
+		c.calls.Add(1)
+		go c.call(messageType, decoded)
+	}
+	close(c.done)
+
+	// Calls can only finish if the service stopped reading, since the caller is gone otherwise
+	if atomic.LoadInt32(&c.stopping) == 0 {
+		c.cancel()
+	}
+
+	c.calls.Wait()
+	c.cancel()
+	c.conn.CloseWithCode(websocket.CloseGoingAway, "")
+}
+
+func (c *webSocketConn) call(messageType int, decoded *wire.DecodeResult) {
+	defer func() {
+		<-c.slots
+		c.calls.Done()
+	}()
+
+	c.service.serveFrame(c.ctx, decoded, func(writeFrame func(w io.Writer), flush bool) error {
+		return c.write(messageType, writeFrame)
+	})
+}
+
+// write a frame in a message of the type.
+// If the connection cannot be written, it is closed, and calls in flight are canceled.
+func (c *webSocketConn) write(messageType int, writeFrame func(w io.Writer)) error {
+	var buf bytes.Buffer
+	writeFrame(&buf)
+
+	// Frames that are not text, such as those in the MessagePack encoding, are sent in binary messages
+	if messageType == websocket.TextMessage && !utf8.Valid(buf.Bytes()) {
+		messageType = websocket.BinaryMessage
+	}
+
+	err := c.conn.WriteMessage(messageType, buf.Bytes())
+	if err != nil {
+		c.close()
+	}
+
+	return err
+}
+
+// keepAlive pings the caller, until the connection stops reading.
+func (c *webSocketConn) keepAlive() {
+	interval := c.service.options.webSocketPingInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.conn.Ping(nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *webSocketConn) stopReading() {
+	atomic.StoreInt32(&c.stopping, 1)
+	c.conn.SetReadDeadline(time.Now())
+}
+
+func (c *webSocketConn) close() {
+	c.cancel()
+	c.conn.CloseWithCode(websocket.CloseGoingAway, "")
+}
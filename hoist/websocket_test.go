@@ -0,0 +1,220 @@
+package hoist_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hoistup/hoist-go/hoist"
+	"github.com/hoistup/hoist-go/strand"
+	"github.com/hoistup/hoist-go/websocket"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+func TestWebSocket(t *testing.T) {
+	serve := func(opts ...hoist.Option) (url string, release chan struct{}, stop func()) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		release = make(chan struct{})
+		s := hoist.NewService("abc", append(opts, hoist.WithListener(listener))...)
+		s.RegisterAs("wait", func(ctx *TestContext, params *TestParams) (*TestParams, error) {
+			if params.Message == "slow" {
+				<-release
+			}
+			return params, nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error)
+		go func() { serveErr <- s.ServeContext(ctx) }()
+
+		return "ws://" + listener.Addr().String() + "/_/v1/ws", release, func() {
+			cancel()
+			<-serveErr
+		}
+	}
+
+	readResponse := func(t *testing.T, conn *websocket.Conn) (int, *strand.ResponseDetails, *TestParams) {
+		t.Helper()
+
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := wire.NewDecoder(bytes.NewReader(message)).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var details strand.ResponseDetails
+		var params *TestParams
+		if err := decoded.Encoding.UnmarshalDetails(decoded.RawDetails, &details); err != nil {
+			t.Fatal(err)
+		}
+		if !details.IsError {
+			if err := decoded.Encoding.UnmarshalParams(decoded.RawParams, &params); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return messageType, &details, params
+	}
+
+	request := func(encoding wire.Encoding, requestID, message string) []byte {
+		var buf bytes.Buffer
+		details := &strand.RequestDetails{RequestID: requestID, FunctionName: "wait"}
+		if err := wire.NewEncoder(&buf, wire.WithEncoding(encoding)).Encode(details, &TestParams{Message: message}); err != nil {
+			panic(err)
+		}
+		return buf.Bytes()
+	}
+
+	t.Run("responses are written as calls finish", func(t *testing.T) {
+		is := is.New(t)
+
+		url, release, stop := serve()
+		defer stop()
+
+		conn, err := websocket.Dial(context.Background(), url, nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, request(wire.EncodingJSON, "first", "slow")))
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, request(wire.EncodingJSON, "second", "fast")))
+
+		messageType, details, params := readResponse(t, conn)
+		is.Equal(messageType, websocket.TextMessage)
+		is.Equal(details, &strand.ResponseDetails{RequestID: "second"})
+		is.Equal(params, &TestParams{Message: "fast"})
+
+		close(release)
+		_, details, params = readResponse(t, conn)
+		is.Equal(details, &strand.ResponseDetails{RequestID: "first"})
+		is.Equal(params, &TestParams{Message: "slow"})
+	})
+
+	t.Run("with MessagePack request", func(t *testing.T) {
+		is := is.New(t)
+
+		url, _, stop := serve()
+		defer stop()
+
+		conn, err := websocket.Dial(context.Background(), url, nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		is.NoErr(conn.WriteMessage(websocket.BinaryMessage, request(wire.EncodingMsgPack, reqID, "hi")))
+
+		messageType, details, params := readResponse(t, conn)
+		is.Equal(messageType, websocket.BinaryMessage)
+		is.Equal(details, &strand.ResponseDetails{RequestID: reqID})
+		is.Equal(params, &TestParams{Message: "hi"})
+	})
+
+	t.Run("with invalid message", func(t *testing.T) {
+		is := is.New(t)
+
+		url, _, stop := serve()
+		defer stop()
+
+		conn, err := websocket.Dial(context.Background(), url, nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, []byte("x:")))
+		_, details, _ := readResponse(t, conn)
+		is.Equal(details, &errRespDetailsInternal)
+
+		// The connection can still be used
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, request(wire.EncodingJSON, reqID, "hi")))
+		_, details, _ = readResponse(t, conn)
+		is.Equal(details, &strand.ResponseDetails{RequestID: reqID})
+	})
+
+	t.Run("with message over the frame limits", func(t *testing.T) {
+		is := is.New(t)
+
+		url, _, stop := serve(hoist.WithDecoderOptions(wire.WithMaxParamsBytes(8)))
+		defer stop()
+
+		conn, err := websocket.Dial(context.Background(), url, nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		// The caller sent the frame, so the error is not internal
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, request(wire.EncodingJSON, reqID, "too long")))
+		_, details, _ := readResponse(t, conn)
+		is.Equal(details, &strand.ResponseDetails{IsError: true})
+	})
+
+	t.Run("keepalive pings keep the connection open", func(t *testing.T) {
+		is := is.New(t)
+
+		url, _, stop := serve(hoist.WithWebSocketPingInterval(100 * time.Millisecond))
+		defer stop()
+
+		conn, err := websocket.Dial(context.Background(), url, nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		pings := make(chan struct{}, 10)
+		conn.SetPingHandler(func([]byte) { pings <- struct{}{} })
+
+		// Reading answers the pings
+		messages := make(chan []byte)
+		go func() {
+			for {
+				_, message, err := conn.ReadMessage()
+				if err != nil {
+					close(messages)
+					return
+				}
+				messages <- message
+			}
+		}()
+
+		// After three pings, the connection has outlived the read timeout of two intervals
+		for i := 0; i < 3; i++ {
+			select {
+			case <-pings:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no ping received")
+			}
+		}
+
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, request(wire.EncodingJSON, reqID, "hi")))
+		is.True(<-messages != nil)
+	})
+
+	t.Run("connections from other origins are forbidden", func(t *testing.T) {
+		is := is.New(t)
+
+		url, _, stop := serve()
+		defer stop()
+
+		origin := http.Header{"Origin": {"https://app.example.com"}}
+		_, err := websocket.Dial(context.Background(), url, origin)
+		is.True(errors.Is(err, websocket.ErrHandshake))
+	})
+
+	t.Run("WithWebSocketOrigins", func(t *testing.T) {
+		is := is.New(t)
+
+		url, _, stop := serve(hoist.WithWebSocketOrigins("https://app.example.com"))
+		defer stop()
+
+		origin := http.Header{"Origin": {"https://app.example.com"}}
+		conn, err := websocket.Dial(context.Background(), url, origin)
+		is.NoErr(err)
+		conn.Close()
+	})
+}
@@ -0,0 +1,36 @@
+// Package chunked reads payloads whose length is declared by a peer, without trusting that length.
+package chunked
+
+import (
+	"bytes"
+	"io"
+)
+
+// Size is the most memory allocated for a payload before its bytes are received,
+// so a peer cannot make the reader allocate the declared length by only sending a header.
+const Size = 64 << 10
+
+// ReadN reads exactly n bytes, only allocating memory as the bytes are received.
+// If fewer bytes are available, it returns io.ErrUnexpectedEOF.
+func ReadN(r io.Reader, n int64) ([]byte, error) {
+	if n <= Size {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		return buf, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(Size)
+	if _, err := io.CopyN(&buf, r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
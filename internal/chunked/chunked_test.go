@@ -0,0 +1,44 @@
+package chunked_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hoistup/hoist-go/internal/chunked"
+	"github.com/matryer/is"
+)
+
+func TestReadN(t *testing.T) {
+	large := strings.Repeat("a", chunked.Size+1)
+
+	table := []struct {
+		Name          string
+		Data          string
+		N             int64
+		ExpectedData  string
+		ExpectedError error
+	}{
+		{Name: "small payload", Data: "abcd", N: 3, ExpectedData: "abc"},
+		{Name: "empty payload", Data: "", N: 0, ExpectedData: ""},
+		{Name: "large payload", Data: large + "b", N: int64(len(large)), ExpectedData: large},
+		{Name: "short small payload", Data: "ab", N: 3, ExpectedError: io.ErrUnexpectedEOF},
+		{Name: "short large payload", Data: "ab", N: 1 << 30, ExpectedError: io.ErrUnexpectedEOF},
+		{Name: "missing small payload", Data: "", N: 3, ExpectedError: io.EOF},
+		{Name: "missing large payload", Data: "", N: 1 << 30, ExpectedError: io.ErrUnexpectedEOF},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			data, err := chunked.ReadN(bytes.NewReader([]byte(entry.Data)), entry.N)
+			is.Equal(err, entry.ExpectedError)
+			if entry.ExpectedError == nil {
+				is.Equal(string(data), entry.ExpectedData)
+			}
+		})
+	}
+}
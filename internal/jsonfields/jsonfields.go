@@ -0,0 +1,204 @@
+// Package jsonfields lists the fields of structs as encoding/json encodes them,
+// so every package that follows `json:"..."` struct tags agrees on which field has each name.
+package jsonfields
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Field is a struct field, which may be promoted from an embedded struct.
+type Field struct {
+	// Name is the encoded name, from the json tag or the Go field name.
+	Name string
+
+	// Index is the index sequence of the field, for reflect.Value.FieldByIndex.
+	Index []int
+
+	Type      reflect.Type
+	Tagged    bool // Named by a json tag
+	OmitEmpty bool
+	Quoted    bool // Has the ",string" option
+
+	// StructField is the field as declared, for reading its other tags.
+	StructField reflect.StructField
+}
+
+// fieldCache maps struct types to their fields.
+var fieldCache sync.Map
+
+// Of returns the encoded fields of the struct type, following the encoding/json rules for names
+// and embedded structs: fields of embedded structs are promoted, unless the embedded struct is named by a tag,
+// shallower fields win, and fields at the same depth only win if they are the only one named by a tag.
+// Fields are in the order encoding/json encodes them.
+func Of(t reflect.Type) []Field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]Field)
+	}
+
+	fields, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return fields.([]Field)
+}
+
+func typeFields(t reflect.Type) []Field {
+	var candidates []Field
+	collectFields(t, nil, map[reflect.Type]bool{}, &candidates)
+
+	byName := make(map[string][]Field)
+	var names []string
+	for _, f := range candidates {
+		if _, ok := byName[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+		byName[f.Name] = append(byName[f.Name], f)
+	}
+
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		if f, ok := dominantField(byName[name]); ok {
+			fields = append(fields, f)
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool, fields *[]Field) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if comma := strings.Index(tag, ","); comma != -1 {
+			name, opts = tag[:comma], tag[comma:]
+		}
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		// Fields of embedded structs are promoted, unless the embedded struct is named by a tag
+		if sf.Anonymous && name == "" {
+			embeddedType := sf.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				if sf.PkgPath != "" { // Unexported pointers cannot be allocated
+					continue
+				}
+				embeddedType = embeddedType.Elem()
+			}
+
+			if embeddedType.Kind() == reflect.Struct {
+				collectFields(embeddedType, fieldIndex, visited, fields)
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" { // Unexported
+			continue
+		}
+
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+
+		*fields = append(*fields, Field{
+			Name:        name,
+			Index:       fieldIndex,
+			Type:        sf.Type,
+			Tagged:      tagged,
+			OmitEmpty:   strings.Contains(opts+",", ",omitempty,"),
+			Quoted:      strings.Contains(opts+",", ",string,") && isQuotable(sf.Type),
+			StructField: sf,
+		})
+	}
+}
+
+// isQuotable reports whether encoding/json applies the ",string" option to the type.
+func isQuotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func dominantField(fields []Field) (Field, bool) {
+	depth := len(fields[0].Index)
+	for _, f := range fields {
+		if len(f.Index) < depth {
+			depth = len(f.Index)
+		}
+	}
+
+	var shallowest []Field
+	for _, f := range fields {
+		if len(f.Index) == depth {
+			shallowest = append(shallowest, f)
+		}
+	}
+
+	if len(shallowest) == 1 {
+		return shallowest[0], true
+	}
+
+	var tagged []Field
+	for _, f := range shallowest {
+		if f.Tagged {
+			tagged = append(tagged, f)
+		}
+	}
+
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+
+	return Field{}, false
+}
+
+// Find the field by name, preferring an exact match, but also matching case insensitively, like encoding/json.
+func Find(fields []Field, name string) (Field, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+
+	return Field{}, false
+}
@@ -0,0 +1,98 @@
+package jsonfields_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hoistup/hoist-go/internal/jsonfields"
+	"github.com/matryer/is"
+)
+
+type Inner struct {
+	A string
+	B string `json:"b"`
+	C string
+}
+
+type Other struct {
+	A string `json:"A"`
+	C string
+}
+
+type unexported struct {
+	D string
+}
+
+type Fields struct {
+	Inner
+	Other
+	*unexported
+	B       string
+	Named   Inner  `json:"named"`
+	Skipped string `json:"-"`
+	Count   int    `json:"count,omitempty,string"`
+	List    []int  `json:",string"`
+	private string
+}
+
+func TestOf(t *testing.T) {
+	is := is.New(t)
+
+	type expected struct {
+		Name      string
+		Index     []int
+		OmitEmpty bool
+		Quoted    bool
+	}
+
+	var actual []expected
+	for _, f := range jsonfields.Of(reflect.TypeOf(Fields{})) {
+		actual = append(actual, expected{Name: f.Name, Index: f.Index, OmitEmpty: f.OmitEmpty, Quoted: f.Quoted})
+	}
+
+	is.Equal(actual, []expected{
+		{Name: "b", Index: []int{0, 1}},  // Distinct from B
+		{Name: "A", Index: []int{1, 0}},  // Tagged wins at the same depth
+		{Name: "B", Index: []int{3}},     // Shallower wins
+		{Name: "named", Index: []int{4}}, // Named embedded structs are not promoted
+		{Name: "count", Index: []int{6}, OmitEmpty: true, Quoted: true},
+		{Name: "List", Index: []int{7}}, // Slices are not quoted
+	})
+
+	// The fields agree with encoding/json, which drops the conflicting C
+	value := Fields{Inner: Inner{A: "inner", B: "b", C: "c"}, Other: Other{A: "other", C: "c"}, B: "outer", Count: 1}
+	data, err := json.Marshal(value)
+	is.NoErr(err)
+	is.Equal(string(data), `{"b":"b","A":"other","B":"outer","named":{"A":"","b":"","C":""},"count":"1","List":null}`)
+}
+
+func TestFind(t *testing.T) {
+	fields := jsonfields.Of(reflect.TypeOf(Fields{}))
+
+	table := []struct {
+		Name          string
+		Key           string
+		ExpectedIndex []int
+		ExpectedFound bool
+	}{
+		{Name: "exact name", Key: "B", ExpectedIndex: []int{3}, ExpectedFound: true},
+		{Name: "exact name differing in case from another", Key: "b", ExpectedIndex: []int{0, 1}, ExpectedFound: true},
+		{Name: "case insensitive name", Key: "COUNT", ExpectedIndex: []int{6}, ExpectedFound: true},
+		{Name: "conflicting name", Key: "C"},
+		{Name: "skipped name", Key: "Skipped"},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			f, ok := jsonfields.Find(fields, entry.Key)
+			is.Equal(ok, entry.ExpectedFound)
+			if ok {
+				is.Equal(f.Index, entry.ExpectedIndex)
+			}
+		})
+	}
+}
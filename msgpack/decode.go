@@ -0,0 +1,817 @@
+package msgpack
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/internal/jsonfields"
+)
+
+// ErrUnmarshaler is returned when a type fails to decode itself with its unmarshal method.
+var ErrUnmarshaler = erk.New(ErkUnmarshalType{}, "could not decode '{{.type}}' with its unmarshal method: {{.err}}")
+
+// Unmarshal decodes the MessagePack data into the value pointed to by v.
+// Data remaining after the first value is an error.
+func Unmarshal(data []byte, v interface{}) error {
+	d := NewDecoder(data)
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+
+	if d.Buffered() > 0 {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// Decoder is created with NewDecoder, and decodes consecutive values from the data with each Decode() call.
+type Decoder struct {
+	data   []byte
+	offset int
+	depth  int
+
+	disallowUnknownFields bool
+}
+
+// NewDecoder creates a decoder, which decodes from the provided data with each Decode() call.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// DisallowUnknownFields causes Decode to return an ErrUnknownField error
+// when a map decoded into a struct has a key that does not match a field.
+func (d *Decoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// Buffered returns the number of bytes that have not been decoded.
+func (d *Decoder) Buffered() int {
+	return len(d.data) - d.offset
+}
+
+// Decode the next value into the value pointed to by v.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return erk.WithParam(ErrInvalidTarget, "type", fmt.Sprintf("%T", v))
+	}
+
+	return d.decodeValue(rv.Elem())
+}
+
+// tokenKind is the kind of value a format byte starts.
+type tokenKind int
+
+const (
+	tokenNil tokenKind = iota
+	tokenBool
+	tokenInt
+	tokenUint
+	tokenFloat
+	tokenStr
+	tokenBin
+	tokenArray
+	tokenMap
+	tokenExt
+)
+
+var tokenNames = [...]string{"nil", "bool", "integer", "integer", "float", "string", "binary", "array", "map", "extension"}
+
+// token is the header of the next value.
+// Strings, binary data, and extensions include their bytes, while arrays and maps only include their length.
+type token struct {
+	kind    tokenKind
+	b       bool
+	i       int64
+	u       uint64
+	f       float64
+	bytes   []byte
+	length  int
+	extType int8
+}
+
+// next reads the header of the next value.
+func (d *Decoder) next() (token, error) {
+	start := d.offset
+	format, err := d.readByte()
+	if err != nil {
+		return token{}, err
+	}
+
+	switch {
+	case format <= 0x7f:
+		return token{kind: tokenUint, u: uint64(format)}, nil
+	case format >= formatNegInt:
+		return token{kind: tokenInt, i: int64(int8(format))}, nil
+	case format&0xf0 == formatFixMap:
+		return d.containerToken(tokenMap, int(format&0x0f))
+	case format&0xf0 == formatFixArray:
+		return d.containerToken(tokenArray, int(format&0x0f))
+	case format&0xe0 == formatFixStr:
+		return d.bytesToken(tokenStr, int(format&0x1f))
+	}
+
+	switch format {
+	case formatNil:
+		return token{kind: tokenNil}, nil
+	case formatFalse, formatTrue:
+		return token{kind: tokenBool, b: format == formatTrue}, nil
+
+	case formatUint8, formatUint16, formatUint32, formatUint64:
+		u, err := d.readUint(1 << (format - formatUint8))
+		return token{kind: tokenUint, u: u}, err
+
+	case formatInt8, formatInt16, formatInt32, formatInt64:
+		size := 1 << (format - formatInt8)
+		u, err := d.readUint(size)
+		shift := uint(64 - 8*size)
+		return token{kind: tokenInt, i: int64(u<<shift) >> shift}, err // Sign extend
+
+	case formatFloat32:
+		u, err := d.readUint(4)
+		return token{kind: tokenFloat, f: float64(math.Float32frombits(uint32(u)))}, err
+	case formatFloat64:
+		u, err := d.readUint(8)
+		return token{kind: tokenFloat, f: math.Float64frombits(u)}, err
+
+	case formatStr8, formatStr16, formatStr32:
+		n, err := d.readUint(1 << (format - formatStr8))
+		if err != nil {
+			return token{}, err
+		}
+		return d.bytesToken(tokenStr, d.length(n))
+
+	case formatBin8, formatBin16, formatBin32:
+		n, err := d.readUint(1 << (format - formatBin8))
+		if err != nil {
+			return token{}, err
+		}
+		return d.bytesToken(tokenBin, d.length(n))
+
+	case formatArray16, formatArray32:
+		n, err := d.readUint(2 << (format - formatArray16))
+		if err != nil {
+			return token{}, err
+		}
+		return d.containerToken(tokenArray, d.length(n))
+
+	case formatMap16, formatMap32:
+		n, err := d.readUint(2 << (format - formatMap16))
+		if err != nil {
+			return token{}, err
+		}
+		return d.containerToken(tokenMap, d.length(n))
+
+	case formatExt8, formatExt16, formatExt32:
+		n, err := d.readUint(1 << (format - formatExt8))
+		if err != nil {
+			return token{}, err
+		}
+		return d.extToken(d.length(n))
+	}
+
+	if format >= formatFixExt1 && format <= formatFixExt16 {
+		return d.extToken(1 << (format - formatFixExt1))
+	}
+
+	return token{}, erk.WithParams(ErrInvalidFormat, erk.Params{"format": strconv.FormatUint(uint64(format), 16), "offset": start})
+}
+
+// length converts a length read from the data, so lengths too large for the data are errors.
+func (d *Decoder) length(n uint64) int {
+	if n > uint64(d.Buffered()) {
+		return d.Buffered() + 1
+	}
+
+	return int(n)
+}
+
+func (d *Decoder) bytesToken(kind tokenKind, n int) (token, error) {
+	b, err := d.readBytes(n)
+	return token{kind: kind, bytes: b}, err
+}
+
+func (d *Decoder) containerToken(kind tokenKind, n int) (token, error) {
+	// Every element is at least one byte, so larger lengths cannot be valid.
+	// This prevents allocating memory for lengths the data cannot fill.
+	if n > d.Buffered() {
+		return token{}, ErrUnexpectedEnd
+	}
+
+	return token{kind: kind, length: n}, nil
+}
+
+func (d *Decoder) extToken(n int) (token, error) {
+	extType, err := d.readByte()
+	if err != nil {
+		return token{}, err
+	}
+
+	b, err := d.readBytes(n)
+	return token{kind: tokenExt, extType: int8(extType), bytes: b}, err
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	if d.offset >= len(d.data) {
+		return 0, ErrUnexpectedEnd
+	}
+
+	b := d.data[d.offset]
+	d.offset++
+	return b, nil
+}
+
+func (d *Decoder) readBytes(n int) ([]byte, error) {
+	if n > d.Buffered() {
+		return nil, ErrUnexpectedEnd
+	}
+
+	b := d.data[d.offset : d.offset+n : d.offset+n]
+	d.offset += n
+	return b, nil
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	b, err := d.readBytes(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *Decoder) decodeValue(v reflect.Value) error {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return erk.WithParam(ErrTooDeep, "max", maxDepth)
+	}
+
+	if v.Type() == rawMessageType {
+		return d.decodeRaw(v)
+	}
+
+	// Nil sets pointers, maps, slices and interfaces to nil, and leaves other values unchanged
+	if d.offset < len(d.data) && d.data[d.offset] == formatNil {
+		d.offset++
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return d.decodeValue(v.Elem())
+	}
+
+	// Types with custom decodings
+	t := v.Type()
+	switch {
+	case t == timeType:
+		return d.decodeTime(v)
+	case t == jsonNumberType:
+		return d.decodeNumber(v)
+	case reflect.PtrTo(t).Implements(jsonUnmarshalerType):
+		return d.decodeJSONUnmarshaler(v.Addr())
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return d.decodeTextUnmarshaler(v.Addr())
+	}
+
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	typeErr := erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": t.String()})
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			generic, err := d.interfaceFrom(tok, false)
+			if err != nil {
+				return err
+			}
+
+			v.Set(reflect.ValueOf(generic))
+			return nil
+		}
+
+		// Decode into the pointer held by a non-empty interface, like encoding/json
+		if elem := v.Elem(); elem.Kind() == reflect.Ptr && !elem.IsNil() {
+			return d.decodeInto(tok, elem.Elem())
+		}
+
+		return typeErr
+	}
+
+	return d.decodeInto(tok, v)
+}
+
+// decodeInto the value, after its token has been read.
+func (d *Decoder) decodeInto(tok token, v reflect.Value) error {
+	typeErr := erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": v.Type().String()})
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if tok.kind != tokenBool {
+			return typeErr
+		}
+		v.SetBool(tok.b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := tok.int64()
+		if !ok || v.OverflowInt(i) {
+			return typeErr
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, ok := tok.uint64()
+		if !ok || v.OverflowUint(u) {
+			return typeErr
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := tok.float64()
+		if !ok || v.OverflowFloat(f) {
+			return typeErr
+		}
+		v.SetFloat(f)
+
+	case reflect.String:
+		if tok.kind != tokenStr && tok.kind != tokenBin {
+			return typeErr
+		}
+		v.SetString(string(tok.bytes))
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (tok.kind == tokenBin || tok.kind == tokenStr) {
+			v.SetBytes(append([]byte{}, tok.bytes...))
+			return nil
+		}
+
+		if tok.kind != tokenArray {
+			return typeErr
+		}
+
+		slice := reflect.MakeSlice(v.Type(), tok.length, tok.length)
+		for i := 0; i < tok.length; i++ {
+			if err := d.decodeValue(slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Array:
+		if tok.kind != tokenArray {
+			return typeErr
+		}
+
+		for i := 0; i < tok.length; i++ {
+			if i >= v.Len() {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := d.decodeValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		for i := tok.length; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+
+	case reflect.Map:
+		if tok.kind != tokenMap {
+			return typeErr
+		}
+
+		return d.decodeMap(tok.length, v)
+
+	case reflect.Struct:
+		if tok.kind != tokenMap {
+			return typeErr
+		}
+
+		return d.decodeStruct(tok.length, v)
+
+	default:
+		return typeErr
+	}
+
+	return nil
+}
+
+func (d *Decoder) decodeMap(length int, v reflect.Value) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, length))
+	}
+
+	for i := 0; i < length; i++ {
+		key := reflect.New(t.Key()).Elem()
+		if err := d.decodeMapKey(key); err != nil {
+			return err
+		}
+
+		value := reflect.New(t.Elem()).Elem()
+		if err := d.decodeValue(value); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(key, value)
+	}
+
+	return nil
+}
+
+// decodeMapKey from a string, or an integer for integer keys, like encoding/json.
+func (d *Decoder) decodeMapKey(key reflect.Value) error {
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	typeErr := erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": key.Type().String()})
+
+	if tok.kind == tokenStr || tok.kind == tokenBin {
+		if reflect.PtrTo(key.Type()).Implements(textUnmarshalerType) {
+			if err := key.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(tok.bytes); err != nil {
+				return erk.WrapAs(erk.WithParam(ErrUnmarshaler, "type", key.Type().String()), err)
+			}
+			return nil
+		}
+
+		switch key.Kind() {
+		case reflect.String:
+			key.SetString(string(tok.bytes))
+			return nil
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(string(tok.bytes), 10, 64)
+			if err != nil || key.OverflowInt(i) {
+				return typeErr
+			}
+			key.SetInt(i)
+			return nil
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u, err := strconv.ParseUint(string(tok.bytes), 10, 64)
+			if err != nil || key.OverflowUint(u) {
+				return typeErr
+			}
+			key.SetUint(u)
+			return nil
+		}
+
+		return typeErr
+	}
+
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return d.decodeInto(tok, key)
+	}
+
+	return typeErr
+}
+
+func (d *Decoder) decodeStruct(length int, v reflect.Value) error {
+	fields := jsonfields.Of(v.Type())
+
+	for i := 0; i < length; i++ {
+		tok, err := d.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind != tokenStr && tok.kind != tokenBin {
+			return erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": "string"})
+		}
+
+		f, ok := jsonfields.Find(fields, string(tok.bytes))
+		if !ok {
+			if d.disallowUnknownFields {
+				return erk.WithParam(ErrUnknownField, "field", string(tok.bytes))
+			}
+
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.decodeValue(allocFieldByIndex(v, f.Index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allocFieldByIndex returns the field, allocating nil embedded structs.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// decodeRaw copies the next value into the RawMessage, including nil.
+func (d *Decoder) decodeRaw(v reflect.Value) error {
+	start := d.offset
+	if err := d.skip(); err != nil {
+		return err
+	}
+
+	v.SetBytes(append(RawMessage(nil), d.data[start:d.offset]...))
+	return nil
+}
+
+// skip the next value.
+func (d *Decoder) skip() error {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return erk.WithParam(ErrTooDeep, "max", maxDepth)
+	}
+
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	n := tok.length
+	if tok.kind == tokenMap {
+		n *= 2
+	}
+
+	for i := 0; i < n; i++ {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeInterface decodes the next value as the types produced by encoding/json.
+// If exact is true, integers are decoded as int64 or uint64 instead of float64.
+func (d *Decoder) decodeInterface(exact bool) (interface{}, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, erk.WithParam(ErrTooDeep, "max", maxDepth)
+	}
+
+	tok, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.interfaceFrom(tok, exact)
+}
+
+func (d *Decoder) interfaceFrom(tok token, exact bool) (interface{}, error) {
+	switch tok.kind {
+	case tokenNil:
+		return nil, nil
+	case tokenBool:
+		return tok.b, nil
+	case tokenInt:
+		if exact {
+			return tok.i, nil
+		}
+		return float64(tok.i), nil
+	case tokenUint:
+		if exact {
+			return tok.u, nil
+		}
+		return float64(tok.u), nil
+	case tokenFloat:
+		return tok.f, nil
+	case tokenStr:
+		return string(tok.bytes), nil
+	case tokenBin:
+		return append([]byte{}, tok.bytes...), nil
+
+	case tokenArray:
+		array := make([]interface{}, tok.length)
+		for i := range array {
+			var err error
+			if array[i], err = d.decodeInterface(exact); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+
+	case tokenMap:
+		m := make(map[string]interface{}, tok.length)
+		for i := 0; i < tok.length; i++ {
+			key, err := d.decodeInterface(true)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := d.decodeInterface(exact)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key := key.(type) {
+			case string:
+				m[key] = value
+			case []byte:
+				m[string(key)] = value
+			case int64:
+				m[strconv.FormatInt(key, 10)] = value
+			case uint64:
+				m[strconv.FormatUint(key, 10)] = value
+			default:
+				return nil, erk.WithParams(ErrUnmarshalType, erk.Params{"format": "map key", "type": "string"})
+			}
+		}
+		return m, nil
+
+	case tokenExt:
+		if tok.extType == extTimestamp {
+			t, err := tok.timestamp()
+			if err != nil {
+				return nil, err
+			}
+			return t.Format(time.RFC3339Nano), nil
+		}
+	}
+
+	return nil, erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": "interface {}"})
+}
+
+// decodeTime from an RFC 3339 string, or a timestamp extension.
+func (d *Decoder) decodeTime(v reflect.Value) error {
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case tok.kind == tokenStr:
+		t, err := time.Parse(time.RFC3339, string(tok.bytes))
+		if err != nil {
+			return erk.WrapAs(erk.WithParam(ErrUnmarshaler, "type", timeType.String()), err)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+
+	case tok.kind == tokenExt && tok.extType == extTimestamp:
+		t, err := tok.timestamp()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	return erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": timeType.String()})
+}
+
+func (d *Decoder) decodeNumber(v reflect.Value) error {
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	switch tok.kind {
+	case tokenInt:
+		v.SetString(strconv.FormatInt(tok.i, 10))
+	case tokenUint:
+		v.SetString(strconv.FormatUint(tok.u, 10))
+	case tokenFloat:
+		v.SetString(strconv.FormatFloat(tok.f, 'g', -1, 64))
+	default:
+		return erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": jsonNumberType.String()})
+	}
+
+	return nil
+}
+
+// decodeJSONUnmarshaler converts the next value to JSON, and provides it to the value's UnmarshalJSON method.
+func (d *Decoder) decodeJSONUnmarshaler(v reflect.Value) error {
+	generic, err := d.decodeInterface(true)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(generic)
+	if err == nil {
+		err = v.Interface().(json.Unmarshaler).UnmarshalJSON(raw)
+	}
+	if err != nil {
+		return erk.WrapAs(erk.WithParam(ErrUnmarshaler, "type", v.Type().Elem().String()), err)
+	}
+
+	return nil
+}
+
+func (d *Decoder) decodeTextUnmarshaler(v reflect.Value) error {
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	if tok.kind != tokenStr && tok.kind != tokenBin {
+		return erk.WithParams(ErrUnmarshalType, erk.Params{"format": tokenNames[tok.kind], "type": v.Type().Elem().String()})
+	}
+
+	if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText(tok.bytes); err != nil {
+		return erk.WrapAs(erk.WithParam(ErrUnmarshaler, "type", v.Type().Elem().String()), err)
+	}
+
+	return nil
+}
+
+func (tok token) int64() (int64, bool) {
+	switch tok.kind {
+	case tokenInt:
+		return tok.i, true
+	case tokenUint:
+		return int64(tok.u), tok.u <= math.MaxInt64
+	case tokenFloat:
+		return int64(tok.f), tok.f == math.Trunc(tok.f) && tok.f >= math.MinInt64 && tok.f < math.MaxInt64
+	}
+
+	return 0, false
+}
+
+func (tok token) uint64() (uint64, bool) {
+	switch tok.kind {
+	case tokenInt:
+		return uint64(tok.i), tok.i >= 0
+	case tokenUint:
+		return tok.u, true
+	case tokenFloat:
+		return uint64(tok.f), tok.f == math.Trunc(tok.f) && tok.f >= 0 && tok.f < math.MaxUint64
+	}
+
+	return 0, false
+}
+
+func (tok token) float64() (float64, bool) {
+	switch tok.kind {
+	case tokenInt:
+		return float64(tok.i), true
+	case tokenUint:
+		return float64(tok.u), true
+	case tokenFloat:
+		return tok.f, true
+	}
+
+	return 0, false
+}
+
+// timestamp decodes the 32, 64, or 96 bit timestamp extension.
+func (tok token) timestamp() (time.Time, error) {
+	b := tok.bytes
+	switch len(b) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0).UTC(), nil
+	case 8:
+		u := binary.BigEndian.Uint64(b)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b))).UTC(), nil
+	}
+
+	return time.Time{}, erk.WithParams(ErrUnmarshalType, erk.Params{"format": "extension", "type": timeType.String()})
+}
@@ -0,0 +1,478 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/internal/jsonfields"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonNumberType      = reflect.TypeOf(json.Number(""))
+	rawMessageType      = reflect.TypeOf(RawMessage{})
+	jsonMarshalerType   = reflect.TypeOf(new(json.Marshaler)).Elem()
+	textMarshalerType   = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf(new(json.Unmarshaler)).Elem()
+	textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+)
+
+// Marshal returns the MessagePack encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append the MessagePack encoding of v to buf, returning the extended buffer.
+// This allows buffers to be reused.
+func Append(buf []byte, v interface{}) ([]byte, error) {
+	e := &encoder{buf: buf}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return buf, err
+	}
+
+	return e.buf, nil
+}
+
+type encoder struct {
+	buf   []byte
+	depth int
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, formatNil)
+		return nil
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+	if e.depth > maxDepth {
+		return erk.WithParam(ErrTooDeep, "max", maxDepth)
+	}
+
+	// Types with custom encodings
+	t := v.Type()
+	switch {
+	case t == timeType:
+		e.encodeString(v.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+
+	case t == jsonNumberType:
+		return e.encodeNumber(json.Number(v.String()))
+
+	case t == rawMessageType:
+		return e.encodeRaw(v.Bytes())
+
+	case t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(jsonMarshalerType):
+		return e.encodeJSONMarshaler(v)
+
+	case t.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return e.encodeJSONMarshaler(v.Addr())
+
+	case t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(textMarshalerType):
+		return e.encodeTextMarshaler(v)
+
+	case t.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(textMarshalerType):
+		return e.encodeTextMarshaler(v.Addr())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, formatTrue)
+		} else {
+			e.buf = append(e.buf, formatFalse)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+
+	case reflect.Float32:
+		e.buf = append(e.buf, formatFloat32)
+		e.buf = appendUint32(e.buf, math.Float32bits(float32(v.Float())))
+
+	case reflect.Float64:
+		e.buf = append(e.buf, formatFloat64)
+		e.buf = appendUint64(e.buf, math.Float64bits(v.Float()))
+
+	case reflect.String:
+		e.encodeString(v.String())
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, formatNil)
+			return nil
+		}
+
+		return e.encode(v.Elem())
+
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, formatNil)
+			return nil
+		}
+
+		if t.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(t.Elem()).Implements(jsonMarshalerType) && !reflect.PtrTo(t.Elem()).Implements(textMarshalerType) {
+			e.encodeBin(v.Bytes())
+			return nil
+		}
+
+		return e.encodeArray(v)
+
+	case reflect.Array:
+		return e.encodeArray(v)
+
+	case reflect.Map:
+		return e.encodeMap(v)
+
+	case reflect.Struct:
+		return e.encodeStruct(v)
+
+	default:
+		return erk.WithParam(ErrUnsupportedType, "type", t.String())
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, formatInt8, byte(i))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, formatInt16)
+		e.buf = appendUint16(e.buf, uint16(i))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, formatInt32)
+		e.buf = appendUint32(e.buf, uint32(i))
+	default:
+		e.buf = append(e.buf, formatInt64)
+		e.buf = appendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *encoder) encodeUint(u uint64) {
+	switch {
+	case u <= math.MaxInt8:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, formatUint8, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = append(e.buf, formatUint16)
+		e.buf = appendUint16(e.buf, uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = append(e.buf, formatUint32)
+		e.buf = appendUint32(e.buf, uint32(u))
+	default:
+		e.buf = append(e.buf, formatUint64)
+		e.buf = appendUint64(e.buf, u)
+	}
+}
+
+func (e *encoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, formatFixStr|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, formatStr8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, formatStr16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, formatStr32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) encodeBin(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, formatBin8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, formatBin16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, formatBin32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) encodeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, formatFixArray|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, formatArray16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, formatArray32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *encoder) encodeMapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, formatFixMap|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, formatMap16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, formatMap32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *encoder) encodeArray(v reflect.Value) error {
+	e.encodeArrayHeader(v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, formatNil)
+		return nil
+	}
+
+	e.encodeMapHeader(v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		if err := e.encodeMapKey(iter.Key()); err != nil {
+			return err
+		}
+		if err := e.encode(iter.Value()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeMapKey as a string, like encoding/json.
+func (e *encoder) encodeMapKey(k reflect.Value) error {
+	if k.Kind() == reflect.String {
+		e.encodeString(k.String())
+		return nil
+	}
+
+	if k.Type().Implements(textMarshalerType) {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			e.encodeString("")
+			return nil
+		}
+
+		text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return erk.WrapAs(erk.WithParam(ErrMarshaler, "type", k.Type().String()), err)
+		}
+
+		e.encodeString(string(text))
+		return nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeString(strconv.FormatInt(k.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeString(strconv.FormatUint(k.Uint(), 10))
+	default:
+		return erk.WithParam(ErrUnsupportedType, "type", k.Type().String())
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	fields := jsonfields.Of(v.Type())
+
+	// Find the fields to encode first, since the map header contains the count
+	values := make([]reflect.Value, len(fields))
+	count := 0
+	for i, f := range fields {
+		fv, ok := fieldByIndex(v, f.Index)
+		if !ok || (f.OmitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+
+		values[i] = fv
+		count++
+	}
+
+	e.encodeMapHeader(count)
+	for i, f := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+
+		e.encodeString(f.Name)
+		if err := e.encode(values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeJSONMarshaler encodes the JSON produced by the value as MessagePack.
+func (e *encoder) encodeJSONMarshaler(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.buf = append(e.buf, formatNil)
+		return nil
+	}
+
+	raw, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return erk.WrapAs(erk.WithParam(ErrMarshaler, "type", v.Type().String()), err)
+	}
+
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return erk.WrapAs(erk.WithParam(ErrMarshaler, "type", v.Type().String()), err)
+	}
+
+	return e.encode(reflect.ValueOf(generic))
+}
+
+// encodeRaw appends the raw value, after checking it is exactly one value.
+func (e *encoder) encodeRaw(raw []byte) error {
+	if len(raw) == 0 {
+		e.buf = append(e.buf, formatNil)
+		return nil
+	}
+
+	// An invalid raw value would change how the values after it are decoded
+	d := NewDecoder(raw)
+	err := d.skip()
+	if err == nil && d.Buffered() > 0 {
+		err = ErrTrailingData
+	}
+	if err != nil {
+		return erk.WrapAs(erk.WithParam(ErrMarshaler, "type", rawMessageType.String()), err)
+	}
+
+	e.buf = append(e.buf, raw...)
+	return nil
+}
+
+// encodeNumber as an integer if possible, so it is not rounded.
+func (e *encoder) encodeNumber(n json.Number) error {
+	if n == "" {
+		e.encodeInt(0)
+		return nil
+	}
+
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		e.encodeInt(i)
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		e.encodeUint(u)
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return erk.WrapAs(erk.WithParam(ErrMarshaler, "type", jsonNumberType.String()), err)
+	}
+
+	e.buf = append(e.buf, formatFloat64)
+	e.buf = appendUint64(e.buf, math.Float64bits(f))
+	return nil
+}
+
+func (e *encoder) encodeTextMarshaler(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.buf = append(e.buf, formatNil)
+		return nil
+	}
+
+	text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return erk.WrapAs(erk.WithParam(ErrMarshaler, "type", v.Type().String()), err)
+	}
+
+	e.encodeString(string(text))
+	return nil
+}
+
+// fieldByIndex returns the field, or false if it is in a nil embedded struct.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// isEmptyValue matches the values omitted by encoding/json with omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+func appendUint16(buf []byte, u uint16) []byte {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], u)
+	return append(buf, b[:]...)
+}
+
+func appendUint32(buf []byte, u uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], u)
+	return append(buf, b[:]...)
+}
+
+func appendUint64(buf []byte, u uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	return append(buf, b[:]...)
+}
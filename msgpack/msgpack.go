@@ -0,0 +1,86 @@
+// Package msgpack implements the MessagePack format (https://github.com/msgpack/msgpack/blob/master/spec.md),
+// used by wire encoding version 2.
+//
+// Values are encoded like encoding/json encodes them, so types need no changes to support both:
+// structs are encoded as maps using the names in `json:"..."` struct tags (including omitempty and "-"),
+// embedded struct fields are promoted, types implementing json.Marshaler or encoding.TextMarshaler
+// use those methods, and time.Time is encoded as an RFC 3339 string.
+// Unlike encoding/json, []byte is encoded as binary, and map keys are not sorted.
+//
+// Decoding into an interface{} produces the same types as encoding/json: nil, bool, float64, string,
+// []interface{} and map[string]interface{}, except binary data is decoded as []byte.
+package msgpack
+
+import (
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+)
+
+// Error kinds
+type (
+	ErkUnsupportedType struct{ erks.Default }
+	ErkInvalidData     struct{ erks.Default }
+	ErkUnmarshalType   struct{ erks.Default }
+	ErkUnknownField    struct{ erks.Default }
+)
+
+// Errors
+var (
+	ErrUnsupportedType = erk.New(ErkUnsupportedType{}, "cannot encode value of type '{{.type}}'")
+	ErrMarshaler       = erk.New(ErkUnsupportedType{}, "could not encode '{{.type}}' with its marshal method: {{.err}}")
+	ErrInvalidTarget   = erk.New(ErkUnmarshalType{}, "can only decode into a non-nil pointer, got '{{.type}}'")
+	ErrUnexpectedEnd   = erk.New(ErkInvalidData{}, "unexpected end of data")
+	ErrInvalidFormat   = erk.New(ErkInvalidData{}, "invalid format byte 0x{{.format}} at offset {{.offset}}")
+	ErrTooDeep         = erk.New(ErkInvalidData{}, "values are nested more than {{.max}} levels deep")
+	ErrTrailingData    = erk.New(ErkInvalidData{}, "data remains after the first value")
+	ErrUnmarshalType   = erk.New(ErkUnmarshalType{}, "cannot decode {{.format}} into a value of type '{{.type}}'")
+	ErrUnknownField    = erk.New(ErkUnknownField{}, "unknown field '{{.field}}'")
+)
+
+// RawMessage is an encoded MessagePack value.
+// Like json.RawMessage, it is encoded as is, and decoding copies the next value into it,
+// so values can be encoded once and reused, or decoded later.
+type RawMessage []byte
+
+// Format bytes
+const (
+	formatNil      = 0xc0
+	formatFalse    = 0xc2
+	formatTrue     = 0xc3
+	formatBin8     = 0xc4
+	formatBin16    = 0xc5
+	formatBin32    = 0xc6
+	formatExt8     = 0xc7
+	formatExt16    = 0xc8
+	formatExt32    = 0xc9
+	formatFloat32  = 0xca
+	formatFloat64  = 0xcb
+	formatUint8    = 0xcc
+	formatUint16   = 0xcd
+	formatUint32   = 0xce
+	formatUint64   = 0xcf
+	formatInt8     = 0xd0
+	formatInt16    = 0xd1
+	formatInt32    = 0xd2
+	formatInt64    = 0xd3
+	formatFixExt1  = 0xd4
+	formatFixExt16 = 0xd8
+	formatStr8     = 0xd9
+	formatStr16    = 0xda
+	formatStr32    = 0xdb
+	formatArray16  = 0xdc
+	formatArray32  = 0xdd
+	formatMap16    = 0xde
+	formatMap32    = 0xdf
+
+	formatFixMap   = 0x80 // Up to 0x8f
+	formatFixArray = 0x90 // Up to 0x9f
+	formatFixStr   = 0xa0 // Up to 0xbf
+	formatNegInt   = 0xe0 // Up to 0xff
+)
+
+// extTimestamp is the extension type of timestamps.
+const extTimestamp = -1
+
+// maxDepth limits how deeply values can be nested, so decoding cannot overflow the stack.
+const maxDepth = 10000
@@ -0,0 +1,249 @@
+package msgpack_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/msgpack"
+	"github.com/matryer/is"
+)
+
+type Inner struct {
+	Value int `json:"value"`
+}
+
+type Embedded struct {
+	Promoted string `json:"promoted"`
+}
+
+type Outer struct {
+	Embedded
+	Name     string `json:"name"`
+	Skipped  string `json:"-"`
+	Empty    string `json:"empty,omitempty"`
+	Untagged bool
+	Inner    *Inner            `json:"inner"`
+	Items    []Inner           `json:"items"`
+	Labels   map[string]string `json:"labels"`
+	Data     []byte            `json:"data"`
+	At       time.Time         `json:"at"`
+	Raw      json.RawMessage   `json:"raw"`
+	private  int
+}
+
+func TestMarshal(t *testing.T) {
+	table := []struct {
+		Name     string
+		Value    interface{}
+		Expected []byte
+	}{
+		{Name: "nil", Value: nil, Expected: []byte{0xc0}},
+		{Name: "true", Value: true, Expected: []byte{0xc3}},
+		{Name: "false", Value: false, Expected: []byte{0xc2}},
+		{Name: "positive fixint", Value: 127, Expected: []byte{0x7f}},
+		{Name: "negative fixint", Value: -32, Expected: []byte{0xe0}},
+		{Name: "uint8", Value: 200, Expected: []byte{0xcc, 0xc8}},
+		{Name: "int8", Value: -100, Expected: []byte{0xd0, 0x9c}},
+		{Name: "uint16", Value: 1000, Expected: []byte{0xcd, 0x03, 0xe8}},
+		{Name: "int64", Value: int64(math.MinInt64), Expected: []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{Name: "uint64", Value: uint64(math.MaxUint64), Expected: []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{Name: "float32", Value: float32(1.5), Expected: []byte{0xca, 0x3f, 0xc0, 0, 0}},
+		{Name: "float64", Value: 1.5, Expected: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{Name: "fixstr", Value: "abc", Expected: []byte{0xa3, 'a', 'b', 'c'}},
+		{Name: "str8", Value: strings.Repeat("a", 32), Expected: append([]byte{0xd9, 32}, strings.Repeat("a", 32)...)},
+		{Name: "bin", Value: []byte{1, 2}, Expected: []byte{0xc4, 2, 1, 2}},
+		{Name: "array", Value: []int{1, 2}, Expected: []byte{0x92, 1, 2}},
+		{Name: "nil slice", Value: []int(nil), Expected: []byte{0xc0}},
+		{Name: "map", Value: map[string]int{"a": 1}, Expected: []byte{0x81, 0xa1, 'a', 1}},
+		{Name: "map with int keys", Value: map[int]bool{1: true}, Expected: []byte{0x81, 0xa1, '1', 0xc3}},
+		{Name: "json.Number", Value: json.Number("12"), Expected: []byte{0x0c}},
+		{Name: "json.RawMessage", Value: json.RawMessage(`{"a":[1]}`), Expected: []byte{0x81, 0xa1, 'a', 0x91, 1}},
+		{Name: "RawMessage", Value: []msgpack.RawMessage{{0x91, 1}, nil}, Expected: []byte{0x92, 0x91, 1, 0xc0}},
+		{
+			Name: "struct with tags",
+			Value: struct {
+				A int    `json:"a"`
+				B string `json:"b,omitempty"`
+				C int    `json:"-"`
+			}{A: 1, C: 2},
+			Expected: []byte{0x81, 0xa1, 'a', 1},
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			data, err := msgpack.Marshal(entry.Value)
+			is.NoErr(err)
+			is.Equal(data, entry.Expected)
+		})
+	}
+
+	t.Run("appends to the buffer", func(t *testing.T) {
+		is := is.New(t)
+
+		data, err := msgpack.Append([]byte{0xc0}, true)
+		is.NoErr(err)
+		is.Equal(data, []byte{0xc0, 0xc3})
+	})
+
+	t.Run("with invalid RawMessage", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := msgpack.Marshal([]msgpack.RawMessage{{0x92, 1}})
+		is.True(errors.Is(err, msgpack.ErrMarshaler))
+
+		_, err = msgpack.Marshal(msgpack.RawMessage{0xc3, 0xc3})
+		is.True(errors.Is(err, msgpack.ErrMarshaler))
+	})
+
+	t.Run("with unsupported type", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := msgpack.Marshal(map[string]interface{}{"ch": make(chan int)})
+		is.True(errors.Is(err, msgpack.ErrUnsupportedType))
+		is.Equal(erk.GetParams(err)["type"], "chan int")
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	is := is.New(t)
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	original := Outer{
+		Embedded: Embedded{Promoted: "up"},
+		Name:     "abc",
+		Skipped:  "skipped",
+		Untagged: true,
+		Inner:    &Inner{Value: -5},
+		Items:    []Inner{{Value: 1}, {Value: 300}},
+		Labels:   map[string]string{"a": "b"},
+		Data:     []byte{0, 1, 2},
+		At:       at,
+		Raw:      json.RawMessage(`{"x":1}`),
+		private:  1,
+	}
+
+	data, err := msgpack.Marshal(&original)
+	is.NoErr(err)
+
+	var decoded Outer
+	is.NoErr(msgpack.Unmarshal(data, &decoded))
+
+	expected := original
+	expected.Skipped = ""
+	expected.private = 0
+	is.True(decoded.At.Equal(at))
+	decoded.At = at
+	is.True(reflect.DeepEqual(decoded, expected))
+
+	// Decoding into an interface{} matches encoding/json, except for binary data
+	var generic interface{}
+	is.NoErr(msgpack.Unmarshal(data, &generic))
+
+	fields := generic.(map[string]interface{})
+	is.Equal(fields["promoted"], "up")
+	is.Equal(fields["Untagged"], true)
+	is.Equal(fields["inner"], map[string]interface{}{"value": float64(-5)})
+	is.Equal(fields["data"], []byte{0, 1, 2})
+	is.Equal(fields["at"], at.Format(time.RFC3339Nano))
+	is.Equal(fields["raw"], map[string]interface{}{"x": float64(1)})
+	_, hasEmpty := fields["empty"]
+	is.True(!hasEmpty)
+}
+
+func TestUnmarshal(t *testing.T) {
+	table := []struct {
+		Name          string
+		Data          []byte
+		Target        interface{}
+		Expected      interface{}
+		ExpectedError error
+	}{
+		{Name: "int from uint", Data: []byte{0xcc, 0xc8}, Target: new(int), Expected: 200},
+		{Name: "int from integral float", Data: []byte{0xcb, 0x40, 0, 0, 0, 0, 0, 0, 0}, Target: new(int), Expected: 2},
+		{Name: "float from int", Data: []byte{0xd0, 0x9c}, Target: new(float64), Expected: float64(-100)},
+		{Name: "string from bin", Data: []byte{0xc4, 1, 'a'}, Target: new(string), Expected: "a"},
+		{Name: "map keys matched case insensitively", Data: []byte{0x81, 0xa5, 'V', 'A', 'L', 'U', 'E', 7}, Target: new(Inner), Expected: Inner{Value: 7}},
+		{Name: "unknown fields are skipped", Data: []byte{0x82, 0xa1, 'x', 0x91, 1, 0xa5, 'v', 'a', 'l', 'u', 'e', 7}, Target: new(Inner), Expected: Inner{Value: 7}},
+		{Name: "nil zeroes pointers", Data: []byte{0xc0}, Target: func() interface{} { p := &Inner{}; return &p }(), Expected: (*Inner)(nil)},
+		{Name: "int keys", Data: []byte{0x81, 0xa1, '1', 0xc3}, Target: new(map[int]bool), Expected: map[int]bool{1: true}},
+		{Name: "json.Number", Data: []byte{0x0c}, Target: new(json.Number), Expected: json.Number("12")},
+		{Name: "RawMessage", Data: []byte{0x92, 0x81, 0xa1, 'a', 1, 0xc0}, Target: new([]msgpack.RawMessage), Expected: []msgpack.RawMessage{{0x81, 0xa1, 'a', 1}, {0xc0}}},
+		{Name: "wrong type", Data: []byte{0xa1, 'a'}, Target: new(int), ExpectedError: msgpack.ErrUnmarshalType},
+		{Name: "overflow", Data: []byte{0xcd, 0x03, 0xe8}, Target: new(int8), ExpectedError: msgpack.ErrUnmarshalType},
+		{Name: "negative into uint", Data: []byte{0xff}, Target: new(uint), ExpectedError: msgpack.ErrUnmarshalType},
+		{Name: "truncated", Data: []byte{0xa3, 'a'}, Target: new(string), ExpectedError: msgpack.ErrUnexpectedEnd},
+		{Name: "invalid format", Data: []byte{0xc1}, Target: new(interface{}), ExpectedError: msgpack.ErrInvalidFormat},
+		{Name: "trailing data", Data: []byte{0xc3, 0xc3}, Target: new(bool), ExpectedError: msgpack.ErrTrailingData},
+		{Name: "huge length", Data: []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, Target: new([]int), ExpectedError: msgpack.ErrUnexpectedEnd},
+		{Name: "non-pointer target", Data: []byte{0xc3}, Target: false, ExpectedError: msgpack.ErrInvalidTarget},
+		{Name: "nil target", Data: []byte{0xc3}, Target: nil, ExpectedError: msgpack.ErrInvalidTarget},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			err := msgpack.Unmarshal(entry.Data, entry.Target)
+			if entry.ExpectedError != nil {
+				is.True(errors.Is(err, entry.ExpectedError))
+				return
+			}
+
+			is.NoErr(err)
+			is.Equal(reflect.ValueOf(entry.Target).Elem().Interface(), entry.Expected)
+		})
+	}
+
+	t.Run("deeply nested data", func(t *testing.T) {
+		is := is.New(t)
+
+		data := make([]byte, 20000)
+		for i := range data {
+			data[i] = 0x91
+		}
+
+		var value interface{}
+		is.True(errors.Is(msgpack.Unmarshal(data, &value), msgpack.ErrTooDeep))
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("decodes consecutive values", func(t *testing.T) {
+		is := is.New(t)
+
+		dec := msgpack.NewDecoder([]byte{0x01, 0xa1, 'a'})
+
+		var i int
+		is.NoErr(dec.Decode(&i))
+		is.Equal(i, 1)
+		is.Equal(dec.Buffered(), 2)
+
+		var s string
+		is.NoErr(dec.Decode(&s))
+		is.Equal(s, "a")
+		is.Equal(dec.Buffered(), 0)
+	})
+
+	t.Run("disallows unknown fields", func(t *testing.T) {
+		is := is.New(t)
+
+		dec := msgpack.NewDecoder([]byte{0x81, 0xa1, 'x', 0x01})
+		dec.DisallowUnknownFields()
+
+		var inner Inner
+		err := dec.Decode(&inner)
+		is.True(errors.Is(err, msgpack.ErrUnknownField))
+		is.Equal(erk.GetParams(err)["field"], "x")
+	})
+}
@@ -1,11 +1,37 @@
 // Package strand defines strands that are encoded with wire.
 package strand
 
+import "github.com/hoistup/hoist-go/wire"
+
 // RequestDetails are the details encoded with wire for a request.
 type RequestDetails struct {
 	RequestID    string `json:"id"`
 	ServiceName  string `json:"svc"`
 	FunctionName string `json:"fn"`
+
+	// Deadline is when the caller stops waiting for a response, in Unix milliseconds.
+	// Zero means there is no deadline.
+	Deadline int64 `json:"dl,omitempty"`
+
+	// AcceptCompression lists the compressions the caller can decode, in order of preference.
+	// Responses are only compressed with one of them.
+	AcceptCompression []wire.Compression `json:"acmp,omitempty"`
+
+	// AcceptStream is true when the caller can receive the items of streaming functions as a series of frames.
+	// Otherwise, the items are sent at once, in a list.
+	AcceptStream bool `json:"astrm,omitempty"`
+
+	// Batch lists the calls of a batch request, which the service runs concurrently, instead of FunctionName.
+	// The params of a batch request are a list containing the params of each call, in the same order.
+	// Each call is made with its own request ID: the batch request ID, then "/", then the ID of the call.
+	Batch []BatchCall `json:"batch,omitempty"`
+}
+
+// BatchCall is a function call in a batch request.
+type BatchCall struct {
+	// ID of the call, which is unique in the batch.
+	ID           string `json:"id"`
+	FunctionName string `json:"fn"`
 }
 
 // ResponseDetails are the details encoded with wire for a response.
@@ -13,4 +39,28 @@ type ResponseDetails struct {
 	RequestID       string `json:"id"`
 	IsError         bool   `json:"err,omitempty"`
 	IsInternalError bool   `json:"ierr,omitempty"`
+
+	// AcceptCompression lists the compressions the service can decode, when the request accepts compression.
+	// Callers only compress requests with one of them.
+	AcceptCompression []wire.Compression `json:"acmp,omitempty"`
+
+	// Sequence is the position of the frame in a streamed response, starting at one.
+	// It is zero when the response is a single frame, since the function does not stream, or the request
+	// does not accept streams.
+	Sequence uint64 `json:"seq,omitempty"`
+
+	// EndOfStream is true on the final frame of a streamed response, which contains no data, or the error
+	// that ended the stream. Streams without any items only have the final frame.
+	EndOfStream bool `json:"eos,omitempty"`
+
+	// Batch lists the result of each call of a batch request, in the order of the calls.
+	// The params of the response are a list containing the data or error of each call, in the same order.
+	Batch []BatchResult `json:"batch,omitempty"`
+}
+
+// BatchResult describes the result of a call in a batch request.
+type BatchResult struct {
+	ID              string `json:"id"`
+	IsError         bool   `json:"err,omitempty"`
+	IsInternalError bool   `json:"ierr,omitempty"`
 }
@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+)
+
+var (
+	ErrNotWebSocket    = erk.New(ErkHandshake{}, "request is not a WebSocket upgrade: {{.reason}}")
+	ErrOriginForbidden = erk.New(ErkHandshake{}, "origin '{{.origin}}' is not allowed")
+	ErrHijack          = erk.New(ErkHandshake{}, "could not take over the connection: {{.err}}")
+	ErrDialing         = erk.New(ErkHandshake{}, "could not connect to '{{.url}}': {{.err}}")
+	ErrHandshake       = erk.New(ErkHandshake{}, "WebSocket handshake with '{{.url}}' failed: {{.reason}}")
+)
+
+// acceptGUID is appended to the key of the client, to compute the accept header of the server.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Upgrader upgrades HTTP requests to WebSocket connections.
+type Upgrader struct {
+	// CheckOrigin returns true if the page that opened the connection may use it.
+	// Defaults to SameOrigin, so other sites cannot connect with the cookies of the user.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// SameOrigin returns true if the request has no Origin header, or its host matches the Host of the request.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Upgrade the request to a WebSocket connection.
+// If the request cannot be upgraded, an HTTP error is written, and the error is returned.
+//
+// Deadlines set by the http.Server are cleared, since the connection outlives the request.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	reason := ""
+	switch {
+	case r.Method != http.MethodGet:
+		reason = "method must be GET"
+	case !headerContains(r.Header, "Connection", "upgrade"):
+		reason = "Connection header must contain 'upgrade'"
+	case !headerContains(r.Header, "Upgrade", "websocket"):
+		reason = "Upgrade header must contain 'websocket'"
+	case r.Header.Get("Sec-WebSocket-Key") == "":
+		reason = "Sec-WebSocket-Key header is required"
+	}
+	if reason != "" {
+		http.Error(w, reason, http.StatusBadRequest)
+		return nil, erk.WithParam(ErrNotWebSocket, "reason", reason)
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, erk.WithParam(ErrNotWebSocket, "reason", "Sec-WebSocket-Version header must be 13")
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, erk.WithParam(ErrOriginForbidden, "origin", r.Header.Get("Origin"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, erk.WithParam(ErrHijack, "err", "response writer does not support hijacking")
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, erk.WrapAs(ErrHijack, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, erk.WrapAs(ErrUnableToWrite, err)
+	}
+
+	return newConn(conn, buffered.Reader, false), nil
+}
+
+// Dial opens a WebSocket connection to the ws:// or wss:// URL, sending the headers with the handshake.
+// Canceling ctx only stops connecting.
+func Dial(ctx context.Context, rawURL string, headers http.Header) (*Conn, error) {
+	errParams := erk.Params{"url": rawURL}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrDialing, errParams), err)
+	}
+
+	address := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrDialing, errParams), err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, erk.WrapAs(erk.WithParams(ErrDialing, errParams), err)
+		}
+		conn = tlsConn
+	}
+
+	c, err := handshake(conn, u, headers, errParams)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func handshake(conn net.Conn, u *url.URL, headers http.Header, errParams erk.Params) (*Conn, error) {
+	var rawKey [16]byte
+	rand.Read(rawKey[:])
+	key := base64.StdEncoding.EncodeToString(rawKey[:])
+
+	u = &url.URL{Scheme: "http", Host: u.Host, Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrDialing, errParams), err)
+	}
+
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(conn); err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrDialing, errParams), err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, erk.WrapAs(erk.WithParams(ErrDialing, errParams), err)
+	}
+	resp.Body.Close()
+
+	reason := ""
+	switch {
+	case resp.StatusCode != http.StatusSwitchingProtocols:
+		reason = "unexpected status " + resp.Status
+	case resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key):
+		reason = "Sec-WebSocket-Accept header does not match the key"
+	}
+	if reason != "" {
+		errParams["reason"] = reason
+		return nil, erk.WithParams(ErrHandshake, errParams)
+	}
+
+	return newConn(conn, reader, true), nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains returns true if the comma separated header contains the token, ignoring case.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[name] {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,425 @@
+// Package websocket implements the parts of the WebSocket protocol (RFC 6455) used by hoist services:
+// text and binary messages, ping and pong keepalive, and closing.
+//
+// Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/erks"
+	"github.com/hoistup/hoist-go/internal/chunked"
+)
+
+type (
+	ErkHandshake  struct{ erks.Default }
+	ErkProtocol   struct{ erks.Default }
+	ErkConnection struct{ erks.Default }
+)
+
+var (
+	ErrProtocol        = erk.New(ErkProtocol{}, "invalid WebSocket frame: {{.reason}}")
+	ErrMessageTooLarge = erk.New(ErkProtocol{}, "message is larger than the maximum of {{.max}} bytes")
+	ErrClosed          = erk.New(ErkConnection{}, "connection closed with code {{.code}}")
+	ErrUnableToRead    = erk.New(ErkConnection{}, "unable to read from the connection: {{.err}}")
+	ErrUnableToWrite   = erk.New(ErkConnection{}, "unable to write to the connection: {{.err}}")
+)
+
+// Message types
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Close codes
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseMessageTooBig = 1009
+)
+
+// DefaultMaxMessageBytes is the largest message a Conn reads, unless SetMaxMessageBytes is called.
+// It fits a wire frame at the default wire.Decoder limits.
+const DefaultMaxMessageBytes = 33 << 20 // 33 MiB
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	maxControlPayload = 125
+)
+
+// Conn is a WebSocket connection, created by Upgrader.Upgrade or Dial.
+//
+// ReadMessage must only be called by one goroutine at a time,
+// while the other methods are safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool // Frames sent by clients are masked
+
+	maxMessageBytes int64
+	pingHandler     func(data []byte)
+
+	// readMu guards the read deadline, so SetReadDeadline is not undone by the read timeout
+	readMu      sync.Mutex
+	readTimeout time.Duration
+
+	writeMu      sync.Mutex
+	writeTimeout time.Duration
+	writeBuf     []byte
+	closeSent    bool
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{
+		conn:            conn,
+		reader:          reader,
+		client:          client,
+		maxMessageBytes: DefaultMaxMessageBytes,
+	}
+}
+
+// SetMaxMessageBytes sets the largest message ReadMessage accepts. Larger messages close the connection.
+// Use 0 for no limit.
+func (c *Conn) SetMaxMessageBytes(size int64) {
+	c.maxMessageBytes = size
+}
+
+// SetPingHandler sets a function called by ReadMessage with the data of each ping, after it is answered.
+// It must be set before ReadMessage is called.
+func (c *Conn) SetPingHandler(handler func(data []byte)) {
+	c.pingHandler = handler
+}
+
+// SetReadTimeout sets how long to wait for each frame, including pings and pongs, before failing the read.
+// Use 0 to wait forever.
+func (c *Conn) SetReadTimeout(timeout time.Duration) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	c.readTimeout = timeout
+}
+
+// SetReadDeadline sets a fixed deadline for reading, replacing the read timeout.
+// Use the current time to stop a ReadMessage call in progress.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	c.readTimeout = 0
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteTimeout sets how long each write may take, before failing the write. Use 0 to wait forever.
+func (c *Conn) SetWriteTimeout(timeout time.Duration) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.writeTimeout = timeout
+}
+
+// ReadMessage reads the next text or binary message, answering pings while it waits.
+//
+// When the peer closes the connection, ReadMessage replies, and returns ErrClosed with the code
+// and reason params. Invalid frames close the connection with CloseProtocolError.
+func (c *Conn) ReadMessage() (messageType int, message []byte, err error) {
+	for {
+		header, err := c.readHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if header.opcode >= opClose {
+			payload, err := c.readPayload(header)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			if err := c.handleControl(header.opcode, payload); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		}
+
+		switch {
+		case header.opcode == opContinuation && messageType == 0:
+			return 0, nil, c.fail(CloseProtocolError, protocolErr("continuation frame without a message"))
+		case header.opcode != opContinuation && messageType != 0:
+			return 0, nil, c.fail(CloseProtocolError, protocolErr("new message before the last one finished"))
+		case header.opcode != opContinuation:
+			messageType = int(header.opcode)
+		}
+
+		if c.maxMessageBytes > 0 && int64(len(message))+header.length > c.maxMessageBytes {
+			return 0, nil, c.fail(CloseMessageTooBig, erk.WithParam(ErrMessageTooLarge, "max", c.maxMessageBytes))
+		}
+
+		payload, err := c.readPayload(header)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if message == nil {
+			message = payload
+		} else {
+			message = append(message, payload...)
+		}
+
+		if header.fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseProtocolError, protocolErr("text message is not valid UTF-8"))
+			}
+
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage writes a text or binary message in one frame.
+func (c *Conn) WriteMessage(messageType int, message []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return protocolErr("unknown message type")
+	}
+
+	return c.writeFrame(byte(messageType), message)
+}
+
+// Ping the peer, which answers with a pong. Pongs are read by ReadMessage, and reset the read timeout.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return protocolErr("ping data is too long")
+	}
+
+	return c.writeFrame(opPing, data)
+}
+
+// Close sends a normal close frame, and closes the connection.
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormal, "")
+}
+
+// CloseWithCode sends a close frame with the code and reason, and closes the connection.
+// The peer is not waited for, since hoist callers do not send anything after a close frame.
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	c.writeClose(code, reason)
+	return c.conn.Close()
+}
+
+// header of a frame.
+type header struct {
+	fin    bool
+	opcode byte
+	length int64
+	mask   []byte
+}
+
+func (c *Conn) readHeader() (*header, error) {
+	c.readMu.Lock()
+	if c.readTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	c.readMu.Unlock()
+
+	var start [2]byte
+	if _, err := io.ReadFull(c.reader, start[:]); err != nil {
+		return nil, erk.WrapAs(ErrUnableToRead, err)
+	}
+
+	h := &header{
+		fin:    start[0]&finBit != 0,
+		opcode: start[0] & 0x0f,
+		length: int64(start[1] &^ maskBit),
+	}
+
+	if start[0]&rsvBits != 0 {
+		return nil, c.fail(CloseProtocolError, protocolErr("reserved bits are set"))
+	}
+
+	switch h.opcode {
+	case opContinuation, opText, opBinary:
+	case opClose, opPing, opPong:
+		if !h.fin || h.length > maxControlPayload {
+			return nil, c.fail(CloseProtocolError, protocolErr("control frames cannot be fragmented or longer than 125 bytes"))
+		}
+	default:
+		return nil, c.fail(CloseProtocolError, protocolErr("unknown opcode"))
+	}
+
+	// Frames from clients are masked, and frames from servers are not
+	if masked := start[1]&maskBit != 0; masked == c.client {
+		return nil, c.fail(CloseProtocolError, protocolErr("frame masking is invalid"))
+	}
+
+	switch h.length {
+	case 126:
+		var length [2]byte
+		if _, err := io.ReadFull(c.reader, length[:]); err != nil {
+			return nil, erk.WrapAs(ErrUnableToRead, err)
+		}
+		h.length = int64(binary.BigEndian.Uint16(length[:]))
+
+	case 127:
+		var length [8]byte
+		if _, err := io.ReadFull(c.reader, length[:]); err != nil {
+			return nil, erk.WrapAs(ErrUnableToRead, err)
+		}
+		if length[0]&0x80 != 0 {
+			return nil, c.fail(CloseProtocolError, protocolErr("frame length is too large"))
+		}
+		h.length = int64(binary.BigEndian.Uint64(length[:]))
+	}
+
+	if !c.client {
+		h.mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.reader, h.mask); err != nil {
+			return nil, erk.WrapAs(ErrUnableToRead, err)
+		}
+	}
+
+	return h, nil
+}
+
+func (c *Conn) readPayload(h *header) ([]byte, error) {
+	payload, err := chunked.ReadN(c.reader, h.length)
+	if err != nil {
+		return nil, erk.WrapAs(ErrUnableToRead, err)
+	}
+
+	if h.mask != nil {
+		maskBytes(h.mask, payload)
+	}
+
+	return payload, nil
+}
+
+// handleControl answers pings and close frames. Pongs only reset the read timeout, when the next header is read.
+func (c *Conn) handleControl(opcode byte, payload []byte) error {
+	switch opcode {
+	case opPing:
+		if err := c.writeFrame(opPong, payload); err != nil {
+			return err
+		}
+
+		if c.pingHandler != nil {
+			c.pingHandler(payload)
+		}
+
+	case opClose:
+		code := CloseNormal
+		reason := ""
+		if len(payload) >= 2 {
+			code = int(binary.BigEndian.Uint16(payload))
+			reason = string(payload[2:])
+		}
+
+		c.writeClose(code, "")
+		c.conn.Close()
+		return erk.WithParams(ErrClosed, erk.Params{"code": code, "reason": reason})
+	}
+
+	return nil
+}
+
+// fail closes the connection with the code, and returns err.
+func (c *Conn) fail(code int, err error) error {
+	c.CloseWithCode(code, "")
+	return err
+}
+
+// writeClose sends a close frame, unless one was already sent.
+func (c *Conn) writeClose(code int, reason string) {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+
+	c.writeFrame(opClose, payload)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return erk.WithParam(ErrClosed, "code", CloseNormal)
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	buf := c.writeBuf[:0]
+	buf = append(buf, finBit|opcode)
+
+	lengthByte := byte(0)
+	if c.client {
+		lengthByte = maskBit
+	}
+
+	switch length := len(payload); {
+	case length <= 125:
+		buf = append(buf, lengthByte|byte(length))
+	case length <= 0xffff:
+		buf = append(buf, lengthByte|126, 0, 0)
+		binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(length))
+	default:
+		buf = append(buf, lengthByte|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(length))
+	}
+
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		buf = append(buf, mask[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(mask[:], buf[start:])
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	// Keep small buffers for the next frame
+	if cap(buf) <= 64<<10 {
+		c.writeBuf = buf
+	}
+
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+
+	if _, err := c.conn.Write(buf); err != nil {
+		return erk.WrapAs(ErrUnableToWrite, err)
+	}
+
+	return nil
+}
+
+func maskBytes(mask, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+func protocolErr(reason string) error {
+	return erk.WithParam(ErrProtocol, "reason", reason)
+}
@@ -0,0 +1,277 @@
+package websocket_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/websocket"
+	"github.com/matryer/is"
+)
+
+// newEchoServer returns a server that echoes messages, and pings before each echo.
+func newEchoServer(upgrader *websocket.Upgrader) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			conn.Ping([]byte("ping"))
+			if string(message) == "close" {
+				conn.CloseWithCode(websocket.CloseGoingAway, "bye")
+				return
+			}
+
+			conn.WriteMessage(messageType, message)
+		}
+	}))
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestConn(t *testing.T) {
+	server := newEchoServer(&websocket.Upgrader{})
+	defer server.Close()
+
+	table := []struct {
+		Name        string
+		MessageType int
+		Message     []byte
+	}{
+		{Name: "text", MessageType: websocket.TextMessage, Message: []byte("hello")},
+		{Name: "binary", MessageType: websocket.BinaryMessage, Message: []byte{0, 1, 0xff}},
+		{Name: "empty", MessageType: websocket.BinaryMessage, Message: []byte{}},
+		{Name: "with 16 bit length", MessageType: websocket.BinaryMessage, Message: bytes.Repeat([]byte("a"), 300)},
+		{Name: "with 64 bit length", MessageType: websocket.BinaryMessage, Message: bytes.Repeat([]byte("a"), 70000)},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			conn, err := websocket.Dial(context.Background(), wsURL(server), nil)
+			is.NoErr(err)
+			defer conn.Close()
+
+			// Pings sent by the server are answered while reading
+			for i := 0; i < 2; i++ {
+				is.NoErr(conn.WriteMessage(entry.MessageType, entry.Message))
+
+				messageType, message, err := conn.ReadMessage()
+				is.NoErr(err)
+				is.Equal(messageType, entry.MessageType)
+				is.Equal(message, entry.Message)
+			}
+		})
+	}
+
+	t.Run("calls the ping handler", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, err := websocket.Dial(context.Background(), wsURL(server), nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		var pings []string
+		conn.SetPingHandler(func(data []byte) { pings = append(pings, string(data)) })
+
+		// The server pings before echoing
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+		_, _, err = conn.ReadMessage()
+		is.NoErr(err)
+		is.Equal(pings, []string{"ping"})
+	})
+
+	t.Run("returns the close code of the peer", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, err := websocket.Dial(context.Background(), wsURL(server), nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		is.NoErr(conn.WriteMessage(websocket.TextMessage, []byte("close")))
+		_, _, err = conn.ReadMessage()
+		is.True(errors.Is(err, websocket.ErrClosed))
+		is.Equal(erk.GetParams(err)["code"], websocket.CloseGoingAway)
+		is.Equal(erk.GetParams(err)["reason"], "bye")
+	})
+
+	t.Run("with message over the limit", func(t *testing.T) {
+		is := is.New(t)
+
+		readErr := make(chan error, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r)
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			conn.SetMaxMessageBytes(4)
+			_, _, err = conn.ReadMessage()
+			readErr <- err
+		}))
+		defer server.Close()
+
+		conn, err := websocket.Dial(context.Background(), wsURL(server), nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		is.NoErr(conn.WriteMessage(websocket.BinaryMessage, []byte("12345")))
+		is.True(errors.Is(<-readErr, websocket.ErrMessageTooLarge))
+
+		// The connection was closed by the server
+		_, _, err = conn.ReadMessage()
+		is.True(err != nil)
+	})
+
+	t.Run("with frame shorter than its length", func(t *testing.T) {
+		is := is.New(t)
+
+		readErr := make(chan error, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r)
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			_, _, err = conn.ReadMessage()
+			readErr <- err
+		}))
+		defer server.Close()
+
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		is.NoErr(err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: " + server.Listener.Addr().String() + "\r\n" +
+			"Connection: Upgrade\r\nUpgrade: websocket\r\n" +
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+		is.NoErr(err)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		is.NoErr(err)
+		is.Equal(resp.StatusCode, http.StatusSwitchingProtocols)
+
+		// A masked binary frame declaring 32 MiB, which ends after a few bytes
+		_, err = conn.Write([]byte{0x82, 0xff, 0, 0, 0, 0, 0x02, 0, 0, 0, 0, 0, 0, 0, 'a', 'b', 'c'})
+		is.NoErr(err)
+		conn.Close()
+
+		is.True(errors.Is(<-readErr, websocket.ErrUnableToRead))
+	})
+
+	t.Run("read timeout", func(t *testing.T) {
+		is := is.New(t)
+
+		conn, err := websocket.Dial(context.Background(), wsURL(server), nil)
+		is.NoErr(err)
+		defer conn.Close()
+
+		conn.SetReadTimeout(10 * time.Millisecond)
+		_, _, err = conn.ReadMessage()
+		is.True(errors.Is(err, websocket.ErrUnableToRead))
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	table := []struct {
+		Name           string
+		Upgrader       websocket.Upgrader
+		Header         http.Header
+		ExpectedStatus int
+	}{
+		{
+			Name:           "without upgrade headers",
+			Header:         http.Header{},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name: "with unsupported version",
+			Header: http.Header{
+				"Connection":            {"keep-alive, Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+				"Sec-Websocket-Version": {"8"},
+			},
+			ExpectedStatus: http.StatusUpgradeRequired,
+		},
+		{
+			Name: "from another origin",
+			Header: http.Header{
+				"Connection":            {"Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+				"Sec-Websocket-Version": {"13"},
+				"Origin":                {"https://other.example.com"},
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Name:     "from an allowed origin",
+			Upgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+			Header: http.Header{
+				"Connection":            {"Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+				"Sec-Websocket-Version": {"13"},
+				"Origin":                {"https://other.example.com"},
+			},
+			ExpectedStatus: http.StatusSwitchingProtocols,
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			server := newEchoServer(&entry.Upgrader)
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			is.NoErr(err)
+			req.Header = entry.Header
+
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			is.NoErr(err)
+			resp.Body.Close()
+			is.Equal(resp.StatusCode, entry.ExpectedStatus)
+
+			// The accept header is computed from the key, as in the example of RFC 6455
+			if entry.ExpectedStatus == http.StatusSwitchingProtocols {
+				is.Equal(resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+			}
+		})
+	}
+
+	t.Run("Dial reports rejected handshakes", func(t *testing.T) {
+		is := is.New(t)
+
+		server := newEchoServer(&websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return false }})
+		defer server.Close()
+
+		_, err := websocket.Dial(context.Background(), wsURL(server), nil)
+		is.True(errors.Is(err, websocket.ErrHandshake))
+	})
+}
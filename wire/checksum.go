@@ -0,0 +1,12 @@
+package wire
+
+import "hash/crc32"
+
+// castagnoli is the CRC-32C table used for frame checksums.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// frameChecksum is the CRC-32C checksum of the details followed by the params, as they are written in the frame.
+func frameChecksum(rawDetails, rawParams []byte) uint32 {
+	checksum := crc32.Update(0, castagnoli, rawDetails)
+	return crc32.Update(checksum, castagnoli, rawParams)
+}
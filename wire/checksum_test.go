@@ -0,0 +1,74 @@
+package wire_test
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+func TestChecksum(t *testing.T) {
+	checksum := crc32.Checksum([]byte("{}null"), crc32.MakeTable(crc32.Castagnoli))
+
+	t.Run("checksummed frames have compression and checksum parts", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		is.NoErr(wire.NewEncoder(&buf, wire.WithChecksum()).Encode(map[string]string{}, nil))
+		is.Equal(buf.String(), "1,2,4,0,"+strconv.FormatUint(uint64(checksum), 10)+":{}null")
+
+		decoded, err := wire.NewDecoder(&buf).Decode()
+		is.NoErr(err)
+		is.True(decoded.Checksummed)
+		is.Equal(string(decoded.RawParams), "null")
+	})
+
+	t.Run("checksums cover compressed params", func(t *testing.T) {
+		is := is.New(t)
+
+		params := strings.Repeat("compress me ", 200)
+
+		var buf bytes.Buffer
+		is.NoErr(wire.NewEncoder(&buf, wire.WithChecksum(), wire.WithCompression(wire.CompressionGzip, 0)).Encode(map[string]string{}, params))
+
+		decoded, err := wire.NewDecoder(&buf).Decode()
+		is.NoErr(err)
+		is.True(decoded.Checksummed)
+		is.Equal(decoded.Compression, wire.CompressionGzip)
+		is.Equal(string(decoded.RawParams), `"`+params+`"`)
+	})
+
+	t.Run("frames without checksums are not checksummed", func(t *testing.T) {
+		is := is.New(t)
+
+		decoded, err := wire.NewDecoder(strings.NewReader("1,2,4:{}null")).Decode()
+		is.NoErr(err)
+		is.True(!decoded.Checksummed)
+	})
+
+	table := []struct {
+		Name    string
+		Message string
+	}{
+		{Name: "with corrupted params", Message: "1,2,4,0," + strconv.FormatUint(uint64(checksum), 10) + ":{}nulL"},
+		{Name: "with corrupted details", Message: "1,2,4,0," + strconv.FormatUint(uint64(checksum), 10) + ":[]null"},
+		{Name: "with wrong checksum", Message: "1,2,4,0,12345:{}null"},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := wire.NewDecoder(strings.NewReader(entry.Message)).Decode()
+			is.True(errors.Is(err, wire.ErrChecksumMismatch))
+			is.True(erk.IsKind(err, wire.ErkChecksumMismatch{}))
+		})
+	}
+}
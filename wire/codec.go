@@ -0,0 +1,193 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/msgpack"
+)
+
+// Errors
+var (
+	ErrCodecInvalid    = erk.New(ErkCodecInvalid{}, "codec '{{.name}}' for version {{.version}} is invalid: {{.reason}}")
+	ErrCodecRegistered = erk.New(ErkCodecInvalid{}, "a codec is already registered with the version {{.version}} or name '{{.name}}'")
+)
+
+// minInfoHeaderParts are the version, details length, and params length.
+const minInfoHeaderParts = 3
+
+// Codec describes an encoding, so it can be registered with RegisterCodec.
+// The JSON and MessagePack encodings are registered as codecs for versions 1 and 2.
+//
+// For example, to add a CBOR encoding as version 10:
+//  wire.RegisterCodec(wire.Codec{
+//    Version:          10,
+//    Name:             "cbor",
+//    AppendDetails:    appendCBOR,
+//    UnmarshalDetails: cbor.Unmarshal,
+//    AppendParams:     appendCBOR,
+//    UnmarshalParams:  cbor.Unmarshal,
+//  })
+type Codec struct {
+	// Version is the first info header part of messages in this encoding. It must be positive.
+	Version Encoding
+
+	// Name of the encoding, returned by Encoding.String.
+	Name string
+
+	// InfoHeaderParts is the number of info header parts: the version, details length, and params length,
+	// followed by any parts specific to the codec (see WithExtraInfo and DecodeResult.ExtraInfo).
+	// Defaults to 3.
+	InfoHeaderParts int
+
+	// AppendDetails appends the encoded details to buf, and UnmarshalDetails decodes them into the value pointed to by v.
+	AppendDetails    func(buf []byte, v interface{}) ([]byte, error)
+	UnmarshalDetails func(data []byte, v interface{}) error
+
+	// AppendParams appends the encoded params to buf, and UnmarshalParams decodes them into the value pointed to by v.
+	AppendParams    func(buf []byte, v interface{}) ([]byte, error)
+	UnmarshalParams func(data []byte, v interface{}) error
+
+	// invalidErr is returned when the info header has the wrong number of parts.
+	// The JSON codec keeps its original error, while others use ErrEncodingInvalid.
+	invalidErr error
+}
+
+func (c *Codec) infoHeaderParts() int {
+	if c.InfoHeaderParts == 0 {
+		return minInfoHeaderParts
+	}
+
+	return c.InfoHeaderParts
+}
+
+func (c *Codec) validate() error {
+	reason := ""
+	switch {
+	case c.Version < 1:
+		reason = "version must be positive"
+	case c.Name == "":
+		reason = "name is required"
+	case c.InfoHeaderParts != 0 && c.InfoHeaderParts < minInfoHeaderParts:
+		reason = "info header must have at least 3 parts"
+	case c.AppendDetails == nil || c.UnmarshalDetails == nil:
+		reason = "details functions are required"
+	case c.AppendParams == nil || c.UnmarshalParams == nil:
+		reason = "params functions are required"
+	default:
+		return nil
+	}
+
+	return erk.WithParams(ErrCodecInvalid, erk.Params{"name": c.Name, "version": int(c.Version), "reason": reason})
+}
+
+// codecs are the registered codecs, by version.
+var codecs = struct {
+	sync.RWMutex
+	byVersion map[Encoding]*Codec
+	byName    map[string]*Codec
+}{
+	byVersion: make(map[Encoding]*Codec),
+	byName:    make(map[string]*Codec),
+}
+
+// RegisterCodec makes the encoding available to Encoders and Decoders.
+// Each version and name can only be registered once.
+//
+// Codecs are usually registered in an init function, since peers can only decode versions they have registered.
+func RegisterCodec(codec Codec) error {
+	if err := codec.validate(); err != nil {
+		return err
+	}
+
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if codecs.byVersion[codec.Version] != nil || codecs.byName[codec.Name] != nil {
+		return erk.WithParams(ErrCodecRegistered, erk.Params{"name": codec.Name, "version": int(codec.Version)})
+	}
+
+	codecs.byVersion[codec.Version] = &codec
+	codecs.byName[codec.Name] = &codec
+	return nil
+}
+
+// LookupCodec returns the codec registered for the version.
+func LookupCodec(version Encoding) (Codec, bool) {
+	codec := lookupCodec(version)
+	if codec == nil {
+		return Codec{}, false
+	}
+
+	return *codec, true
+}
+
+// LookupCodecByName returns the codec registered with the name.
+func LookupCodecByName(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	codec := codecs.byName[name]
+	if codec == nil {
+		return Codec{}, false
+	}
+
+	return *codec, true
+}
+
+func lookupCodec(version Encoding) *Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	return codecs.byVersion[version]
+}
+
+// findCodec returns the codec registered for the version, or ErrHeaderVersionInvalid.
+func findCodec(version Encoding) (*Codec, error) {
+	codec := lookupCodec(version)
+	if codec == nil {
+		return nil, erk.WithParam(ErrHeaderVersionInvalid, "version", int(version))
+	}
+
+	return codec, nil
+}
+
+func init() {
+	builtIn := []Codec{
+		{
+			Version:          EncodingJSON,
+			Name:             "json",
+			AppendDetails:    appendJSON,
+			UnmarshalDetails: json.Unmarshal,
+			AppendParams:     appendJSON,
+			UnmarshalParams:  json.Unmarshal,
+			invalidErr:       ErrEncoding1Invalid,
+		},
+		{
+			Version:          EncodingMsgPack,
+			Name:             "msgpack",
+			AppendDetails:    msgpack.Append,
+			UnmarshalDetails: msgpack.Unmarshal,
+			AppendParams:     msgpack.Append,
+			UnmarshalParams:  msgpack.Unmarshal,
+		},
+	}
+
+	for _, codec := range builtIn {
+		if err := RegisterCodec(codec); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// appendJSON appends v encoded as JSON to buf, so buffers can be reused.
+func appendJSON(buf []byte, v interface{}) ([]byte, error) {
+	b := bytes.NewBuffer(buf)
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return buf, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil // Remove the newline added by json.Encoder
+}
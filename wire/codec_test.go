@@ -0,0 +1,136 @@
+package wire_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+// appendJSON is used to build test codecs.
+func appendJSON(buf []byte, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	return append(buf, data...), err
+}
+
+func TestRegisterCodec(t *testing.T) {
+	valid := wire.Codec{
+		Version:          100,
+		Name:             "test-codec",
+		InfoHeaderParts:  4,
+		AppendDetails:    appendJSON,
+		UnmarshalDetails: json.Unmarshal,
+		AppendParams:     appendJSON,
+		UnmarshalParams:  json.Unmarshal,
+	}
+
+	t.Run("registered codecs are used to encode and decode", func(t *testing.T) {
+		is := is.New(t)
+
+		// The registry is global, so the codec is already registered when tests run more than once
+		if _, ok := wire.LookupCodec(valid.Version); !ok {
+			is.NoErr(wire.RegisterCodec(valid))
+		}
+
+		codec, ok := wire.LookupCodec(100)
+		is.True(ok)
+		is.Equal(codec.Name, "test-codec")
+		is.Equal(wire.Encoding(100).String(), "test-codec")
+
+		_, ok = wire.LookupCodecByName("test-codec")
+		is.True(ok)
+
+		var buf bytes.Buffer
+		is.NoErr(wire.NewEncoder(&buf, wire.WithEncoding(100), wire.WithExtraInfo(7)).Encode(map[string]int{"a": 1}, "hi"))
+		is.Equal(buf.String(), `100,7,4,7:{"a":1}"hi"`)
+
+		decoded, err := wire.NewDecoder(&buf).Decode()
+		is.NoErr(err)
+		is.Equal(decoded.Encoding, wire.Encoding(100))
+		is.Equal(decoded.ExtraInfo, []int{7})
+
+		var params string
+		is.NoErr(decoded.Encoding.UnmarshalParams(decoded.RawParams, &params))
+		is.Equal(params, "hi")
+	})
+
+	t.Run("extra info must match the codec", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		err := wire.NewEncoder(&buf, wire.WithEncoding(100)).Encode(map[string]int{}, nil)
+		is.True(errors.Is(err, wire.ErrExtraInfoInvalid))
+		is.Equal(buf.Len(), 0)
+
+		_, err = wire.NewDecoder(strings.NewReader(`100,2,4:{}null`)).Decode()
+		is.True(errors.Is(err, wire.ErrEncodingInvalid))
+	})
+
+	t.Run("built in codecs are registered", func(t *testing.T) {
+		is := is.New(t)
+
+		codec, ok := wire.LookupCodec(wire.EncodingJSON)
+		is.True(ok)
+		is.Equal(codec.Name, "json")
+
+		codec, ok = wire.LookupCodecByName("msgpack")
+		is.True(ok)
+		is.Equal(codec.Version, wire.EncodingMsgPack)
+
+		_, ok = wire.LookupCodec(99)
+		is.True(!ok)
+		is.Equal(wire.Encoding(99).String(), "encoding 99")
+	})
+
+	table := []struct {
+		Name          string
+		Modify        func(c *wire.Codec)
+		ExpectedError error
+	}{
+		{
+			Name:          "with registered version",
+			Modify:        func(c *wire.Codec) { c.Name = "other" },
+			ExpectedError: wire.ErrCodecRegistered,
+		},
+		{
+			Name:          "with registered name",
+			Modify:        func(c *wire.Codec) { c.Version = 101; c.Name = "json" },
+			ExpectedError: wire.ErrCodecRegistered,
+		},
+		{
+			Name:          "with zero version",
+			Modify:        func(c *wire.Codec) { c.Version = 0 },
+			ExpectedError: wire.ErrCodecInvalid,
+		},
+		{
+			Name:          "without name",
+			Modify:        func(c *wire.Codec) { c.Version = 101; c.Name = "" },
+			ExpectedError: wire.ErrCodecInvalid,
+		},
+		{
+			Name:          "with too few info header parts",
+			Modify:        func(c *wire.Codec) { c.Version = 101; c.InfoHeaderParts = 2 },
+			ExpectedError: wire.ErrCodecInvalid,
+		},
+		{
+			Name:          "without params functions",
+			Modify:        func(c *wire.Codec) { c.Version = 101; c.UnmarshalParams = nil },
+			ExpectedError: wire.ErrCodecInvalid,
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			codec := valid
+			entry.Modify(&codec)
+			is.True(errors.Is(wire.RegisterCodec(codec), entry.ExpectedError))
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package wire
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/JosiahWitt/erk"
+)
+
+// Compression tracks what algorithm compresses the params of a frame.
+type Compression int
+
+// Built in compressions. Others can be added with RegisterCompressor.
+const (
+	CompressionNone  Compression = 0
+	CompressionGzip  Compression = 1
+	CompressionFlate Compression = 2
+)
+
+// DefaultCompressionMinBytes is the default size params must reach before they are compressed.
+const DefaultCompressionMinBytes = 1 << 10 // 1 KiB
+
+// Errors
+var (
+	ErrCompressorInvalid    = erk.New(ErkCodecInvalid{}, "compressor '{{.name}}' for compression {{.compression}} is invalid: {{.reason}}")
+	ErrCompressorRegistered = erk.New(ErkCodecInvalid{}, "a compressor is already registered with the compression {{.compression}} or name '{{.name}}'")
+	ErrCompressionInvalid   = erk.New(ErkHeaderInvalid{}, "compression '{{.compression}}' not implemented")
+	ErrUnableToCompress     = erk.New(ErkJSONMarshalling{}, "could not compress params")
+	ErrUnableToDecompress   = erk.New(ErkUnableToRead{}, "unable to decompress params")
+	ErrDecompressedTooLarge = erk.New(ErkFrameTooLarge{}, "decompressed params are larger than the maximum of {{.max}} bytes")
+)
+
+// Compressor describes a compression algorithm, so it can be registered with RegisterCompressor.
+// Gzip and flate (at its best speed) are registered as compressions 1 and 2.
+//
+// Compressed frames have one more info header part than their codec, containing the compression.
+// Peers that do not support compression reject these frames, so a peer must declare it accepts a compression
+// before it is sent compressed frames (see strand.RequestDetails.AcceptCompression).
+type Compressor struct {
+	// Compression is the info header part of frames compressed by this compressor. It must be positive.
+	Compression Compression
+
+	// Name of the compression, returned by Compression.String.
+	Name string
+
+	// NewWriter returns a writer that compresses to w, until it is closed.
+	// If the writer has a Reset(io.Writer) method, it is reused by the Encoder.
+	NewWriter func(w io.Writer) io.WriteCloser
+
+	// NewReader returns a reader that decompresses r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// resetWriter is implemented by compressing writers that can be reused.
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+func (c *Compressor) validate() error {
+	reason := ""
+	switch {
+	case c.Compression < 1:
+		reason = "compression must be positive"
+	case c.Name == "":
+		reason = "name is required"
+	case c.NewWriter == nil || c.NewReader == nil:
+		reason = "writer and reader functions are required"
+	default:
+		return nil
+	}
+
+	return erk.WithParams(ErrCompressorInvalid, erk.Params{"name": c.Name, "compression": int(c.Compression), "reason": reason})
+}
+
+// compressors are the registered compressors, by compression.
+var compressors = struct {
+	sync.RWMutex
+	byCompression map[Compression]*Compressor
+	byName        map[string]*Compressor
+}{
+	byCompression: make(map[Compression]*Compressor),
+	byName:        make(map[string]*Compressor),
+}
+
+// RegisterCompressor makes the compression available to Encoders and Decoders.
+// Each compression and name can only be registered once.
+func RegisterCompressor(compressor Compressor) error {
+	if err := compressor.validate(); err != nil {
+		return err
+	}
+
+	compressors.Lock()
+	defer compressors.Unlock()
+
+	if compressors.byCompression[compressor.Compression] != nil || compressors.byName[compressor.Name] != nil {
+		return erk.WithParams(ErrCompressorRegistered, erk.Params{"name": compressor.Name, "compression": int(compressor.Compression)})
+	}
+
+	compressors.byCompression[compressor.Compression] = &compressor
+	compressors.byName[compressor.Name] = &compressor
+	return nil
+}
+
+// LookupCompressor returns the compressor registered for the compression.
+func LookupCompressor(compression Compression) (Compressor, bool) {
+	compressor := lookupCompressor(compression)
+	if compressor == nil {
+		return Compressor{}, false
+	}
+
+	return *compressor, true
+}
+
+// Compressions returns every registered compression, in ascending order.
+func Compressions() []Compression {
+	compressors.RLock()
+	defer compressors.RUnlock()
+
+	registered := make([]Compression, 0, len(compressors.byCompression))
+	for compression := range compressors.byCompression {
+		registered = append(registered, compression)
+	}
+
+	sort.Slice(registered, func(i, j int) bool { return registered[i] < registered[j] })
+	return registered
+}
+
+// String returns the name of the registered compressor.
+func (c Compression) String() string {
+	if c == CompressionNone {
+		return "none"
+	}
+
+	if compressor := lookupCompressor(c); compressor != nil {
+		return compressor.Name
+	}
+
+	return "compression " + strconv.Itoa(int(c))
+}
+
+func lookupCompressor(compression Compression) *Compressor {
+	compressors.RLock()
+	defer compressors.RUnlock()
+
+	return compressors.byCompression[compression]
+}
+
+// findCompressor returns the compressor registered for the compression, or ErrCompressionInvalid.
+func findCompressor(compression Compression) (*Compressor, error) {
+	compressor := lookupCompressor(compression)
+	if compressor == nil {
+		return nil, erk.WithParam(ErrCompressionInvalid, "compression", int(compression))
+	}
+
+	return compressor, nil
+}
+
+// decompress the params, reading at most maxBytes of decompressed data (0 for no limit).
+func decompress(compressor *Compressor, rawParams io.Reader, maxBytes int) ([]byte, error) {
+	reader, err := compressor.NewReader(rawParams)
+	if err != nil {
+		return nil, erk.WrapAs(ErrUnableToDecompress, err)
+	}
+	defer reader.Close()
+
+	limited := io.Reader(reader)
+	if maxBytes > 0 {
+		limited = io.LimitReader(reader, int64(maxBytes)+1)
+	}
+
+	params, err := ioutil.ReadAll(limited)
+	if err != nil {
+		return nil, erk.WrapAs(ErrUnableToDecompress, err)
+	}
+
+	if maxBytes > 0 && len(params) > maxBytes {
+		return nil, erk.WithParam(ErrDecompressedTooLarge, "max", maxBytes)
+	}
+
+	return params, nil
+}
+
+func init() {
+	builtIn := []Compressor{
+		{
+			Compression: CompressionGzip,
+			Name:        "gzip",
+			NewWriter: func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			Compression: CompressionFlate,
+			Name:        "flate",
+			NewWriter: func(w io.Writer) io.WriteCloser {
+				fw, _ := flate.NewWriter(w, flate.BestSpeed) // Only fails for invalid levels
+				return fw
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return flate.NewReader(r), nil
+			},
+		},
+	}
+
+	for _, compressor := range builtIn {
+		if err := RegisterCompressor(compressor); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package wire_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hoistup/hoist-go/wire"
+	"github.com/matryer/is"
+)
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("compress me ", 200)
+
+	table := []struct {
+		Name                string
+		Compression         wire.Compression
+		MinBytes            int
+		Params              string
+		ExpectedCompression wire.Compression
+	}{
+		{
+			Name:                "gzip",
+			Compression:         wire.CompressionGzip,
+			MinBytes:            1024,
+			Params:              large,
+			ExpectedCompression: wire.CompressionGzip,
+		},
+		{
+			Name:                "flate",
+			Compression:         wire.CompressionFlate,
+			MinBytes:            1024,
+			Params:              large,
+			ExpectedCompression: wire.CompressionFlate,
+		},
+		{
+			Name:                "below the minimum size",
+			Compression:         wire.CompressionGzip,
+			MinBytes:            1024,
+			Params:              "small",
+			ExpectedCompression: wire.CompressionNone,
+		},
+		{
+			Name:                "when compressing does not make the params smaller",
+			Compression:         wire.CompressionGzip,
+			MinBytes:            0,
+			Params:              "small",
+			ExpectedCompression: wire.CompressionNone,
+		},
+		{
+			Name:                "without compression",
+			Compression:         wire.CompressionNone,
+			Params:              large,
+			ExpectedCompression: wire.CompressionNone,
+		},
+	}
+
+	for _, entry := range table {
+		entry := entry
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			var buf bytes.Buffer
+			e := wire.NewEncoder(&buf, wire.WithCompression(entry.Compression, entry.MinBytes))
+
+			// Encode twice, to reuse the compressing writer
+			is.NoErr(e.Encode(map[string]string{}, entry.Params))
+			is.NoErr(e.Encode(map[string]string{}, entry.Params))
+
+			d := wire.NewDecoder(&buf)
+			for i := 0; i < 2; i++ {
+				decoded, err := d.Decode()
+				is.NoErr(err)
+				is.Equal(decoded.Compression, entry.ExpectedCompression)
+				is.Equal(string(decoded.RawParams), `"`+entry.Params+`"`)
+			}
+		})
+	}
+
+	t.Run("compressed frames have a compression part", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		is.NoErr(wire.NewEncoder(&buf, wire.WithCompression(wire.CompressionGzip, 0)).Encode(map[string]string{}, large))
+		is.True(strings.HasPrefix(buf.String(), "1,2,"))
+		is.True(strings.Contains(buf.String()[:16], ",1:{}"))
+	})
+
+	t.Run("with compression part of zero", func(t *testing.T) {
+		is := is.New(t)
+
+		decoded, err := wire.NewDecoder(strings.NewReader("1,2,4,0:{}null")).Decode()
+		is.NoErr(err)
+		is.Equal(decoded.Compression, wire.CompressionNone)
+		is.Equal(string(decoded.RawParams), "null")
+	})
+
+	t.Run("with unknown compression", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := wire.NewDecoder(strings.NewReader("1,2,4,99:{}null")).Decode()
+		is.True(errors.Is(err, wire.ErrCompressionInvalid))
+	})
+
+	t.Run("with corrupt compressed params", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := wire.NewDecoder(strings.NewReader("1,2,4,1:{}null")).Decode()
+		is.True(errors.Is(err, wire.ErrUnableToDecompress))
+	})
+
+	t.Run("with decompressed params over the limit", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		is.NoErr(wire.NewEncoder(&buf, wire.WithCompression(wire.CompressionGzip, 0)).Encode(map[string]string{}, large))
+
+		_, err := wire.NewDecoder(&buf, wire.WithMaxParamsBytes(1000)).Decode()
+		is.True(errors.Is(err, wire.ErrDecompressedTooLarge))
+	})
+
+	t.Run("built in compressions are registered", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(wire.Compressions()[:2], []wire.Compression{wire.CompressionGzip, wire.CompressionFlate})
+		is.Equal(wire.CompressionGzip.String(), "gzip")
+		is.Equal(wire.CompressionNone.String(), "none")
+		is.Equal(wire.Compression(99).String(), "compression 99")
+	})
+
+	t.Run("invalid compressors are not registered", func(t *testing.T) {
+		is := is.New(t)
+
+		noop := wire.Compressor{
+			Compression: wire.CompressionGzip,
+			Name:        "noop",
+			NewWriter:   func(w io.Writer) io.WriteCloser { return nil },
+			NewReader:   func(r io.Reader) (io.ReadCloser, error) { return nil, nil },
+		}
+		is.True(errors.Is(wire.RegisterCompressor(noop), wire.ErrCompressorRegistered))
+
+		noop.Compression = 0
+		is.True(errors.Is(wire.RegisterCompressor(noop), wire.ErrCompressorInvalid))
+
+		noop.Compression = 100
+		noop.NewReader = nil
+		is.True(errors.Is(wire.RegisterCompressor(noop), wire.ErrCompressorInvalid))
+	})
+}
@@ -2,22 +2,37 @@ package wire
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"strconv"
 	"strings"
 
 	"github.com/JosiahWitt/erk"
+	"github.com/hoistup/hoist-go/internal/chunked"
 )
 
 // Errors
 var (
 	ErrUnableToReadInfoHeader = erk.New(ErkUnableToRead{}, "unable to read info header")
 	ErrHeaderNotInt           = erk.New(ErkHeaderInvalid{}, "'{{.rawInfoPart}}' is not an int in info header")
+	ErrHeaderNegative         = erk.New(ErkHeaderInvalid{}, "'{{.rawInfoPart}}' is negative in info header")
 	ErrHeaderMissingVersion   = erk.New(ErkHeaderInvalid{}, "info header must contain the encoding version")
 	ErrHeaderVersionInvalid   = erk.New(ErkHeaderInvalid{}, "encoding version '{{.version}}' not implemented")
-	ErrEncoding1Invalid       = erk.New(ErkEncodingInvalid{}, "requires exactly three info header parts (version, details length, params length) got '{{.infoHeader}}'")
+	ErrEncoding1Invalid       = erk.New(ErkEncodingInvalid{}, "requires three, four or five info header parts (version, details length, params length, then optional compression and checksum) got '{{.infoHeader}}'")
+	ErrEncodingInvalid        = erk.New(ErkEncodingInvalid{}, "encoding '{{.encoding}}' requires {{.parts}} info header parts, then optional compression and checksum parts, got '{{.infoHeader}}'")
 	ErrUnableToReadDetails    = erk.New(ErkUnableToRead{}, "unable to read details")
 	ErrUnableToReadParams     = erk.New(ErkUnableToRead{}, "unable to read params")
+	ErrInfoHeaderTooLong      = erk.New(ErkFrameTooLarge{}, "info header is longer than the maximum of {{.max}} bytes")
+	ErrDetailsTooLarge        = erk.New(ErkFrameTooLarge{}, "details length {{.length}} is larger than the maximum of {{.max}} bytes")
+	ErrParamsTooLarge         = erk.New(ErkFrameTooLarge{}, "params length {{.length}} is larger than the maximum of {{.max}} bytes")
+	ErrChecksumMismatch       = erk.New(ErkChecksumMismatch{}, "checksum {{.actual}} of the details and params does not match {{.expected}} in the info header")
+)
+
+// Default decoder limits
+const (
+	DefaultMaxInfoHeaderBytes = 64
+	DefaultMaxDetailsBytes    = 1 << 20  // 1 MiB
+	DefaultMaxParamsBytes     = 32 << 20 // 32 MiB
 )
 
 // DecodeResult contains the decoded parts.
@@ -25,28 +40,80 @@ type DecodeResult struct {
 	Encoding   Encoding
 	RawDetails []byte
 	RawParams  []byte
+
+	// ExtraInfo contains the info header parts after the params length, for codecs with more than three parts.
+	ExtraInfo []int
+
+	// Compression of the params in the frame. RawParams are already decompressed.
+	Compression Compression
+
+	// Checksummed is true when the frame had a checksum, which matched the details and params.
+	Checksummed bool
 }
 
 // Decoder is created with NewDecoder, and stores the io.Reader for use between Decode() calls.
 type Decoder struct {
 	reader *bufio.Reader
+
+	maxInfoHeaderBytes int
+	maxDetailsBytes    int
+	maxParamsBytes     int
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithMaxInfoHeaderBytes sets the maximum length of the info header, not including the colon.
+// Defaults to DefaultMaxInfoHeaderBytes. Use 0 for no limit.
+func WithMaxInfoHeaderBytes(size int) DecoderOption {
+	return func(d *Decoder) {
+		d.maxInfoHeaderBytes = size
+	}
+}
+
+// WithMaxDetailsBytes sets the maximum size of the details. Defaults to DefaultMaxDetailsBytes. Use 0 for no limit.
+func WithMaxDetailsBytes(size int) DecoderOption {
+	return func(d *Decoder) {
+		d.maxDetailsBytes = size
+	}
+}
+
+// WithMaxParamsBytes sets the maximum size of the params. Defaults to DefaultMaxParamsBytes. Use 0 for no limit.
+func WithMaxParamsBytes(size int) DecoderOption {
+	return func(d *Decoder) {
+		d.maxParamsBytes = size
+	}
 }
 
 // NewDecoder creates a decoder, which decodes from the provided io.Reader with each Decode() call.
-func NewDecoder(rawReader io.Reader) *Decoder {
-	return &Decoder{
+//
+// Messages larger than the limits set by the options are rejected with an ErkFrameTooLarge error,
+// before their details or params are read. Messages with a checksum that does not match their details
+// and params (see WithChecksum) are rejected with an ErkChecksumMismatch error.
+func NewDecoder(rawReader io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{
 		reader: bufio.NewReader(rawReader),
+
+		maxInfoHeaderBytes: DefaultMaxInfoHeaderBytes,
+		maxDetailsBytes:    DefaultMaxDetailsBytes,
+		maxParamsBytes:     DefaultMaxParamsBytes,
 	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
 }
 
 // Decode the next message on the io.Reader.
 func (d *Decoder) Decode() (*DecodeResult, error) {
-	rawInfoHeader, err := d.reader.ReadString(':')
+	rawInfoHeader, err := d.readInfoHeader()
 	if err != nil {
-		return nil, erk.WrapAs(ErrUnableToReadInfoHeader, err)
+		return nil, err
 	}
 
-	infoHeader, err := parseInfoHeader(strings.TrimSuffix(rawInfoHeader, ":"))
+	infoHeader, err := parseInfoHeader(rawInfoHeader)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +121,27 @@ func (d *Decoder) Decode() (*DecodeResult, error) {
 	return d.decode(infoHeader)
 }
 
+// readInfoHeader reads up to the colon ending the info header, without the colon.
+func (d *Decoder) readInfoHeader() (string, error) {
+	var rawInfoHeader []byte
+	for {
+		b, err := d.reader.ReadByte()
+		if err != nil {
+			return "", erk.WrapAs(ErrUnableToReadInfoHeader, err)
+		}
+
+		if b == ':' {
+			return string(rawInfoHeader), nil
+		}
+
+		if d.maxInfoHeaderBytes > 0 && len(rawInfoHeader) >= d.maxInfoHeaderBytes {
+			return "", erk.WithParam(ErrInfoHeaderTooLong, "max", d.maxInfoHeaderBytes)
+		}
+
+		rawInfoHeader = append(rawInfoHeader, b)
+	}
+}
+
 func parseInfoHeader(rawInfoHeader string) ([]int, error) {
 	rawInfoParts := strings.Split(rawInfoHeader, ",")
 	infoHeader := make([]int, 0, len(rawInfoParts))
@@ -64,6 +152,9 @@ func parseInfoHeader(rawInfoHeader string) ([]int, error) {
 		if err != nil {
 			return nil, erk.WithParam(ErrHeaderNotInt, "rawInfoPart", rawInfoPart)
 		}
+		if infoPart < 0 {
+			return nil, erk.WithParam(ErrHeaderNegative, "rawInfoPart", rawInfoPart)
+		}
 
 		infoHeader = append(infoHeader, infoPart)
 	}
@@ -71,38 +162,87 @@ func parseInfoHeader(rawInfoHeader string) ([]int, error) {
 	return infoHeader, nil
 }
 
+// decode the details and params with the codec registered for the version in the info header.
 func (d *Decoder) decode(infoHeader []int) (*DecodeResult, error) {
 	if len(infoHeader) < 1 {
 		return nil, ErrHeaderMissingVersion // This case seems impossible to reach, since a message of ":" yields an ErrHeaderNotInt
 	}
 
-	switch infoHeader[0] {
-	case 1:
-		return d.decodeJSON(infoHeader)
+	codec, err := findCodec(Encoding(infoHeader[0]))
+	if err != nil {
+		return nil, err
+	}
 
+	// Compressed frames have one more part, containing the compression,
+	// and checksummed frames have two more parts, containing the compression and the checksum
+	codecParts := codec.infoHeaderParts()
+	compression := CompressionNone
+	checksum, checksummed := 0, false
+	switch len(infoHeader) {
+	case codecParts:
+	case codecParts + 1:
+		compression = Compression(infoHeader[codecParts])
+	case codecParts + 2:
+		compression = Compression(infoHeader[codecParts])
+		checksum, checksummed = infoHeader[codecParts+1], true
 	default:
-		return nil, erk.WithParam(ErrHeaderVersionInvalid, "version", infoHeader[0])
+		if codec.invalidErr != nil {
+			return nil, erk.WithParam(codec.invalidErr, "infoHeader", infoHeader)
+		}
+
+		return nil, erk.WithParams(ErrEncodingInvalid, erk.Params{"encoding": codec.Name, "parts": codecParts, "infoHeader": infoHeader})
 	}
-}
 
-func (d *Decoder) decodeJSON(infoHeader []int) (*DecodeResult, error) {
-	if len(infoHeader) != 3 {
-		return nil, erk.WithParam(ErrEncoding1Invalid, "infoHeader", infoHeader)
+	var compressor *Compressor
+	if compression != CompressionNone {
+		var err error
+		if compressor, err = findCompressor(compression); err != nil {
+			return nil, err
+		}
 	}
 
-	rawDetails := make([]byte, infoHeader[1])
-	if _, err := io.ReadFull(d.reader, rawDetails); err != nil {
+	// Check both sizes before reading either
+	if d.maxDetailsBytes > 0 && infoHeader[1] > d.maxDetailsBytes {
+		return nil, erk.WithParams(ErrDetailsTooLarge, erk.Params{"length": infoHeader[1], "max": d.maxDetailsBytes})
+	}
+	if d.maxParamsBytes > 0 && infoHeader[2] > d.maxParamsBytes {
+		return nil, erk.WithParams(ErrParamsTooLarge, erk.Params{"length": infoHeader[2], "max": d.maxParamsBytes})
+	}
+
+	rawDetails, err := chunked.ReadN(d.reader, int64(infoHeader[1]))
+	if err != nil {
 		return nil, erk.WrapAs(ErrUnableToReadDetails, err)
 	}
 
-	rawParams := make([]byte, infoHeader[2])
-	if _, err := io.ReadFull(d.reader, rawParams); err != nil {
+	rawParams, err := chunked.ReadN(d.reader, int64(infoHeader[2]))
+	if err != nil {
 		return nil, erk.WrapAs(ErrUnableToReadParams, err)
 	}
 
-	return &DecodeResult{
-		Encoding:   EncodingJSON,
-		RawDetails: rawDetails,
-		RawParams:  rawParams,
-	}, nil
+	// Verify the frame as it was sent, before decompressing it
+	if checksummed {
+		if actual := frameChecksum(rawDetails, rawParams); int64(actual) != int64(checksum) {
+			return nil, erk.WithParams(ErrChecksumMismatch, erk.Params{"expected": checksum, "actual": actual})
+		}
+	}
+
+	if compressor != nil {
+		rawParams, err = decompress(compressor, bytes.NewReader(rawParams), d.maxParamsBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DecodeResult{
+		Encoding:    codec.Version,
+		RawDetails:  rawDetails,
+		RawParams:   rawParams,
+		Compression: compression,
+		Checksummed: checksummed,
+	}
+	if codecParts > minInfoHeaderParts {
+		result.ExtraInfo = infoHeader[minInfoHeaderParts:codecParts]
+	}
+
+	return result, nil
 }
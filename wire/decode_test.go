@@ -2,6 +2,8 @@ package wire_test
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hoistup/hoist-go/wire"
@@ -74,13 +76,13 @@ func TestDecode(t *testing.T) {
 		},
 		{
 			Name:           "with invalid encoding version",
-			Message:        []byte("2:"),
+			Message:        []byte("3:"),
 			ExpectedResult: nil,
 			ExpectedError:  wire.ErrHeaderVersionInvalid,
 		},
 		{
 			Name:           "encoding version 1: incorrect number of headers",
-			Message:        []byte("1,4,5,6:"),
+			Message:        []byte("1,4,5,0,6,7:"),
 			ExpectedResult: nil,
 			ExpectedError:  wire.ErrEncoding1Invalid,
 		},
@@ -96,6 +98,39 @@ func TestDecode(t *testing.T) {
 			ExpectedResult: nil,
 			ExpectedError:  wire.ErrUnableToReadParams,
 		},
+		{
+			Name:    "encoding version 2: with present details and present params",
+			Message: []byte("2,4,1:\x81\xa1a\x01\xc3"),
+			ExpectedResult: &wire.DecodeResult{
+				Encoding:   wire.EncodingMsgPack,
+				RawDetails: []byte("\x81\xa1a\x01"),
+				RawParams:  []byte("\xc3"),
+			},
+		},
+		{
+			Name:           "encoding version 2: incorrect number of headers",
+			Message:        []byte("2,4:"),
+			ExpectedResult: nil,
+			ExpectedError:  wire.ErrEncodingInvalid,
+		},
+		{
+			Name:           "with negative length",
+			Message:        []byte("1,-4,4:"),
+			ExpectedResult: nil,
+			ExpectedError:  wire.ErrHeaderNegative,
+		},
+		{
+			Name:           "encoding version 1: details too large",
+			Message:        []byte("1,2000000000,0:"),
+			ExpectedResult: nil,
+			ExpectedError:  wire.ErrDetailsTooLarge,
+		},
+		{
+			Name:           "encoding version 1: params too large",
+			Message:        []byte("1,4,2000000000:"),
+			ExpectedResult: nil,
+			ExpectedError:  wire.ErrParamsTooLarge,
+		},
 	}
 
 	r := &testReader{}
@@ -111,3 +146,83 @@ func TestDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoderOptions(t *testing.T) {
+	largeParams := `"` + strings.Repeat("a", 100000) + `"`
+
+	table := []struct {
+		Name           string
+		Options        []wire.DecoderOption
+		Message        []byte
+		ExpectedResult *wire.DecodeResult
+		ExpectedError  error
+	}{
+		{
+			Name:          "with info header too long",
+			Message:       []byte(strings.Repeat("1", 65) + ":"),
+			ExpectedError: wire.ErrInfoHeaderTooLong,
+		},
+		{
+			Name:    "with info header at the limit",
+			Options: []wire.DecoderOption{wire.WithMaxInfoHeaderBytes(5)},
+			Message: []byte("1,4,4:nullnull"),
+			ExpectedResult: &wire.DecodeResult{
+				Encoding:   1,
+				RawDetails: []byte("null"),
+				RawParams:  []byte("null"),
+			},
+		},
+		{
+			Name:          "with info header over the limit",
+			Options:       []wire.DecoderOption{wire.WithMaxInfoHeaderBytes(4)},
+			Message:       []byte("1,4,4:nullnull"),
+			ExpectedError: wire.ErrInfoHeaderTooLong,
+		},
+		{
+			Name:          "with details over the limit",
+			Options:       []wire.DecoderOption{wire.WithMaxDetailsBytes(3)},
+			Message:       []byte("1,4,4:nullnull"),
+			ExpectedError: wire.ErrDetailsTooLarge,
+		},
+		{
+			Name:          "with params over the limit",
+			Options:       []wire.DecoderOption{wire.WithMaxParamsBytes(3)},
+			Message:       []byte("1,4,4:nullnull"),
+			ExpectedError: wire.ErrParamsTooLarge,
+		},
+		{
+			Name:    "with large params",
+			Message: []byte("1,4," + strconv.Itoa(len(largeParams)) + ":null" + largeParams),
+			ExpectedResult: &wire.DecodeResult{
+				Encoding:   1,
+				RawDetails: []byte("null"),
+				RawParams:  []byte(largeParams),
+			},
+		},
+		{
+			Name:          "with large params cut short",
+			Message:       []byte("1,4," + strconv.Itoa(len(largeParams)+1) + ":null" + largeParams),
+			ExpectedError: wire.ErrUnableToReadParams,
+		},
+		{
+			Name:    "with no limits",
+			Options: []wire.DecoderOption{wire.WithMaxInfoHeaderBytes(0), wire.WithMaxDetailsBytes(0), wire.WithMaxParamsBytes(0)},
+			Message: []byte(strings.Repeat("0", 100) + "1,4,4:nullnull"),
+			ExpectedResult: &wire.DecodeResult{
+				Encoding:   1,
+				RawDetails: []byte("null"),
+				RawParams:  []byte("null"),
+			},
+		},
+	}
+
+	for _, entry := range table {
+		t.Run(entry.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			res, err := wire.NewDecoder(&testReader{next: entry.Message}, entry.Options...).Decode()
+			is.True(errors.Is(err, entry.ExpectedError))
+			is.Equal(res, entry.ExpectedResult)
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package wire
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"io"
+	"strconv"
 
 	"github.com/JosiahWitt/erk"
 )
@@ -12,32 +14,277 @@ var (
 	ErrNilDetails            = erk.New(ErkNilDetails{}, "details cannot be nil")
 	ErrUnableToEncodeParams  = erk.New(ErkJSONMarshalling{}, "could not encode params")
 	ErrUnableToEncodeDetails = erk.New(ErkJSONMarshalling{}, "could not encode details")
+	ErrUnableToWrite         = erk.New(ErkUnableToWrite{}, "unable to write message")
+	ErrExtraInfoInvalid      = erk.New(ErkEncodingInvalid{}, "encoding '{{.encoding}}' requires {{.expected}} extra info header parts, got {{.actual}}")
 )
 
+// maxRetainedBufferSize is the largest buffer an Encoder keeps between Encode calls,
+// so one large message does not hold on to its memory.
+const maxRetainedBufferSize = 64 << 10
+
 // Encode details and params to the Hoist Wire specification.
 //
 // If the params are already JSON encoded, call EncodeWithJSONParams.
+// To write to an io.Writer, use an Encoder.
 func Encode(details, params interface{}) ([]byte, error) {
-	paramsJSON, err := json.Marshal(params)
-	if err != nil {
-		return nil, erk.WrapAs(ErrUnableToEncodeParams, err)
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(details, params); err != nil {
+		return nil, err
 	}
 
-	return EncodeWithJSONParams(details, paramsJSON)
+	return buf.Bytes(), nil
 }
 
 // EncodeWithJSONParams encodes details and JSON encoded params to the Hoist Wire specification.
 //
 // If the params are not JSON encoded, call Encode.
 func EncodeWithJSONParams(details interface{}, paramsJSON []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).EncodeWithJSONParams(details, paramsJSON); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Encoder is created with NewEncoder, and writes messages to the io.Writer with each Encode() call.
+// Buffers are reused between calls, so an Encoder should be reused for every message written to a connection.
+//
+// An Encoder is not safe for concurrent use.
+type Encoder struct {
+	writer    io.Writer
+	encoding  Encoding
+	extraInfo []int
+
+	compression         Compression
+	compressionMinBytes int
+	checksum            bool
+	compressWriter      resetWriter // Reused when the compressor supports it
+
+	header     []byte // Room for the info header, followed by the details
+	info       []byte // The info header, before it is copied in front of the details
+	params     []byte
+	compressed bytes.Buffer
+}
+
+// EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// WithEncoding sets the encoding of the messages. Defaults to DefaultEncoding.
+func WithEncoding(encoding Encoding) EncoderOption {
+	return func(e *Encoder) {
+		e.encoding = encoding
+	}
+}
+
+// WithExtraInfo sets the info header parts written after the params length,
+// for codecs with more than three parts (see Codec.InfoHeaderParts).
+func WithExtraInfo(parts ...int) EncoderOption {
+	return func(e *Encoder) {
+		e.extraInfo = parts
+	}
+}
+
+// WithCompression compresses params that are at least minBytes long, when compressing makes them smaller.
+// Defaults to CompressionNone.
+//
+// Only use a compression the peer has declared it accepts, since other peers cannot decode compressed frames.
+func WithCompression(compression Compression, minBytes int) EncoderOption {
+	return func(e *Encoder) {
+		e.compression = compression
+		e.compressionMinBytes = minBytes
+	}
+}
+
+// WithChecksum adds a CRC-32C checksum of the details and params to the info header of each message,
+// so the Decoder can detect frames that were corrupted or truncated in transport.
+//
+// Checksummed frames also include the compression part, so peers that do not support compression cannot decode them.
+func WithChecksum() EncoderOption {
+	return func(e *Encoder) {
+		e.checksum = true
+	}
+}
+
+// NewEncoder creates an encoder, which encodes to the provided io.Writer with each Encode() call.
+func NewEncoder(writer io.Writer, opts ...EncoderOption) *Encoder {
+	e := &Encoder{
+		writer:   writer,
+		encoding: DefaultEncoding,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Encode details and params as the next message on the io.Writer.
+//
+// Both are encoded before writing, so nothing is written if either cannot be encoded.
+func (e *Encoder) Encode(details, params interface{}) error {
+	defer e.release()
+
+	codec, err := findCodec(e.encoding)
+	if err != nil {
+		return erk.WrapAs(ErrUnableToEncodeParams, err)
+	}
+
+	e.params, err = codec.AppendParams(e.params[:0], params)
+	if err != nil {
+		return erk.WrapAs(ErrUnableToEncodeParams, err)
+	}
+
+	return e.encode(codec, details, e.params)
+}
+
+// EncodeWithJSONParams encodes details and JSON encoded params as the next message on the io.Writer.
+// If the Encoder does not use the JSON encoding, the params are converted to its encoding.
+func (e *Encoder) EncodeWithJSONParams(details interface{}, paramsJSON []byte) error {
+	defer e.release()
+
+	codec, err := findCodec(e.encoding)
+	if err != nil {
+		return erk.WrapAs(ErrUnableToEncodeParams, err)
+	}
+
+	if e.encoding == EncodingJSON {
+		return e.encode(codec, details, paramsJSON)
+	}
+
+	var params interface{}
+	dec := json.NewDecoder(bytes.NewReader(paramsJSON))
+	dec.UseNumber()
+	if err := dec.Decode(&params); err != nil {
+		return erk.WrapAs(ErrUnableToEncodeParams, err)
+	}
+
+	e.params, err = codec.AppendParams(e.params[:0], params)
+	if err != nil {
+		return erk.WrapAs(ErrUnableToEncodeParams, err)
+	}
+
+	return e.encode(codec, details, e.params)
+}
+
+// maxInfoPartSize fits an int64 part and its separator.
+const maxInfoPartSize = 20 + 1
+
+func (e *Encoder) encode(codec *Codec, details interface{}, rawParams []byte) error {
 	if details == nil {
-		return nil, ErrNilDetails
+		return ErrNilDetails
+	}
+
+	if len(e.extraInfo) != codec.infoHeaderParts()-minInfoHeaderParts {
+		return erk.WithParams(ErrExtraInfoInvalid, erk.Params{
+			"encoding": codec.Name,
+			"expected": codec.infoHeaderParts() - minInfoHeaderParts,
+			"actual":   len(e.extraInfo),
+		})
+	}
+
+	compression, rawParams, err := e.compress(rawParams)
+	if err != nil {
+		return err
+	}
+
+	// Leave room for the info header, which needs the length of the details
+	maxInfoHeaderSize := (codec.infoHeaderParts() + 2) * maxInfoPartSize
+	if cap(e.header) < maxInfoHeaderSize {
+		e.header = make([]byte, maxInfoHeaderSize, 2*maxInfoHeaderSize)
+	}
+
+	e.header, err = codec.AppendDetails(e.header[:maxInfoHeaderSize], details)
+	if err != nil {
+		return erk.WrapAs(ErrUnableToEncodeDetails, err)
+	}
+
+	// Write the info header right before the details
+	detailsLength := len(e.header) - maxInfoHeaderSize
+	header := strconv.AppendInt(e.info[:0], int64(codec.Version), 10)
+	header = append(header, ',')
+	header = strconv.AppendInt(header, int64(detailsLength), 10)
+	header = append(header, ',')
+	header = strconv.AppendInt(header, int64(len(rawParams)), 10)
+	for _, part := range e.extraInfo {
+		header = append(header, ',')
+		header = strconv.AppendInt(header, int64(part), 10)
+	}
+	if compression != CompressionNone || e.checksum {
+		header = append(header, ',')
+		header = strconv.AppendInt(header, int64(compression), 10)
+	}
+	if e.checksum {
+		header = append(header, ',')
+		header = strconv.AppendUint(header, uint64(frameChecksum(e.header[maxInfoHeaderSize:], rawParams)), 10)
 	}
+	header = append(header, ':')
+	e.info = header
 
-	detailsJSON, err := json.Marshal(details)
+	frame := e.header[maxInfoHeaderSize-len(header):]
+	copy(frame, header)
+
+	if _, err := e.writer.Write(frame); err != nil {
+		return erk.WrapAs(ErrUnableToWrite, err)
+	}
+
+	if _, err := e.writer.Write(rawParams); err != nil {
+		return erk.WrapAs(ErrUnableToWrite, err)
+	}
+
+	return nil
+}
+
+// compress the params if they are large enough, returning the compression used and the params to write.
+func (e *Encoder) compress(rawParams []byte) (Compression, []byte, error) {
+	if e.compression == CompressionNone || len(rawParams) < e.compressionMinBytes {
+		return CompressionNone, rawParams, nil
+	}
+
+	compressor, err := findCompressor(e.compression)
 	if err != nil {
-		return nil, erk.WrapAs(ErrUnableToEncodeDetails, err)
+		return CompressionNone, nil, erk.WrapAs(ErrUnableToCompress, err)
 	}
 
-	return []byte(fmt.Sprintf("%d,%d,%d:%s%s", DefaultEncoding, len(detailsJSON), len(paramsJSON), detailsJSON, paramsJSON)), nil
+	e.compressed.Reset()
+	var writer io.WriteCloser
+	if e.compressWriter != nil {
+		e.compressWriter.Reset(&e.compressed)
+		writer = e.compressWriter
+	} else {
+		writer = compressor.NewWriter(&e.compressed)
+		if resettable, ok := writer.(resetWriter); ok {
+			e.compressWriter = resettable
+		}
+	}
+
+	if _, err := writer.Write(rawParams); err != nil {
+		return CompressionNone, nil, erk.WrapAs(ErrUnableToCompress, err)
+	}
+	if err := writer.Close(); err != nil {
+		return CompressionNone, nil, erk.WrapAs(ErrUnableToCompress, err)
+	}
+
+	// Small or random params can grow when compressed
+	if e.compressed.Len() >= len(rawParams) {
+		return CompressionNone, rawParams, nil
+	}
+
+	return e.compression, e.compressed.Bytes(), nil
+}
+
+// release buffers that grew too large to keep.
+func (e *Encoder) release() {
+	if cap(e.header) > maxRetainedBufferSize {
+		e.header = nil
+	}
+
+	if cap(e.params) > maxRetainedBufferSize {
+		e.params = nil
+	}
+
+	if e.compressed.Cap() > maxRetainedBufferSize {
+		e.compressed = bytes.Buffer{}
+	}
 }
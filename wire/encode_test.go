@@ -1,7 +1,9 @@
 package wire_test
 
 import (
+	"bytes"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/hoistup/hoist-go/wire"
@@ -94,6 +96,58 @@ func TestEncode(t *testing.T) {
 			encoding, err := wire.Encode(entry.Details, entry.Params)
 			is.True(errors.Is(err, entry.ExpectedError))
 			is.Equal(string(encoding), entry.ExpectedEncoding)
+
+			// Nothing is written when encoding fails
+			var buf bytes.Buffer
+			err = wire.NewEncoder(&buf).Encode(entry.Details, entry.Params)
+			is.True(errors.Is(err, entry.ExpectedError))
+			is.Equal(buf.String(), entry.ExpectedEncoding)
 		})
 	}
 }
+
+type testWriter struct {
+	remaining int
+}
+
+func (w *testWriter) Write(p []byte) (n int, err error) {
+	if len(p) > w.remaining {
+		return 0, errors.New("testWriter out of space")
+	}
+
+	w.remaining -= len(p)
+	return len(p), nil
+}
+
+func TestEncoder(t *testing.T) {
+	t.Run("with many messages", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		encoder := wire.NewEncoder(&buf)
+		is.NoErr(encoder.Encode(map[string]string{"id": "1"}, "first"))
+		is.NoErr(encoder.EncodeWithJSONParams(map[string]string{"id": "2"}, []byte(`"second"`)))
+		is.NoErr(encoder.Encode(map[string]string{"id": "3"}, strings.Repeat("a", 100000)))
+		is.NoErr(encoder.Encode(map[string]string{"id": "4"}, nil))
+
+		decoder := wire.NewDecoder(&buf)
+		for _, expected := range []struct{ Details, Params string }{
+			{Details: `{"id":"1"}`, Params: `"first"`},
+			{Details: `{"id":"2"}`, Params: `"second"`},
+			{Details: `{"id":"3"}`, Params: `"` + strings.Repeat("a", 100000) + `"`},
+			{Details: `{"id":"4"}`, Params: `null`},
+		} {
+			decoded, err := decoder.Decode()
+			is.NoErr(err)
+			is.Equal(string(decoded.RawDetails), expected.Details)
+			is.Equal(string(decoded.RawParams), expected.Params)
+		}
+	})
+
+	t.Run("with write error", func(t *testing.T) {
+		is := is.New(t)
+
+		err := wire.NewEncoder(&testWriter{remaining: 5}).Encode(map[string]string{"id": "1"}, "first")
+		is.True(errors.Is(err, wire.ErrUnableToWrite))
+	})
+}
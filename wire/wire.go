@@ -1,7 +1,11 @@
 // Package wire implements the Hoist Wire specification (https://github.com/hoistup/hoist/tree/master/specs/wire.md).
 package wire
 
-import "github.com/hoistup/hoist-go/erks"
+import (
+	"strconv"
+
+	"github.com/hoistup/hoist-go/erks"
+)
 
 // Encoding tracks what Wire encoding is used.
 type Encoding int
@@ -10,9 +14,10 @@ type Encoding int
 // This may change in the future, if other types are supported.
 const DefaultEncoding = EncodingJSON
 
-// Various encodings
+// Built in encodings. Others can be added with RegisterCodec.
 const (
-	EncodingJSON Encoding = 1
+	EncodingJSON    Encoding = 1
+	EncodingMsgPack Encoding = 2
 )
 
 // Error kinds
@@ -20,6 +25,61 @@ type (
 	ErkNilDetails      struct{ erks.Default }
 	ErkJSONMarshalling struct{ erks.Default }
 	ErkUnableToRead    struct{ erks.Default }
+	ErkUnableToWrite   struct{ erks.Default }
 	ErkHeaderInvalid   struct{ erks.Default }
 	ErkEncodingInvalid struct{ erks.Default }
+	ErkFrameTooLarge   struct{ erks.Default }
+	ErkCodecInvalid    struct{ erks.Default }
+
+	// ErkChecksumMismatch means the frame was corrupted or truncated in transport.
+	ErkChecksumMismatch struct{ erks.Default }
 )
+
+// String returns the name of the registered codec.
+func (e Encoding) String() string {
+	if codec := lookupCodec(e); codec != nil {
+		return codec.Name
+	}
+
+	return "encoding " + strconv.Itoa(int(e))
+}
+
+// MarshalDetails encodes v as details, using the registered codec.
+func (e Encoding) MarshalDetails(v interface{}) ([]byte, error) {
+	codec, err := findCodec(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.AppendDetails(nil, v)
+}
+
+// UnmarshalDetails decodes the details into the value pointed to by v, using the registered codec.
+func (e Encoding) UnmarshalDetails(data []byte, v interface{}) error {
+	codec, err := findCodec(e)
+	if err != nil {
+		return err
+	}
+
+	return codec.UnmarshalDetails(data, v)
+}
+
+// MarshalParams encodes v as params, using the registered codec.
+func (e Encoding) MarshalParams(v interface{}) ([]byte, error) {
+	codec, err := findCodec(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.AppendParams(nil, v)
+}
+
+// UnmarshalParams decodes the params into the value pointed to by v, using the registered codec.
+func (e Encoding) UnmarshalParams(data []byte, v interface{}) error {
+	codec, err := findCodec(e)
+	if err != nil {
+		return err
+	}
+
+	return codec.UnmarshalParams(data, v)
+}